@@ -15,6 +15,7 @@ import (
 	"connpass-requirement/internal/config"
 	"connpass-requirement/internal/database"
 	"connpass-requirement/internal/handlers"
+	"connpass-requirement/internal/policy"
 	"connpass-requirement/internal/repository"
 	"connpass-requirement/internal/services"
 	"connpass-requirement/migrations"
@@ -39,14 +40,33 @@ func main() {
 		log.Fatalf("failed to run migrations: %v", err)
 	}
 
-	userRepo := repository.NewUserRepository(db)
+	cipher, err := repository.NewCipher(cfg.EncryptionKeys, cfg.EncryptionPrimaryKeyID)
+	if err != nil {
+		log.Fatalf("failed to init encryption: %v", err)
+	}
+	if !cipher.Enabled() {
+		log.Printf("TOKEN_ENCRYPTION_KEYS is not set. OAuth tokens and webhook secrets are stored in plaintext")
+	}
+
+	userRepo := repository.NewUserRepository(db, cipher)
 	ruleRepo := repository.NewRuleRepository(db)
 	logRepo := repository.NewLogRepository(db)
 	eventRepo := repository.NewEventRepository(db)
 	notificationRepo := repository.NewNotificationRepository(db)
+	ruleEventRepo := repository.NewRuleEventRepository(db)
+	feedTokenRepo := repository.NewFeedTokenRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db, cipher)
+	guildRoleRepo := repository.NewGuildRoleRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	apiTokenRepo := repository.NewAPITokenRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	ruleTemplateRepo := repository.NewRuleTemplateRepository(db)
 
 	oauthService := services.NewOAuthService(cfg)
 	loggerService := services.NewLoggerService(logRepo)
+	webhookService := services.NewWebhookService(webhookRepo)
+	loggerService.AttachWebhooks(webhookService)
+	go webhookService.Start(ctx)
 	connpassService := services.NewConnpassService(cfg)
 
 	var discordService *services.DiscordService
@@ -63,11 +83,15 @@ func main() {
 		log.Printf("DISCORD_BOT_TOKEN is not set. Channel listing and test notification APIs are disabled")
 	}
 
-	var notifierService *services.NotifierService
+	guildSyncService := services.NewGuildSyncService(userRepo, oauthService, discordService, loggerService, cfg.GuildSyncInterval)
+	go guildSyncService.Start(ctx)
+
+	notifierService := services.NewNotifierService(notificationRepo, eventRepo, discordService, loggerService, cfg.NotificationDefaultLimit)
+	simulatorService := services.NewSimulatorService(ruleEventRepo, connpassService, notifierService)
+
 	var schedulerService *services.SchedulerService
 	if discordService != nil {
-		notifierService = services.NewNotifierService(notificationRepo, eventRepo, discordService, loggerService, cfg.NotificationDefaultLimit)
-		schedulerService = services.NewSchedulerService(ruleRepo, notificationRepo, eventRepo, logRepo, connpassService, notifierService, loggerService)
+		schedulerService = services.NewSchedulerService(ruleRepo, ruleEventRepo, notificationRepo, eventRepo, logRepo, connpassService, notifierService, loggerService, cfg.EventSnapshotRetention, cfg.RuleTrashRetention)
 	}
 
 	e := echo.New()
@@ -87,20 +111,33 @@ func main() {
 		return c.String(http.StatusOK, "ok")
 	})
 
+	auditService := services.NewAuditService(auditRepo, loggerService)
+	authz := handlers.NewAuthorizer(policy.New(userRepo, guildRoleRepo, ruleRepo, cfg.SystemAdminDiscordIDs))
+	feedHandler := handlers.NewFeedHandler(feedTokenRepo, eventRepo, ruleRepo, userRepo, authz, notificationRepo, loggerService, auditService, cfg.PublicBaseURL)
+	handlers.RegisterFeedRoutes(e, feedHandler)
+
 	api := e.Group("/api")
-	authHandler := handlers.NewAuthHandler(cfg, oauthService, userRepo, loggerService)
+	authHandler := handlers.NewAuthHandler(cfg, oauthService, userRepo, sessionRepo, services.NewSessionService(cfg, sessionRepo, userRepo), guildSyncService, loggerService)
 	handlers.RegisterAuthRoutes(api, authHandler)
 
 	authenticated := api.Group("")
-	authenticated.Use(handlers.JWTMiddleware(cfg))
+	apiTokenService := services.NewAPITokenService(apiTokenRepo)
+	authenticated.Use(handlers.JWTMiddleware(cfg, sessionRepo, apiTokenService))
 
 	handlers.RegisterAuthRoutesWithMiddleware(authenticated, authHandler)
-	handlers.RegisterGuildRoutes(authenticated, handlers.NewGuildHandler(userRepo, discordService))
-	handlers.RegisterRuleRoutes(authenticated, handlers.NewRuleHandler(ruleRepo, userRepo, loggerService, discordService))
-	handlers.RegisterStatusRoutes(authenticated, handlers.NewStatusHandler(logRepo))
-	handlers.RegisterLogRoutes(authenticated, handlers.NewLogHandler(logRepo))
+	handlers.RegisterAPITokenRoutes(authenticated, handlers.NewAPITokenHandler(apiTokenRepo, apiTokenService, loggerService))
+	handlers.RegisterGuildRoutes(authenticated, handlers.NewGuildHandler(userRepo, authz, discordService, auditService))
+	handlers.RegisterRoleRoutes(authenticated, handlers.NewRoleHandler(guildRoleRepo, userRepo, authz, discordService, auditService))
+	handlers.RegisterRuleRoutes(authenticated, handlers.NewRuleHandler(ruleRepo, authz, loggerService, discordService, simulatorService, auditService))
+	handlers.RegisterTemplateRoutes(authenticated, handlers.NewTemplateHandler(ruleTemplateRepo, ruleRepo, authz, discordService, loggerService, auditService))
+	handlers.RegisterAuditRoutes(authenticated, handlers.NewAuditHandler(auditRepo, authz))
+	handlers.RegisterStatusRoutes(authenticated, handlers.NewStatusHandler(logRepo, authz))
+	handlers.RegisterLogRoutes(authenticated, handlers.NewLogHandler(logRepo, authz))
+	handlers.RegisterEventRoutes(authenticated, handlers.NewEventHandler(eventRepo, authz))
+	handlers.RegisterFeedTokenRoutes(authenticated, feedHandler)
+	handlers.RegisterWebhookRoutes(authenticated, handlers.NewWebhookHandler(webhookRepo, authz, webhookService, loggerService, auditService))
 	if schedulerService != nil {
-		handlers.RegisterSchedulerRoutes(authenticated, handlers.NewSchedulerHandler(schedulerService))
+		handlers.RegisterSchedulerRoutes(authenticated, handlers.NewSchedulerHandler(schedulerService, authz))
 	}
 
 	server := &http.Server{
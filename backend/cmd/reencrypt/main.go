@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"connpass-requirement/internal/config"
+	"connpass-requirement/internal/database"
+	"connpass-requirement/internal/repository"
+)
+
+// reencrypt は平文・旧形式（AADなし）・旧鍵で保存された機密カラムを現在の主鍵で暗号化し直す。
+// 鍵のローテーション時は新旧両方の鍵をTOKEN_ENCRYPTION_KEYSに残したまま実行する。
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	cipher, err := repository.NewCipher(cfg.EncryptionKeys, cfg.EncryptionPrimaryKeyID)
+	if err != nil {
+		log.Fatalf("failed to init encryption: %v", err)
+	}
+	if !cipher.Enabled() {
+		log.Fatalf("TOKEN_ENCRYPTION_KEYS is required")
+	}
+
+	db, err := database.Connect(ctx, cfg)
+	if err != nil {
+		log.Fatalf("failed to connect database: %v", err)
+	}
+	defer db.Close()
+
+	users, err := repository.NewUserRepository(db, cipher).ReencryptTokens(ctx)
+	if err != nil {
+		log.Fatalf("failed to re-encrypt user tokens: %v", err)
+	}
+	webhooks, err := repository.NewWebhookRepository(db, cipher).ReencryptSecrets(ctx)
+	if err != nil {
+		log.Fatalf("failed to re-encrypt webhook secrets: %v", err)
+	}
+	log.Printf("re-encryption completed: primaryKey=%s users=%d webhooks=%d", cfg.EncryptionPrimaryKeyID, users, webhooks)
+}
@@ -27,12 +27,21 @@ func main() {
 	}
 	defer db.Close()
 
+	cipher, err := repository.NewCipher(cfg.EncryptionKeys, cfg.EncryptionPrimaryKeyID)
+	if err != nil {
+		log.Fatalf("failed to init encryption: %v", err)
+	}
+
 	ruleRepo := repository.NewRuleRepository(db)
 	eventRepo := repository.NewEventRepository(db)
 	notificationRepo := repository.NewNotificationRepository(db)
 	logRepo := repository.NewLogRepository(db)
+	ruleEventRepo := repository.NewRuleEventRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db, cipher)
 
 	logger := services.NewLoggerService(logRepo)
+	webhooks := services.NewWebhookService(webhookRepo)
+	logger.AttachWebhooks(webhooks)
 	connpass := services.NewConnpassService(cfg)
 
 	discordService, err := services.NewDiscordService(cfg.DiscordBotToken)
@@ -45,9 +54,16 @@ func main() {
 	defer discordService.Close()
 
 	notifier := services.NewNotifierService(notificationRepo, eventRepo, discordService, logger, cfg.NotificationDefaultLimit)
-	scheduler := services.NewSchedulerService(ruleRepo, notificationRepo, eventRepo, logRepo, connpass, notifier, logger)
+	scheduler := services.NewSchedulerService(ruleRepo, ruleEventRepo, notificationRepo, eventRepo, logRepo, connpass, notifier, logger, cfg.EventSnapshotRetention, cfg.RuleTrashRetention)
 
-	if err := scheduler.Run(ctx); err != nil {
+	stats, err := scheduler.Run(ctx)
+	// 失敗分の再試行はAPIプロセスの配信ループが引き継ぐ
+	if _, werr := webhooks.DeliverDue(ctx); werr != nil {
+		log.Printf("webhook delivery failed: %v", werr)
+	}
+	if err != nil {
 		log.Printf("scheduler run failed: %v", err)
+		return
 	}
+	log.Printf("scheduler run completed: rules=%d fetched=%d excluded=%d notifications=%d", stats.Rules, stats.EventsFetched, stats.EventsExcluded, stats.Notifications)
 }
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
 	"os"
 	"strconv"
@@ -23,9 +24,17 @@ type Config struct {
 	ConnpassRequestInterval  time.Duration
 	NotificationDefaultLimit int
 	SchedulerInterval        time.Duration
+	GuildSyncInterval        time.Duration
+	EventSnapshotRetention   time.Duration
+	RuleTrashRetention       time.Duration
 	SessionMode              string
 	SessionDuration          time.Duration
+	AccessTokenTTL           time.Duration
 	CORSAllowOrigins         []string
+	PublicBaseURL            string
+	SystemAdminDiscordIDs    []string
+	EncryptionKeys           map[string][]byte
+	EncryptionPrimaryKeyID   string
 }
 
 // Load は環境変数から設定値を読み込み、バリデーションを行う。
@@ -70,6 +79,36 @@ func Load() (Config, error) {
 	}
 	cfg.SchedulerInterval = schedulerInterval
 
+	// ギルド権限をDiscordと再同期する間隔
+	guildSyncIntervalStr := getEnv("GUILD_SYNC_INTERVAL", "1h")
+	guildSyncInterval, err := time.ParseDuration(guildSyncIntervalStr)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid GUILD_SYNC_INTERVAL: %w", err)
+	}
+	if guildSyncInterval <= 0 {
+		return cfg, fmt.Errorf("GUILD_SYNC_INTERVAL must be positive")
+	}
+	cfg.GuildSyncInterval = guildSyncInterval
+
+	// イベントスナップショットの保持期間（既定: 90日）
+	snapshotRetentionStr := getEnv("EVENT_SNAPSHOT_RETENTION", "2160h")
+	snapshotRetention, err := time.ParseDuration(snapshotRetentionStr)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid EVENT_SNAPSHOT_RETENTION: %w", err)
+	}
+	cfg.EventSnapshotRetention = snapshotRetention
+
+	// ゴミ箱に移したルールの保持期間（既定: 30日）
+	trashRetentionStr := getEnv("RULE_TRASH_RETENTION", "720h")
+	trashRetention, err := time.ParseDuration(trashRetentionStr)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid RULE_TRASH_RETENTION: %w", err)
+	}
+	if trashRetention <= 0 {
+		return cfg, fmt.Errorf("RULE_TRASH_RETENTION must be positive")
+	}
+	cfg.RuleTrashRetention = trashRetention
+
 	// セッションモード: develop=1分, production=3ヶ月
 	cfg.SessionMode = getEnv("SESSION_MODE", "production")
 	if cfg.SessionMode == "develop" {
@@ -78,10 +117,47 @@ func Load() (Config, error) {
 		cfg.SessionDuration = 90 * 24 * time.Hour // 3ヶ月
 	}
 
+	// アクセストークン（JWT）の有効期間。期限切れ後はリフレッシュCookieで再発行する
+	accessTokenTTLStr := getEnv("ACCESS_TOKEN_TTL", "15m")
+	accessTokenTTL, err := time.ParseDuration(accessTokenTTLStr)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid ACCESS_TOKEN_TTL: %w", err)
+	}
+	if accessTokenTTL <= 0 {
+		return cfg, fmt.Errorf("ACCESS_TOKEN_TTL must be positive")
+	}
+	cfg.AccessTokenTTL = accessTokenTTL
+
 	// CORS許可オリジン（カンマ区切り）
 	corsOrigins := getEnv("CORS_ALLOW_ORIGINS", "http://localhost:3000,http://127.0.0.1:3000")
 	cfg.CORSAllowOrigins = splitAndTrim(corsOrigins)
 
+	// 購読フィードURLの生成に使うAPIの公開URL
+	cfg.PublicBaseURL = strings.TrimRight(getEnv("PUBLIC_BASE_URL", "http://localhost:8080"), "/")
+
+	// 全体操作（スケジューラ手動実行・全ログ閲覧）を許可するDiscordユーザーID（カンマ区切り）
+	cfg.SystemAdminDiscordIDs = splitAndTrim(os.Getenv("SYSTEM_ADMIN_DISCORD_IDS"))
+
+	// 機密カラム暗号化のマスター鍵（"鍵ID:base64の32バイト鍵"のカンマ区切り）
+	cfg.EncryptionKeys = map[string][]byte{}
+	for i, entry := range splitAndTrim(os.Getenv("TOKEN_ENCRYPTION_KEYS")) {
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return cfg, fmt.Errorf("invalid TOKEN_ENCRYPTION_KEYS: entry %d must be keyId:base64key", i+1)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return cfg, fmt.Errorf("invalid TOKEN_ENCRYPTION_KEYS: key %q must be 32 bytes encoded in base64", id)
+		}
+		cfg.EncryptionKeys[id] = key
+		if i == 0 {
+			cfg.EncryptionPrimaryKeyID = id
+		}
+	}
+	if primary := os.Getenv("TOKEN_ENCRYPTION_PRIMARY_KEY_ID"); primary != "" {
+		cfg.EncryptionPrimaryKeyID = primary
+	}
+
 	if cfg.DatabaseURL == "" {
 		return cfg, fmt.Errorf("DATABASE_URL is required")
 	}
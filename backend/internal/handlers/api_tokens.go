@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"connpass-requirement/internal/models"
+	"connpass-requirement/internal/repository"
+	"connpass-requirement/internal/services"
+)
+
+const (
+	defaultAPITokenDays = 30
+	maxAPITokenDays     = 365
+	apiTokenUsesLimit   = 100
+)
+
+// APITokenHandler はパーソナルアクセストークンの管理API。
+// トークンの発行・失効はブラウザのセッションからのみ行える（トークン自身では呼び出せない）。
+type APITokenHandler struct {
+	tokens  *repository.APITokenRepository
+	service *services.APITokenService
+	logger  *services.LoggerService
+}
+
+func NewAPITokenHandler(tokens *repository.APITokenRepository, service *services.APITokenService, logger *services.LoggerService) *APITokenHandler {
+	return &APITokenHandler{tokens: tokens, service: service, logger: logger}
+}
+
+// RegisterAPITokenRoutes はアクセストークン関連ルートを登録する。
+func RegisterAPITokenRoutes(g *echo.Group, handler *APITokenHandler) {
+	g.GET("/me/tokens", handler.List)
+	g.POST("/me/tokens", handler.Create)
+	g.DELETE("/me/tokens/:id", handler.Revoke)
+	g.GET("/me/tokens/:id/uses", handler.ListUses)
+}
+
+type apiTokenPayload struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays"`
+}
+
+func (h *APITokenHandler) List(c echo.Context) error {
+	tokens, err := h.tokens.ListByUser(c.Request().Context(), MustUserID(c))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch tokens")
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// Create はトークンを発行する。トークン本体は発行時のレスポンスでのみ返す。
+func (h *APITokenHandler) Create(c echo.Context) error {
+	var payload apiTokenPayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid body")
+	}
+	payload.Name = strings.TrimSpace(payload.Name)
+	if payload.Name == "" || len(payload.Name) > 100 {
+		return echo.NewHTTPError(http.StatusBadRequest, "name is required (max 100 characters)")
+	}
+	if len(payload.Scopes) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "at least one scope is required")
+	}
+	scopes := make([]string, 0, len(payload.Scopes))
+	seen := make(map[string]struct{}, len(payload.Scopes))
+	for _, scope := range payload.Scopes {
+		if !models.IsValidAPITokenScope(scope) {
+			return echo.NewHTTPError(http.StatusBadRequest, "unknown scope: "+scope)
+		}
+		if _, ok := seen[scope]; ok {
+			continue
+		}
+		seen[scope] = struct{}{}
+		scopes = append(scopes, scope)
+	}
+	if payload.ExpiresInDays == 0 {
+		payload.ExpiresInDays = defaultAPITokenDays
+	}
+	if payload.ExpiresInDays < 1 || payload.ExpiresInDays > maxAPITokenDays {
+		return echo.NewHTTPError(http.StatusBadRequest, "expiresInDays must be between 1 and 365")
+	}
+
+	token := models.APIToken{
+		UserID:    MustUserID(c),
+		Name:      payload.Name,
+		Scopes:    scopes,
+		ExpiresAt: time.Now().Add(time.Duration(payload.ExpiresInDays) * 24 * time.Hour),
+	}
+	plaintext, err := h.service.Create(c.Request().Context(), &token)
+	if err != nil {
+		h.logger.Error(c.Request().Context(), "database_error", "アクセストークン発行に失敗", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create token")
+	}
+
+	return c.JSON(http.StatusCreated, map[string]any{
+		"token":     token,
+		"plaintext": plaintext,
+	})
+}
+
+func (h *APITokenHandler) Revoke(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid token id")
+	}
+	ok, err := h.tokens.Revoke(c.Request().Context(), MustUserID(c), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke token")
+	}
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "token not found")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListUses はトークンの利用履歴を新しい順に返す。
+func (h *APITokenHandler) ListUses(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid token id")
+	}
+	uses, err := h.tokens.ListUses(c.Request().Context(), MustUserID(c), id, apiTokenUsesLimit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch token uses")
+	}
+	return c.JSON(http.StatusOK, uses)
+}
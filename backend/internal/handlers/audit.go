@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"connpass-requirement/internal/models"
+	"connpass-requirement/internal/repository"
+)
+
+// AuditHandler はギルドの監査ログ閲覧API。
+type AuditHandler struct {
+	audits *repository.AuditRepository
+	authz  *Authorizer
+}
+
+func NewAuditHandler(audits *repository.AuditRepository, authz *Authorizer) *AuditHandler {
+	return &AuditHandler{audits: audits, authz: authz}
+}
+
+// RegisterAuditRoutes は監査ログ関連ルートを登録する。ギルドのadminロールが必要。
+func RegisterAuditRoutes(g *echo.Group, handler *AuditHandler) {
+	admin := handler.authz.RequireGuildRole(models.GuildRoleAdmin, GuildFromParam("guildId"))
+	g.GET("/guilds/:guildId/audit", handler.List, admin)
+}
+
+// List は監査ログを新しい順に返す。操作種別・実行者・対象・期間で絞り込める。
+func (h *AuditHandler) List(c echo.Context) error {
+	params := repository.AuditSearchParams{
+		GuildID:    c.Param("guildId"),
+		Action:     strings.TrimSpace(c.QueryParam("action")),
+		TargetType: strings.TrimSpace(c.QueryParam("target_type")),
+		TargetID:   strings.TrimSpace(c.QueryParam("target_id")),
+		Limit:      50,
+	}
+
+	if v := c.QueryParam("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > 200 {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and 200")
+		}
+		params.Limit = limit
+	}
+	if v := c.QueryParam("actor_id"); v != "" {
+		actorID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid actor_id")
+		}
+		params.ActorUserID = actorID
+	}
+
+	var err error
+	if params.Since, err = parseDateParam(c.QueryParam("since"), false); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid since")
+	}
+	if params.Until, err = parseDateParam(c.QueryParam("until"), true); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid until")
+	}
+	if v := c.QueryParam("cursor"); v != "" {
+		if params.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid cursor")
+		}
+	}
+
+	events, err := h.audits.Search(c.Request().Context(), params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch audit events")
+	}
+
+	resp := map[string]any{"events": events, "nextCursor": nil}
+	if len(events) == params.Limit {
+		resp["nextCursor"] = strconv.FormatInt(events[len(events)-1].ID, 10)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// newAuditEvent はリクエストの実行者・接続元を埋めた監査ログを組み立てる。
+func newAuditEvent(c echo.Context, guildID, action, targetType, targetID string) models.AuditEvent {
+	event := models.AuditEvent{
+		GuildID:    guildID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IPAddress:  c.RealIP(),
+		UserAgent:  c.Request().UserAgent(),
+	}
+	if userID := MustUserID(c); userID != 0 {
+		event.ActorUserID = &userID
+	}
+	if token := CurrentAPIToken(c); token != nil {
+		event.APITokenID = &token.ID
+	}
+	return event
+}
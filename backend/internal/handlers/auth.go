@@ -1,13 +1,11 @@
 package handlers
 
 import (
-	"context"
+	"errors"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 
 	"connpass-requirement/internal/config"
@@ -18,47 +16,152 @@ import (
 
 // AuthHandler はDiscord OAuth2コールバックを処理する。
 type AuthHandler struct {
-	cfg    config.Config
-	oauth  *services.OAuthService
-	users  *repository.UserRepository
-	logger *services.LoggerService
+	cfg       config.Config
+	oauth     *services.OAuthService
+	users     *repository.UserRepository
+	sessions  *repository.SessionRepository
+	issuer    *services.SessionService
+	guildSync *services.GuildSyncService
+	logger    *services.LoggerService
 }
 
 func NewAuthHandler(
 	cfg config.Config,
 	oauth *services.OAuthService,
 	users *repository.UserRepository,
+	sessions *repository.SessionRepository,
+	issuer *services.SessionService,
+	guildSync *services.GuildSyncService,
 	logger *services.LoggerService,
 ) *AuthHandler {
-	return &AuthHandler{cfg: cfg, oauth: oauth, users: users, logger: logger}
+	return &AuthHandler{cfg: cfg, oauth: oauth, users: users, sessions: sessions, issuer: issuer, guildSync: guildSync, logger: logger}
 }
 
+const (
+	sessionCookieName = "session"
+	refreshCookieName = "refresh_token"
+	// refreshCookiePath はリフレッシュCookieを送信する範囲。認証APIに限定する
+	refreshCookiePath = "/api/auth"
+	// oauthStateCookieName はログイン開始時のstateとPKCE verifierを保持するCookie
+	oauthStateCookieName = "oauth_state"
+)
+
 // RegisterAuthRoutes は認証系ルートを登録する。
 func RegisterAuthRoutes(g *echo.Group, handler *AuthHandler) {
+	g.GET("/auth/login", handler.HandleLogin)
 	g.POST("/auth/callback", handler.HandleCallback)
+	g.POST("/auth/refresh", handler.HandleRefresh)
 }
 
 // RegisterAuthRoutesWithMiddleware は認証が必要なルートを登録する。
 func RegisterAuthRoutesWithMiddleware(g *echo.Group, handler *AuthHandler) {
 	g.GET("/auth/me", handler.HandleMe)
 	g.POST("/auth/logout", handler.HandleLogout)
+	g.GET("/auth/sessions", handler.ListSessions)
+	g.DELETE("/auth/sessions", handler.RevokeAllSessions)
+	g.DELETE("/auth/sessions/:id", handler.RevokeSession)
 }
 
-// HandleLogout はログアウト処理を行う。
-func (h *AuthHandler) HandleLogout(c echo.Context) error {
-	cookie := &http.Cookie{
-		Name:     "session",
-		Value:    "",
-		Path:     "/",
+// HandleLogin はstateとPKCEを発行し、Discordの認可画面へリダイレクトする。
+func (h *AuthHandler) HandleLogin(c echo.Context) error {
+	login, err := h.oauth.BeginLogin()
+	if err != nil {
+		h.logger.Error(c.Request().Context(), "auth_error", "ログイン開始に失敗", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to start login")
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     oauthStateCookieName,
+		Value:    login.StateToken,
+		Path:     refreshCookiePath,
 		Secure:   true,
 		HttpOnly: true,
 		SameSite: http.SameSiteNoneMode,
-		MaxAge:   -1,
+		Expires:  login.ExpiresAt,
+	})
+	return c.Redirect(http.StatusFound, login.AuthorizeURL)
+}
+
+// HandleLogout は現在のセッションを失効させ、Cookieを削除する。
+func (h *AuthHandler) HandleLogout(c echo.Context) error {
+	if _, err := h.sessions.Revoke(c.Request().Context(), MustUserID(c), MustSessionID(c)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke session")
 	}
-	c.SetCookie(cookie)
+	h.clearSessionCookies(c)
 	return c.JSON(http.StatusOK, map[string]string{"message": "logged out"})
 }
 
+// HandleRefresh はリフレッシュCookieをローテーションし、アクセストークンを再発行する。
+func (h *AuthHandler) HandleRefresh(c echo.Context) error {
+	cookie, err := c.Cookie(refreshCookieName)
+	if err != nil || cookie.Value == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "missing refresh token")
+	}
+
+	tokens, err := h.issuer.Refresh(c.Request().Context(), cookie.Value)
+	if err != nil {
+		h.clearSessionCookies(c)
+		switch {
+		case errors.Is(err, services.ErrRefreshTokenReused):
+			h.logger.Warn(c.Request().Context(), "auth_refresh_reused", "使用済みのリフレッシュトークンが再提示されたためセッションを失効", nil)
+			return echo.NewHTTPError(http.StatusUnauthorized, "session revoked")
+		case errors.Is(err, services.ErrSessionInvalid):
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid refresh token")
+		default:
+			h.logger.Error(c.Request().Context(), "auth_error", "アクセストークン再発行に失敗", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to refresh session")
+		}
+	}
+
+	h.setSessionCookies(c, tokens)
+	return c.JSON(http.StatusOK, map[string]string{"token": tokens.AccessToken})
+}
+
+// ListSessions はログイン中のセッション一覧を返す。
+func (h *AuthHandler) ListSessions(c echo.Context) error {
+	sessions, err := h.sessions.ListActiveByUser(c.Request().Context(), MustUserID(c))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch sessions")
+	}
+	current := MustSessionID(c)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+	return c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession は指定したセッションを失効させる。現在のセッションの場合はCookieも削除する。
+func (h *AuthHandler) RevokeSession(c echo.Context) error {
+	id := c.Param("id")
+	ok, err := h.sessions.Revoke(c.Request().Context(), MustUserID(c), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke session")
+	}
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "session not found")
+	}
+	if id == MustSessionID(c) {
+		h.clearSessionCookies(c)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RevokeAllSessions は全セッションを失効させる。keep_current=trueの場合は現在のセッションを残す。
+func (h *AuthHandler) RevokeAllSessions(c echo.Context) error {
+	keepCurrent := c.QueryParam("keep_current") == "true"
+	except := ""
+	if keepCurrent {
+		except = MustSessionID(c)
+	}
+	revoked, err := h.sessions.RevokeAll(c.Request().Context(), MustUserID(c), except)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke sessions")
+	}
+	if !keepCurrent {
+		h.clearSessionCookies(c)
+	}
+	return c.JSON(http.StatusOK, map[string]int64{"revoked": revoked})
+}
+
 // HandleMe は現在のユーザー情報を返す。
 func (h *AuthHandler) HandleMe(c echo.Context) error {
 	userID := MustUserID(c)
@@ -83,7 +186,8 @@ func (h *AuthHandler) HandleMe(c echo.Context) error {
 }
 
 type authCallbackRequest struct {
-	Code string `json:"code"`
+	Code  string `json:"code"`
+	State string `json:"state"`
 }
 
 type authCallbackResponse struct {
@@ -102,7 +206,27 @@ func (h *AuthHandler) HandleCallback(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "code is required")
 	}
 
-	token, err := h.oauth.ExchangeCode(c.Request().Context(), req.Code)
+	// stateは1回限り有効なため、検証結果に関わらずCookieを削除する
+	var stateToken string
+	if cookie, err := c.Cookie(oauthStateCookieName); err == nil {
+		stateToken = cookie.Value
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     oauthStateCookieName,
+		Value:    "",
+		Path:     refreshCookiePath,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+		MaxAge:   -1,
+	})
+	verifier, err := h.oauth.VerifyState(stateToken, req.State)
+	if err != nil {
+		h.logger.Error(c.Request().Context(), "auth_error", "OAuth stateの検証に失敗", map[string]any{"reason": err.Error()})
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid oauth state")
+	}
+
+	token, err := h.oauth.ExchangeCode(c.Request().Context(), req.Code, verifier)
 	if err != nil {
 		h.logger.Error(c.Request().Context(), "auth_error", "Discordトークン取得に失敗", err)
 		return echo.NewHTTPError(http.StatusBadGateway, "failed to exchange discord token")
@@ -128,55 +252,67 @@ func (h *AuthHandler) HandleCallback(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save user")
 	}
 
-	guildPerms := make([]models.GuildPermission, 0, len(guilds))
-	for _, guild := range guilds {
-		permValue, _ := strconv.ParseInt(guild.Permissions, 10, 64)
-		guildPerms = append(guildPerms, models.GuildPermission{
-			GuildID:       guild.ID,
-			GuildName:     guild.Name,
-			IconURL:       buildGuildIconURL(guild.ID, guild.Icon),
-			Permissions:   permValue,
-			CanManage:     hasPermission(permValue, 0x8) || hasPermission(permValue, 0x20),
-			CanManageRole: hasPermission(permValue, 0x20),
-		})
-	}
+	guildPerms := h.guildSync.Permissions(c.Request().Context(), user.ID, identity.ID, guilds)
 
 	if err := h.users.SaveGuildPermissions(c.Request().Context(), user.ID, guildPerms); err != nil {
 		h.logger.Error(c.Request().Context(), "database_error", "ギルド権限保存に失敗", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save guild permissions")
 	}
 
-	jwtToken, err := h.issueJWT(c.Request().Context(), user)
+	tokens, err := h.issuer.Issue(c.Request().Context(), user, c.Request().UserAgent(), c.RealIP())
 	if err != nil {
-		h.logger.Error(c.Request().Context(), "auth_error", "JWT発行に失敗", err)
+		h.logger.Error(c.Request().Context(), "auth_error", "セッション発行に失敗", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to issue token")
 	}
+	h.setSessionCookies(c, tokens)
+
+	return c.JSON(http.StatusOK, authCallbackResponse{
+		Token:  tokens.AccessToken,
+		User:   user,
+		Guilds: guildPerms,
+	})
+}
 
-	cookie := &http.Cookie{
-		Name:     "session",
-		Value:    jwtToken,
+func (h *AuthHandler) setSessionCookies(c echo.Context, tokens *services.SessionTokens) {
+	c.SetCookie(&http.Cookie{
+		Name:     sessionCookieName,
+		Value:    tokens.AccessToken,
 		Path:     "/",
 		Secure:   true,
 		HttpOnly: true,
 		SameSite: http.SameSiteNoneMode,
-		Expires:  time.Now().Add(h.cfg.SessionDuration),
+		Expires:  time.Now().Add(h.issuer.AccessTokenTTL()),
+	})
+	// 同時リフレッシュの猶予内では、先の応答で設定されたリフレッシュCookieを上書きしない
+	if tokens.RefreshToken == "" {
+		return
 	}
-	c.SetCookie(cookie)
-
-	return c.JSON(http.StatusOK, authCallbackResponse{
-		Token:  jwtToken,
-		User:   user,
-		Guilds: guildPerms,
+	c.SetCookie(&http.Cookie{
+		Name:     refreshCookieName,
+		Value:    tokens.RefreshToken,
+		Path:     refreshCookiePath,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+		Expires:  tokens.Session.ExpiresAt,
 	})
 }
 
-func (h *AuthHandler) issueJWT(ctx context.Context, user models.User) (string, error) {
-	claims := jwt.MapClaims{
-		"sub":             user.ID,
-		"discord_user_id": user.DiscordUserID,
-		"exp":             time.Now().Add(h.cfg.SessionDuration).Unix(),
+func (h *AuthHandler) clearSessionCookies(c echo.Context) {
+	for _, cookie := range []struct{ name, path string }{
+		{sessionCookieName, "/"},
+		{refreshCookieName, refreshCookiePath},
+	} {
+		c.SetCookie(&http.Cookie{
+			Name:     cookie.name,
+			Value:    "",
+			Path:     cookie.path,
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteNoneMode,
+			MaxAge:   -1,
+		})
 	}
-	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(h.cfg.JWTSecret))
 }
 
 func buildAvatarURL(userID, avatar string) string {
@@ -185,14 +321,3 @@ func buildAvatarURL(userID, avatar string) string {
 	}
 	return "https://cdn.discordapp.com/avatars/" + userID + "/" + avatar + ".png"
 }
-
-func buildGuildIconURL(guildID, icon string) string {
-	if icon == "" {
-		return ""
-	}
-	return "https://cdn.discordapp.com/icons/" + guildID + "/" + icon + ".png"
-}
-
-func hasPermission(value int64, bit int64) bool {
-	return value&bit == bit
-}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"connpass-requirement/internal/models"
+	"connpass-requirement/internal/policy"
+)
+
+const (
+	contextKeyGuildRole contextKey = "guildRole"
+	contextKeyRule      contextKey = "rule"
+)
+
+// GuildResolver はリクエストから認可対象のギルドIDを特定する。
+type GuildResolver func(c echo.Context) (string, error)
+
+// Authorizer はpolicyの判断をHTTPのエラーとミドルウェアへ変換する。
+type Authorizer struct {
+	policy *policy.Policy
+}
+
+func NewAuthorizer(p *policy.Policy) *Authorizer {
+	return &Authorizer{policy: p}
+}
+
+// Role はユーザーのギルドでの実効ロールを返す。ギルドに所属していない場合は空文字。
+func (a *Authorizer) Role(ctx context.Context, userID int64, guildID string) (string, error) {
+	return a.policy.GuildRole(ctx, userID, guildID)
+}
+
+// AttachRoles はギルド一覧の各要素へ実効ロールを設定する。
+func (a *Authorizer) AttachRoles(ctx context.Context, guilds []models.GuildPermission) error {
+	return a.policy.AttachRoles(ctx, guilds)
+}
+
+// Require はユーザーがギルドでrequired以上のロールを持つか検証する。
+func (a *Authorizer) Require(c echo.Context, guildID, required string) error {
+	if guildID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "guildId is required")
+	}
+	role, err := a.policy.RequireGuildRole(c.Request().Context(), MustUserID(c), guildID, required)
+	if err != nil {
+		return policyError(err, "failed to verify guild")
+	}
+	c.Set(string(contextKeyGuildRole), role)
+	return nil
+}
+
+// RequireSystemAdmin はシステム管理者であることを検証する。
+func (a *Authorizer) RequireSystemAdmin(c echo.Context) error {
+	if err := a.policy.RequireSystemAdmin(c.Request().Context(), MustUserID(c)); err != nil {
+		return policyError(err, "failed to verify user")
+	}
+	return nil
+}
+
+// IsSystemAdmin はシステム管理者か判定する。
+func (a *Authorizer) IsSystemAdmin(c echo.Context) (bool, error) {
+	ok, err := a.policy.IsSystemAdmin(c.Request().Context(), MustUserID(c))
+	if err != nil {
+		return false, echo.NewHTTPError(http.StatusInternalServerError, "failed to verify user")
+	}
+	return ok, nil
+}
+
+// LogScope はユーザーが閲覧できるログのギルド範囲を返す。
+func (a *Authorizer) LogScope(c echo.Context, guildID string) ([]string, bool, error) {
+	guildIDs, all, err := a.policy.LogScope(c.Request().Context(), MustUserID(c), guildID)
+	if err != nil {
+		return nil, false, policyError(err, "failed to verify guild")
+	}
+	return guildIDs, all, nil
+}
+
+// RequireGuildRole はresolveで特定したギルドでrequired以上のロールを要求するミドルウェア。
+func (a *Authorizer) RequireGuildRole(required string, resolve GuildResolver) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			guildID, err := resolve(c)
+			if err != nil {
+				return err
+			}
+			if err := a.Require(c, guildID, required); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// GuildFromParam はパスパラメータからギルドIDを取り出す。
+func GuildFromParam(name string) GuildResolver {
+	return func(c echo.Context) (string, error) {
+		return c.Param(name), nil
+	}
+}
+
+// GuildFromQuery はクエリパラメータからギルドIDを取り出す。
+func GuildFromQuery(name string) GuildResolver {
+	return func(c echo.Context) (string, error) {
+		return c.QueryParam(name), nil
+	}
+}
+
+// Rule はルールを読み込み、ユーザーがそのギルドでrequired以上のロールを持つか検証する。
+// ギルドに所属していない場合はルールの存在を明かさず404を返す。
+func (a *Authorizer) Rule(c echo.Context, ruleID int64, required string) (*models.Rule, error) {
+	rule, role, err := a.policy.Rule(c.Request().Context(), MustUserID(c), ruleID, required)
+	if err != nil {
+		if errors.Is(err, policy.ErrNotFound) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "rule not found")
+		}
+		return nil, policyError(err, "failed to fetch rule")
+	}
+	c.Set(string(contextKeyRule), rule)
+	c.Set(string(contextKeyGuildRole), role)
+	return rule, nil
+}
+
+// RequireRuleRole はパスのルールIDのルールについてrequired以上のロールを要求するミドルウェア。
+// 読み込んだルールはMustRuleで取り出せる。
+func (a *Authorizer) RequireRuleRole(required, param string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ruleID, err := strconv.ParseInt(c.Param(param), 10, 64)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+			}
+			if _, err := a.Rule(c, ruleID, required); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// MustRule はGuildFromRuleで読み込んだルールを取り出す。
+func MustRule(c echo.Context) *models.Rule {
+	if v, ok := c.Get(string(contextKeyRule)).(*models.Rule); ok {
+		return v
+	}
+	return nil
+}
+
+// MustGuildRole は認可済みのギルドでの実効ロールを取り出す。
+func MustGuildRole(c echo.Context) string {
+	if v, ok := c.Get(string(contextKeyGuildRole)).(string); ok {
+		return v
+	}
+	return ""
+}
+
+// policyError はpolicyのエラーをHTTPエラーへ変換する。想定外のエラーはfallbackの500にする。
+func policyError(err error, fallback string) error {
+	switch {
+	case errors.Is(err, policy.ErrNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "not found")
+	case errors.Is(err, policy.ErrGuildAccessDenied):
+		return echo.NewHTTPError(http.StatusForbidden, "guild access denied")
+	case errors.Is(err, policy.ErrInsufficientRole):
+		return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
+	case errors.Is(err, policy.ErrSystemAdminRequired):
+		return echo.NewHTTPError(http.StatusForbidden, "system admin required")
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, fallback)
+	}
+}
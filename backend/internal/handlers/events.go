@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"connpass-requirement/internal/models"
+	"connpass-requirement/internal/repository"
+)
+
+// EventHandler はイベントキャッシュ参照API。
+type EventHandler struct {
+	events *repository.EventRepository
+	authz  *Authorizer
+}
+
+func NewEventHandler(events *repository.EventRepository, authz *Authorizer) *EventHandler {
+	return &EventHandler{events: events, authz: authz}
+}
+
+// RegisterEventRoutes はイベント関連ルートを登録する。
+func RegisterEventRoutes(g *echo.Group, handler *EventHandler) {
+	g.GET("/events", handler.Search)
+	g.GET("/events/:eventId/history", handler.History)
+}
+
+// History はイベントの申込状況の推移を返す。所属ギルドのルールが一致したイベントのみ参照できる。
+func (h *EventHandler) History(c echo.Context) error {
+	userID := MustUserID(c)
+	eventID, err := strconv.ParseInt(c.Param("eventId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid eventId")
+	}
+
+	visible, err := h.events.IsVisibleToUser(c.Request().Context(), eventID, userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to verify event access")
+	}
+	if !visible {
+		return echo.NewHTTPError(http.StatusNotFound, "event not found")
+	}
+
+	event, err := h.events.FindByEventID(c.Request().Context(), eventID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch event")
+	}
+
+	snapshots, err := h.events.ListSnapshots(c.Request().Context(), eventID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch event history")
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"event":     event,
+		"snapshots": snapshots,
+	})
+}
+
+// Search はイベントキャッシュをキーワード・開催日・参加率・一致ルールで検索する。
+// rule_idを指定しない場合は、所属ギルドのルールが一致したイベントのみを対象にする。
+func (h *EventHandler) Search(c echo.Context) error {
+	params := repository.EventSearchParams{
+		Query: strings.TrimSpace(c.QueryParam("q")),
+		Limit: 20,
+	}
+
+	if v := c.QueryParam("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > 100 {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and 100")
+		}
+		params.Limit = limit
+	}
+
+	var err error
+	if params.From, err = parseDateParam(c.QueryParam("from"), false); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid from")
+	}
+	if params.To, err = parseDateParam(c.QueryParam("to"), true); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid to")
+	}
+	if params.MinFill, err = parseFillParam(c.QueryParam("min_fill")); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "min_fill must be between 0 and 100")
+	}
+	if params.MaxFill, err = parseFillParam(c.QueryParam("max_fill")); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "max_fill must be between 0 and 100")
+	}
+
+	if v := c.QueryParam("rule_id"); v != "" {
+		ruleID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid rule_id")
+		}
+		if _, err := h.authz.Rule(c, ruleID, models.GuildRoleViewer); err != nil {
+			return err
+		}
+		params.RuleID = ruleID
+	} else {
+		params.VisibleToUserID = MustUserID(c)
+	}
+
+	if v := c.QueryParam("cursor"); v != "" {
+		if params.AfterTime, params.AfterID, err = decodeEventCursor(v); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid cursor")
+		}
+	}
+
+	events, err := h.events.Search(c.Request().Context(), params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to search events")
+	}
+
+	resp := map[string]any{"events": events, "nextCursor": nil}
+	if len(events) == params.Limit {
+		last := events[len(events)-1]
+		resp["nextCursor"] = encodeEventCursor(last.StartedAt, last.EventID)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// parseDateParam はRFC3339またはYYYY-MM-DD(JST)を解釈する。endOfDayがtrueの場合、日付指定は翌日0時を返す。
+func parseDateParam(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.FixedZone("JST", 9*60*60))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// parseFillParam は参加率（0〜100）を読み取る。未指定の場合はnil。
+func parseFillParam(value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	if v < 0 || v > 100 {
+		return nil, fmt.Errorf("out of range")
+	}
+	return &v, nil
+}
+
+func encodeEventCursor(startedAt time.Time, eventID int64) string {
+	raw := fmt.Sprintf("%d:%d", startedAt.UnixNano(), eventID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeEventCursor(cursor string) (time.Time, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return time.Time{}, 0, fmt.Errorf("malformed cursor")
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	eventID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	return time.Unix(0, nanos).UTC(), eventID, nil
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"connpass-requirement/internal/models"
+	"connpass-requirement/internal/repository"
+	"connpass-requirement/internal/services"
+)
+
+// feedLookback は購読フィードに含める過去イベントの期間。
+const feedLookback = 30 * 24 * time.Hour
+
+// FeedHandler は購読フィードの配信とトークン発行API。
+type FeedHandler struct {
+	tokens        *repository.FeedTokenRepository
+	events        *repository.EventRepository
+	rules         *repository.RuleRepository
+	users         *repository.UserRepository
+	authz         *Authorizer
+	notifications *repository.NotificationRepository
+	logger        *services.LoggerService
+	audit         *services.AuditService
+	baseURL       string
+}
+
+func NewFeedHandler(tokens *repository.FeedTokenRepository, events *repository.EventRepository, rules *repository.RuleRepository, users *repository.UserRepository, authz *Authorizer, notifications *repository.NotificationRepository, logger *services.LoggerService, audit *services.AuditService, baseURL string) *FeedHandler {
+	return &FeedHandler{tokens: tokens, events: events, rules: rules, users: users, authz: authz, notifications: notifications, logger: logger, audit: audit, baseURL: baseURL}
+}
+
+// RegisterFeedTokenRoutes はフィードトークン管理ルートを登録する。
+func RegisterFeedTokenRoutes(g *echo.Group, handler *FeedHandler) {
+	ruleEditor := handler.authz.RequireRuleRole(models.GuildRoleEditor, "id")
+	// ギルドのトークンはメンバー全員の購読URLで共有されるため、発行・無効化はadminに限る
+	guildAdmin := handler.authz.RequireGuildRole(models.GuildRoleAdmin, GuildFromParam("guildId"))
+
+	g.POST("/rules/:id/feed-token", handler.RotateRuleToken, ruleEditor)
+	g.DELETE("/rules/:id/feed-token", handler.RevokeRuleToken, ruleEditor)
+	g.POST("/guilds/:guildId/feed-token", handler.RotateGuildToken, guildAdmin)
+	g.DELETE("/guilds/:guildId/feed-token", handler.RevokeGuildToken, guildAdmin)
+}
+
+// RegisterFeedRoutes はトークン認証の購読フィードルートを登録する。
+func RegisterFeedRoutes(e *echo.Echo, handler *FeedHandler) {
+	e.GET("/ical/rules/:file", handler.RuleICal)
+	e.GET("/ical/guilds/:file", handler.GuildICal)
+	e.GET("/atom/rules/:file", handler.RuleAtom)
+}
+
+func (h *FeedHandler) RotateRuleToken(c echo.Context) error {
+	rule := MustRule(c)
+	token, err := h.rotate(c, models.FeedScopeRule, &rule.ID, "")
+	if err != nil {
+		return err
+	}
+	h.recordAudit(c, rule.GuildID, models.AuditActionFeedTokenRotate, models.FeedScopeRule, strconv.FormatInt(rule.ID, 10))
+	return c.JSON(http.StatusCreated, h.feedURLs(models.FeedScopeRule, strconv.FormatInt(rule.ID, 10), token))
+}
+
+func (h *FeedHandler) RevokeRuleToken(c echo.Context) error {
+	rule := MustRule(c)
+	if err := h.tokens.Revoke(c.Request().Context(), models.FeedScopeRule, &rule.ID, ""); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke feed token")
+	}
+	h.recordAudit(c, rule.GuildID, models.AuditActionFeedTokenRevoke, models.FeedScopeRule, strconv.FormatInt(rule.ID, 10))
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *FeedHandler) RotateGuildToken(c echo.Context) error {
+	guildID := c.Param("guildId")
+	token, err := h.rotate(c, models.FeedScopeGuild, nil, guildID)
+	if err != nil {
+		return err
+	}
+	h.recordAudit(c, guildID, models.AuditActionFeedTokenRotate, models.FeedScopeGuild, guildID)
+	return c.JSON(http.StatusCreated, h.feedURLs(models.FeedScopeGuild, guildID, token))
+}
+
+func (h *FeedHandler) RevokeGuildToken(c echo.Context) error {
+	guildID := c.Param("guildId")
+	if err := h.tokens.Revoke(c.Request().Context(), models.FeedScopeGuild, nil, guildID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke feed token")
+	}
+	h.recordAudit(c, guildID, models.AuditActionFeedTokenRevoke, models.FeedScopeGuild, guildID)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RuleICal はルールが一致したイベントのiCalendarを返す。
+func (h *FeedHandler) RuleICal(c echo.Context) error {
+	rule, err := h.authorizeRuleFeed(c, ".ics")
+	if err != nil {
+		return err
+	}
+
+	events, err := h.events.ListMatchedByRule(c.Request().Context(), rule.ID, time.Now().Add(-feedLookback))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch events")
+	}
+
+	return c.Blob(http.StatusOK, "text/calendar; charset=utf-8", services.BuildICalendar(rule.Name, events))
+}
+
+// RuleAtom はルールが一致したイベントと通知履歴のAtomフィードを返す。
+func (h *FeedHandler) RuleAtom(c echo.Context) error {
+	rule, err := h.authorizeRuleFeed(c, ".xml")
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	events, err := h.events.ListMatchedByRule(ctx, rule.ID, time.Now().Add(-feedLookback))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch events")
+	}
+	notifications, err := h.notifications.ListByRule(ctx, rule.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch notifications")
+	}
+
+	selfURL := fmt.Sprintf("%s/atom/rules/%d.xml", h.baseURL, rule.ID)
+	body, err := services.BuildAtomFeed(*rule, selfURL, events, notifications)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to build feed")
+	}
+
+	return c.Blob(http.StatusOK, "application/atom+xml; charset=utf-8", body)
+}
+
+// authorizeRuleFeed はパス中のルールIDとトークンのフィードが一致するか検証する。
+func (h *FeedHandler) authorizeRuleFeed(c echo.Context, ext string) (*models.Rule, error) {
+	file := c.Param("file")
+	ruleID, err := strconv.ParseInt(strings.TrimSuffix(file, ext), 10, 64)
+	if err != nil || !strings.HasSuffix(file, ext) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "feed not found")
+	}
+	feed, err := h.authorizeFeed(c)
+	if err != nil {
+		return nil, err
+	}
+	if feed.Scope != models.FeedScopeRule || feed.RuleID == nil || *feed.RuleID != ruleID {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "feed not found")
+	}
+
+	rule, err := h.rules.Get(c.Request().Context(), ruleID)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch rule")
+	}
+	if rule == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "feed not found")
+	}
+	return rule, nil
+}
+
+// GuildICal はギルド内のルールが一致したイベントのiCalendarを返す。
+func (h *FeedHandler) GuildICal(c echo.Context) error {
+	guildID := strings.TrimSuffix(c.Param("file"), ".ics")
+	if guildID == "" || guildID == c.Param("file") {
+		return echo.NewHTTPError(http.StatusNotFound, "feed not found")
+	}
+	feed, err := h.authorizeFeed(c)
+	if err != nil {
+		return err
+	}
+	if feed.Scope != models.FeedScopeGuild || feed.GuildID != guildID {
+		return echo.NewHTTPError(http.StatusNotFound, "feed not found")
+	}
+
+	ctx := c.Request().Context()
+	events, err := h.events.ListMatchedByGuild(ctx, guildID, time.Now().Add(-feedLookback))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch events")
+	}
+
+	name, err := h.users.FindGuildName(ctx, guildID)
+	if err != nil || name == "" {
+		name = guildID
+	}
+
+	return c.Blob(http.StatusOK, "text/calendar; charset=utf-8", services.BuildICalendar("connpass - "+name, events))
+}
+
+// authorizeFeed はクエリのトークンからフィードを特定する。不正なトークンは存在しないフィードとして扱う。
+func (h *FeedHandler) authorizeFeed(c echo.Context) (*models.FeedToken, error) {
+	token := c.QueryParam("token")
+	if token == "" {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "feed not found")
+	}
+	feed, err := h.tokens.FindByHash(c.Request().Context(), services.HashFeedToken(token))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to verify feed token")
+	}
+	if feed == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "feed not found")
+	}
+	return feed, nil
+}
+
+func (h *FeedHandler) rotate(c echo.Context, scope string, ruleID *int64, guildID string) (string, error) {
+	userID := MustUserID(c)
+	token, hash, err := services.GenerateFeedToken()
+	if err != nil {
+		return "", echo.NewHTTPError(http.StatusInternalServerError, "failed to generate feed token")
+	}
+	feed := models.FeedToken{Scope: scope, RuleID: ruleID, GuildID: guildID, TokenHash: hash, CreatedBy: &userID}
+	if err := h.tokens.Rotate(c.Request().Context(), &feed); err != nil {
+		h.logger.Error(c.Request().Context(), "database_error", "フィードトークンの発行に失敗", err)
+		return "", echo.NewHTTPError(http.StatusInternalServerError, "failed to issue feed token")
+	}
+	return token, nil
+}
+
+// recordAudit はフィードトークンの発行・無効化を監査ログに記録する。トークン自体は記録しない。
+// 対象IDはルール用ならルールID、ギルド用ならギルドID。
+func (h *FeedHandler) recordAudit(c echo.Context, guildID, action, scope, targetID string) {
+	event := newAuditEvent(c, guildID, action, models.AuditTargetFeedToken, targetID)
+	state := map[string]any{"scope": scope}
+	if action == models.AuditActionFeedTokenRevoke {
+		h.audit.Record(c.Request().Context(), event, state, nil)
+		return
+	}
+	h.audit.Record(c.Request().Context(), event, nil, state)
+}
+
+// feedURLs は購読用URLを組み立てる。トークンは発行時のみ返却される。
+func (h *FeedHandler) feedURLs(scope, id, token string) map[string]string {
+	urls := map[string]string{
+		"token":   token,
+		"icalUrl": fmt.Sprintf("%s/ical/%ss/%s.ics?token=%s", h.baseURL, scope, id, token),
+	}
+	if scope == models.FeedScopeRule {
+		urls["atomUrl"] = fmt.Sprintf("%s/atom/rules/%s.xml?token=%s", h.baseURL, id, token)
+	}
+	return urls
+}
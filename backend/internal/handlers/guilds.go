@@ -16,17 +16,21 @@ import (
 // GuildHandler はギルド／チャンネル関連API。
 type GuildHandler struct {
 	users   *repository.UserRepository
+	authz   *Authorizer
 	discord *services.DiscordService
+	audit   *services.AuditService
 }
 
-func NewGuildHandler(users *repository.UserRepository, discord *services.DiscordService) *GuildHandler {
-	return &GuildHandler{users: users, discord: discord}
+func NewGuildHandler(users *repository.UserRepository, authz *Authorizer, discord *services.DiscordService, audit *services.AuditService) *GuildHandler {
+	return &GuildHandler{users: users, authz: authz, discord: discord, audit: audit}
 }
 
 func RegisterGuildRoutes(g *echo.Group, handler *GuildHandler) {
+	editor := handler.authz.RequireGuildRole(models.GuildRoleEditor, GuildFromParam("guildId"))
+
 	g.GET("/me/guilds", handler.ListGuilds)
-	g.GET("/guilds/:guildId/channels", handler.ListChannels)
-	g.POST("/guilds/:guildId/channels", handler.CreateChannel)
+	g.GET("/guilds/:guildId/channels", handler.ListChannels, editor)
+	g.POST("/guilds/:guildId/channels", handler.CreateChannel, editor)
 }
 
 func (h *GuildHandler) ListGuilds(c echo.Context) error {
@@ -48,6 +52,9 @@ func (h *GuildHandler) ListGuilds(c echo.Context) error {
 		}
 		guilds = filtered
 	}
+	if err := h.authz.AttachRoles(c.Request().Context(), guilds); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to resolve guild roles")
+	}
 	return c.JSON(http.StatusOK, guilds)
 }
 
@@ -55,20 +62,7 @@ func (h *GuildHandler) ListChannels(c echo.Context) error {
 	if h.discord == nil {
 		return echo.NewHTTPError(http.StatusServiceUnavailable, "discord integration is disabled")
 	}
-	userID := MustUserID(c)
 	guildID := c.Param("guildId")
-	if guildID == "" {
-		return echo.NewHTTPError(http.StatusBadRequest, "guildId is required")
-	}
-
-	guilds, err := h.users.ListGuildPermissions(c.Request().Context(), userID)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to verify guild access")
-	}
-
-	if !canManageGuild(guilds, guildID) {
-		return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
-	}
 
 	textChannels, categories, err := h.discord.ListTextChannelsWithCategories(c.Request().Context(), guildID)
 	if err != nil {
@@ -123,11 +117,7 @@ func (h *GuildHandler) CreateChannel(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusServiceUnavailable, "discord integration is disabled")
 	}
 
-	userID := MustUserID(c)
 	guildID := c.Param("guildId")
-	if strings.TrimSpace(guildID) == "" {
-		return echo.NewHTTPError(http.StatusBadRequest, "guildId is required")
-	}
 
 	var req struct {
 		Name         string `json:"name"`
@@ -144,17 +134,9 @@ func (h *GuildHandler) CreateChannel(c echo.Context) error {
 	categoryID := strings.TrimSpace(req.CategoryID)
 	categoryName := strings.TrimSpace(req.CategoryName)
 
-	guilds, err := h.users.ListGuildPermissions(c.Request().Context(), userID)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to verify guild access")
-	}
-
-	if !canManageGuild(guilds, guildID) {
-		return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
-	}
-
 	var createdCategory *discordgo.Channel
 	if categoryName != "" {
+		var err error
 		createdCategory, err = h.discord.CreateCategory(c.Request().Context(), guildID, categoryName)
 		if err != nil {
 			if errors.Is(err, services.ErrMissingAccess) {
@@ -187,15 +169,7 @@ func (h *GuildHandler) CreateChannel(c echo.Context) error {
 			"name": createdCategory.Name,
 		}
 	}
+	h.audit.Record(c.Request().Context(), newAuditEvent(c, guildID, models.AuditActionChannelCreate, models.AuditTargetChannel, channel.ID), nil, resp)
 
 	return c.JSON(http.StatusCreated, resp)
 }
-
-func canManageGuild(guilds []models.GuildPermission, guildID string) bool {
-	for _, guild := range guilds {
-		if guild.GuildID == guildID {
-			return guild.CanManage
-		}
-	}
-	return false
-}
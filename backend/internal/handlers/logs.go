@@ -11,11 +11,12 @@ import (
 
 // LogHandler は重要ログ一覧API。
 type LogHandler struct {
-	logs *repository.LogRepository
+	logs  *repository.LogRepository
+	authz *Authorizer
 }
 
-func NewLogHandler(logs *repository.LogRepository) *LogHandler {
-	return &LogHandler{logs: logs}
+func NewLogHandler(logs *repository.LogRepository, authz *Authorizer) *LogHandler {
+	return &LogHandler{logs: logs, authz: authz}
 }
 
 // RegisterLogRoutes はログ関連ルートを登録。
@@ -23,6 +24,7 @@ func RegisterLogRoutes(g *echo.Group, handler *LogHandler) {
 	g.GET("/logs", handler.List)
 }
 
+// List は閲覧できるギルドのログを返す。ギルドを特定できないログはシステム管理者のみ参照できる。
 func (h *LogHandler) List(c echo.Context) error {
 	limitStr := c.QueryParam("limit")
 	limit := 50
@@ -32,7 +34,12 @@ func (h *LogHandler) List(c echo.Context) error {
 		}
 	}
 
-	logs, err := h.logs.ListRecent(c.Request().Context(), limit)
+	guildIDs, all, err := h.authz.LogScope(c, c.QueryParam("guild_id"))
+	if err != nil {
+		return err
+	}
+
+	logs, err := h.logs.ListRecent(c.Request().Context(), limit, guildIDs, all)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch logs")
 	}
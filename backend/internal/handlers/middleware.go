@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
@@ -8,6 +9,10 @@ import (
 	"github.com/labstack/echo/v4"
 
 	"connpass-requirement/internal/config"
+	"connpass-requirement/internal/models"
+	"connpass-requirement/internal/policy"
+	"connpass-requirement/internal/repository"
+	"connpass-requirement/internal/services"
 )
 
 type contextKey string
@@ -15,16 +20,23 @@ type contextKey string
 const (
 	contextKeyUserID      contextKey = "userID"
 	contextKeyDiscordUser contextKey = "discordUserID"
+	contextKeySessionID   contextKey = "sessionID"
+	contextKeyAPIToken    contextKey = "apiToken"
 )
 
 // JWTMiddleware はJWTを検証し、ユーザー情報をContextに格納する。
-func JWTMiddleware(cfg config.Config) echo.MiddlewareFunc {
+// JWTのjtiに対応するセッションが失効・期限切れの場合は拒否する。
+// パーソナルアクセストークンが提示された場合はスコープを確認し、利用履歴を記録する。
+func JWTMiddleware(cfg config.Config, sessions *repository.SessionRepository, apiTokens *services.APITokenService) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			tokenString := extractToken(c)
 			if tokenString == "" {
 				return echo.NewHTTPError(http.StatusUnauthorized, "missing token")
 			}
+			if services.IsAPIToken(tokenString) {
+				return authenticateAPIToken(c, next, apiTokens, tokenString)
+			}
 
 			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
 				if token.Method != jwt.SigningMethodHS256 {
@@ -46,24 +58,71 @@ func JWTMiddleware(cfg config.Config) echo.MiddlewareFunc {
 				return echo.NewHTTPError(http.StatusUnauthorized, "invalid user id")
 			}
 
+			sessionID, _ := claims["jti"].(string)
+			if sessionID == "" {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid session")
+			}
+			session, err := sessions.FindActive(c.Request().Context(), sessionID)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to verify session")
+			}
+			if session == nil || session.UserID != int64(userIDFloat) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "session revoked")
+			}
+			_ = sessions.Touch(c.Request().Context(), sessionID)
+
 			discordUserID, _ := claims["discord_user_id"].(string)
 
 			c.Set(string(contextKeyUserID), int64(userIDFloat))
 			c.Set(string(contextKeyDiscordUser), discordUserID)
+			c.Set(string(contextKeySessionID), sessionID)
 
 			return next(c)
 		}
 	}
 }
 
-func extractToken(c echo.Context) string {
-	if cookie, err := c.Cookie("session"); err == nil && cookie.Value != "" {
-		return cookie.Value
+// authenticateAPIToken はパーソナルアクセストークンで認証する。
+// スコープ外のルートは拒否し、拒否した場合も含めて利用履歴を記録する。
+func authenticateAPIToken(c echo.Context, next echo.HandlerFunc, apiTokens *services.APITokenService, tokenString string) error {
+	ctx := c.Request().Context()
+	token, discordUserID, err := apiTokens.Authenticate(ctx, tokenString)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to verify token")
 	}
+	if token == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid token")
+	}
+
+	if !policy.TokenAllows(*token, c.Request().Method, c.Path()) {
+		err = echo.NewHTTPError(http.StatusForbidden, "token scope does not permit this endpoint")
+	} else {
+		c.Set(string(contextKeyUserID), token.UserID)
+		c.Set(string(contextKeyDiscordUser), discordUserID)
+		c.Set(string(contextKeyAPIToken), token)
+		err = next(c)
+	}
+
+	status := c.Response().Status
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		status = httpErr.Code
+	} else if err != nil {
+		status = http.StatusInternalServerError
+	}
+	_ = apiTokens.RecordUse(ctx, *token, c.Request().Method, c.Path(), status, c.RealIP(), c.Request().UserAgent())
+	return err
+}
+
+// extractToken はBearerトークン、なければセッションCookieのアクセストークンを返す。
+func extractToken(c echo.Context) string {
 	auth := c.Request().Header.Get("Authorization")
 	if strings.HasPrefix(auth, "Bearer ") {
 		return strings.TrimPrefix(auth, "Bearer ")
 	}
+	if cookie, err := c.Cookie(sessionCookieName); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
 	return ""
 }
 
@@ -82,3 +141,19 @@ func MustDiscordUserID(c echo.Context) string {
 	}
 	return ""
 }
+
+// MustSessionID は現在のセッションID（JWTのjti）を取り出す。
+func MustSessionID(c echo.Context) string {
+	if v, ok := c.Get(string(contextKeySessionID)).(string); ok {
+		return v
+	}
+	return ""
+}
+
+// CurrentAPIToken はパーソナルアクセストークンで認証された場合のトークンを返す。セッションの場合はnil。
+func CurrentAPIToken(c echo.Context) *models.APIToken {
+	if v, ok := c.Get(string(contextKeyAPIToken)).(*models.APIToken); ok {
+		return v
+	}
+	return nil
+}
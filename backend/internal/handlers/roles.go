@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"connpass-requirement/internal/models"
+	"connpass-requirement/internal/repository"
+	"connpass-requirement/internal/services"
+)
+
+// RoleHandler はギルドのアプリ内ロール管理API。
+type RoleHandler struct {
+	roles   *repository.GuildRoleRepository
+	users   *repository.UserRepository
+	authz   *Authorizer
+	discord *services.DiscordService
+	audit   *services.AuditService
+}
+
+func NewRoleHandler(roles *repository.GuildRoleRepository, users *repository.UserRepository, authz *Authorizer, discord *services.DiscordService, audit *services.AuditService) *RoleHandler {
+	return &RoleHandler{roles: roles, users: users, authz: authz, discord: discord, audit: audit}
+}
+
+// RegisterRoleRoutes はロール管理ルートを登録する。ギルドのadminロールが必要。
+func RegisterRoleRoutes(g *echo.Group, handler *RoleHandler) {
+	admin := handler.authz.RequireGuildRole(models.GuildRoleAdmin, GuildFromParam("guildId"))
+
+	g.GET("/guilds/:guildId/roles", handler.List, admin)
+	g.PUT("/guilds/:guildId/roles/members/:userId", handler.SetMember, admin)
+	g.DELETE("/guilds/:guildId/roles/members/:userId", handler.DeleteMember, admin)
+	g.PUT("/guilds/:guildId/roles/mappings/:discordRoleId", handler.SetMapping, admin)
+	g.DELETE("/guilds/:guildId/roles/mappings/:discordRoleId", handler.DeleteMapping, admin)
+	g.GET("/guilds/:guildId/discord-roles", handler.ListDiscordRoles, admin)
+}
+
+type rolePayload struct {
+	Role string `json:"role"`
+}
+
+func (h *RoleHandler) List(c echo.Context) error {
+	ctx := c.Request().Context()
+	guildID := c.Param("guildId")
+
+	members, err := h.roles.ListMembers(ctx, guildID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch roles")
+	}
+	mappings, err := h.roles.ListMappings(ctx, guildID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch roles")
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"members":  members,
+		"mappings": mappings,
+	})
+}
+
+// SetMember はユーザーへロールを個別に付与する。対象はギルドにログイン済みのユーザーに限る。
+func (h *RoleHandler) SetMember(c echo.Context) error {
+	ctx := c.Request().Context()
+	guildID := c.Param("guildId")
+	targetID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid userId")
+	}
+	role, err := bindRole(c)
+	if err != nil {
+		return err
+	}
+
+	perm, err := h.users.FindGuildPermission(ctx, targetID, guildID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to verify guild")
+	}
+	if perm == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "user is not a member of this guild")
+	}
+
+	previous, err := h.roles.FindMemberRole(ctx, guildID, targetID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch role")
+	}
+
+	userID := MustUserID(c)
+	member := models.GuildMemberRole{GuildID: guildID, UserID: targetID, Role: role, GrantedBy: &userID}
+	if err := h.roles.SetMemberRole(ctx, &member); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save role")
+	}
+	event := newAuditEvent(c, guildID, models.AuditActionMemberRoleSet, models.AuditTargetMemberRole, c.Param("userId"))
+	h.audit.Record(ctx, event, roleState(previous), roleState(role))
+	return c.JSON(http.StatusOK, member)
+}
+
+func (h *RoleHandler) DeleteMember(c echo.Context) error {
+	ctx := c.Request().Context()
+	guildID := c.Param("guildId")
+	targetID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid userId")
+	}
+	previous, err := h.roles.FindMemberRole(ctx, guildID, targetID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch role")
+	}
+	if err := h.roles.DeleteMemberRole(ctx, guildID, targetID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete role")
+	}
+	if previous != "" {
+		event := newAuditEvent(c, guildID, models.AuditActionMemberRoleDelete, models.AuditTargetMemberRole, c.Param("userId"))
+		h.audit.Record(ctx, event, roleState(previous), nil)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// SetMapping はDiscordロールをアプリ内ロールへ対応付ける。ログイン時に取得したロールIDで評価される。
+func (h *RoleHandler) SetMapping(c echo.Context) error {
+	discordRoleID := strings.TrimSpace(c.Param("discordRoleId"))
+	if discordRoleID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid discordRoleId")
+	}
+	role, err := bindRole(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	guildID := c.Param("guildId")
+	previous, err := h.roles.FindMappingRole(ctx, guildID, discordRoleID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch role mapping")
+	}
+
+	userID := MustUserID(c)
+	mapping := models.GuildRoleMapping{GuildID: guildID, DiscordRoleID: discordRoleID, Role: role, CreatedBy: &userID}
+	if err := h.roles.SetMapping(ctx, &mapping); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save role mapping")
+	}
+	event := newAuditEvent(c, guildID, models.AuditActionRoleMappingSet, models.AuditTargetRoleMapping, discordRoleID)
+	h.audit.Record(ctx, event, roleState(previous), roleState(role))
+	return c.JSON(http.StatusOK, mapping)
+}
+
+func (h *RoleHandler) DeleteMapping(c echo.Context) error {
+	ctx := c.Request().Context()
+	guildID := c.Param("guildId")
+	discordRoleID := c.Param("discordRoleId")
+	previous, err := h.roles.FindMappingRole(ctx, guildID, discordRoleID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch role mapping")
+	}
+	if err := h.roles.DeleteMapping(ctx, guildID, discordRoleID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete role mapping")
+	}
+	if previous != "" {
+		event := newAuditEvent(c, guildID, models.AuditActionRoleMappingDelete, models.AuditTargetRoleMapping, discordRoleID)
+		h.audit.Record(ctx, event, roleState(previous), nil)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListDiscordRoles は対応付けの候補となるDiscordロール一覧を返す。
+func (h *RoleHandler) ListDiscordRoles(c echo.Context) error {
+	if h.discord == nil {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "discord integration is disabled")
+	}
+	roles, err := h.discord.ListRoles(c.Request().Context(), c.Param("guildId"))
+	if err != nil {
+		if errors.Is(err, services.ErrMissingAccess) {
+			return echo.NewHTTPError(http.StatusForbidden, "bot does not have access to this guild")
+		}
+		return echo.NewHTTPError(http.StatusBadGateway, "failed to fetch roles")
+	}
+
+	type roleView struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		Position int    `json:"position"`
+	}
+	resp := make([]roleView, 0, len(roles))
+	for _, role := range roles {
+		if role.Managed {
+			continue
+		}
+		resp = append(resp, roleView{ID: role.ID, Name: role.Name, Position: role.Position})
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// roleState は監査ログに残すロールの状態。未設定の場合はnil。
+func roleState(role string) any {
+	if role == "" {
+		return nil
+	}
+	return map[string]string{"role": role}
+}
+
+func bindRole(c echo.Context) (string, error) {
+	var payload rolePayload
+	if err := c.Bind(&payload); err != nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "invalid body")
+	}
+	role := strings.TrimSpace(payload.Role)
+	if !models.IsValidGuildRole(role) {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "role must be one of viewer, editor, admin")
+	}
+	return role, nil
+}
@@ -0,0 +1,464 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gopkg.in/yaml.v3"
+
+	"connpass-requirement/internal/models"
+	"connpass-requirement/internal/services"
+)
+
+const (
+	ruleDocumentVersion = 1
+	// ruleImportMaxBytes / ruleImportMaxRules は1回のインポートで受け付ける上限
+	ruleImportMaxBytes = 1 << 20
+	ruleImportMaxRules = 200
+)
+
+// ruleDocument はルールのエクスポート・インポート形式。YAMLとJSONで同じ項目名を使う。
+type ruleDocument struct {
+	Version int                `json:"version" yaml:"version"`
+	GuildID string             `json:"guildId,omitempty" yaml:"guildId,omitempty"`
+	Rules   []ruleDocumentRule `json:"rules" yaml:"rules"`
+}
+
+// ruleDocumentRule は1件分のルール設定。channelIdがギルドに存在しない場合はchannel（チャンネル名）で解決する。
+type ruleDocumentRule struct {
+	Name              string   `json:"name" yaml:"name"`
+	Channel           string   `json:"channel,omitempty" yaml:"channel,omitempty"`
+	ChannelID         string   `json:"channelId,omitempty" yaml:"channelId,omitempty"`
+	Description       string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Keywords          []string `json:"keywords" yaml:"keywords"`
+	NotifyTypes       []string `json:"notifyTypes" yaml:"notifyTypes"`
+	Location          string   `json:"location,omitempty" yaml:"location,omitempty"`
+	Prefectures       []string `json:"prefectures,omitempty" yaml:"prefectures,omitempty"`
+	VenueType         string   `json:"venueType,omitempty" yaml:"venueType,omitempty"`
+	Weekdays          []int    `json:"weekdays,omitempty" yaml:"weekdays,omitempty"`
+	TimeFrom          string   `json:"timeFrom,omitempty" yaml:"timeFrom,omitempty"`
+	TimeTo            string   `json:"timeTo,omitempty" yaml:"timeTo,omitempty"`
+	WithinDays        int      `json:"withinDays,omitempty" yaml:"withinDays,omitempty"`
+	MatchMode         string   `json:"matchMode,omitempty" yaml:"matchMode,omitempty"`
+	MatchPatterns     []string `json:"matchPatterns,omitempty" yaml:"matchPatterns,omitempty"`
+	FuzzyDistance     int      `json:"fuzzyDistance,omitempty" yaml:"fuzzyDistance,omitempty"`
+	CapacityThreshold int      `json:"capacityThreshold,omitempty" yaml:"capacityThreshold,omitempty"`
+	FillHorizonHours  int      `json:"fillHorizonHours,omitempty" yaml:"fillHorizonHours,omitempty"`
+	ExcludeKeywords   []string `json:"excludeKeywords,omitempty" yaml:"excludeKeywords,omitempty"`
+	ExcludeOwners     []string `json:"excludeOwners,omitempty" yaml:"excludeOwners,omitempty"`
+	ExcludeSeries     []string `json:"excludeSeries,omitempty" yaml:"excludeSeries,omitempty"`
+	IsActive          *bool    `json:"isActive,omitempty" yaml:"isActive,omitempty"`
+	BaselineMode      string   `json:"baselineMode,omitempty" yaml:"baselineMode,omitempty"`
+
+	// present はドキュメントに書かれていた項目名。既存ルールの更新では書かれた項目だけを上書きする。
+	present map[string]bool
+}
+
+// has は項目がドキュメントに書かれていたかを返す。
+func (r ruleDocumentRule) has(key string) bool {
+	return r.present[key]
+}
+
+// ruleImportResult はインポート1件ごとの結果。
+type ruleImportResult struct {
+	Index     int    `json:"index"`
+	Name      string `json:"name"`
+	Action    string `json:"action,omitempty"`
+	RuleID    int64  `json:"ruleId,omitempty"`
+	ChannelID string `json:"channelId,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Export はギルドのルールをYAMLまたはJSONで返す。?format=yaml|json（既定json）。
+func (h *RuleHandler) Export(c echo.Context) error {
+	guildID := c.Param("guildId")
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "yaml" {
+		return echo.NewHTTPError(http.StatusBadRequest, "format must be json or yaml")
+	}
+
+	rules, err := h.rules.ListByGuild(c.Request().Context(), guildID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch rules")
+	}
+	doc := ruleDocument{Version: ruleDocumentVersion, GuildID: guildID, Rules: make([]ruleDocumentRule, 0, len(rules))}
+	for _, rule := range rules {
+		doc.Rules = append(doc.Rules, ruleDocumentFrom(rule))
+	}
+
+	filename := "rules-" + guildID + "." + format
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	if format == "yaml" {
+		body, err := yaml.Marshal(doc)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to encode rules")
+		}
+		return c.Blob(http.StatusOK, "application/yaml; charset=utf-8", body)
+	}
+	return c.JSON(http.StatusOK, doc)
+}
+
+func ruleDocumentFrom(rule models.Rule) ruleDocumentRule {
+	config := models.RuleConfigOf(rule)
+	isActive := config.IsActive
+	return ruleDocumentRule{
+		Name:              config.Name,
+		Channel:           config.ChannelName,
+		ChannelID:         config.ChannelID,
+		Description:       config.Description,
+		Keywords:          config.Keywords,
+		NotifyTypes:       config.NotifyTypes,
+		Location:          config.Location,
+		Prefectures:       config.Prefectures,
+		VenueType:         config.VenueType,
+		Weekdays:          config.Weekdays,
+		TimeFrom:          config.TimeFrom,
+		TimeTo:            config.TimeTo,
+		WithinDays:        config.WithinDays,
+		MatchMode:         config.MatchMode,
+		MatchPatterns:     config.MatchPatterns,
+		FuzzyDistance:     config.FuzzyDistance,
+		CapacityThreshold: config.CapacityThresh,
+		FillHorizonHours:  config.FillHorizonHours,
+		ExcludeKeywords:   config.ExcludeKeywords,
+		ExcludeOwners:     config.ExcludeOwners,
+		ExcludeSeries:     config.ExcludeSeries,
+		IsActive:          &isActive,
+		BaselineMode:      config.BaselineMode,
+	}
+}
+
+// Import はYAMLまたはJSONのルール定義を取り込む。同名のルールは更新し、それ以外は作成する。
+// ?dry_run=trueの場合は検証結果のみ返して保存しない。1件でもエラーがあれば何も保存しない。
+func (h *RuleHandler) Import(c echo.Context) error {
+	if h.discord == nil {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "discord integration is disabled")
+	}
+	userID := MustUserID(c)
+	guildID := c.Param("guildId")
+	dryRun := c.QueryParam("dry_run") == "true"
+
+	doc, err := decodeRuleDocument(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	channels, err := h.discord.ListTextChannels(ctx, guildID)
+	if err != nil {
+		if errors.Is(err, services.ErrMissingAccess) {
+			return echo.NewHTTPError(http.StatusForbidden, "bot does not have access to this guild")
+		}
+		return echo.NewHTTPError(http.StatusBadGateway, "failed to fetch channels")
+	}
+	channelNames := make(map[string]string, len(channels))
+	channelIDs := make(map[string][]string, len(channels))
+	for _, ch := range channels {
+		channelNames[ch.ID] = ch.Name
+		channelIDs[ch.Name] = append(channelIDs[ch.Name], ch.ID)
+	}
+
+	existing, err := h.rules.ListByGuild(ctx, guildID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch rules")
+	}
+	byName := make(map[string][]models.Rule, len(existing))
+	for _, rule := range existing {
+		byName[rule.Name] = append(byName[rule.Name], rule)
+	}
+
+	var (
+		results      = make([]ruleImportResult, 0, len(doc.Rules))
+		creates      []*models.Rule
+		updates      []*models.Rule
+		befores      = map[int64]models.Rule{}
+		seen         = make(map[string]int, len(doc.Rules))
+		invalidCount int
+	)
+	for i, entry := range doc.Rules {
+		result := ruleImportResult{Index: i, Name: strings.TrimSpace(entry.Name)}
+		var (
+			rule *models.Rule
+			base *models.Rule
+		)
+		matches := byName[result.Name]
+		if len(matches) == 1 {
+			base = &matches[0]
+		}
+		if prev, ok := seen[result.Name]; ok {
+			err = fmt.Errorf("duplicate rule name (same as rules[%d])", prev)
+		} else if len(matches) > 1 {
+			err = errors.New("multiple existing rules have this name")
+		} else {
+			rule, err = h.importRule(guildID, userID, entry, base, channelNames, channelIDs)
+		}
+		if err != nil {
+			result.Error = err.Error()
+			invalidCount++
+			results = append(results, result)
+			continue
+		}
+		seen[result.Name] = i
+		result.ChannelID = rule.ChannelID
+
+		if base != nil {
+			current := *base
+			befores[current.ID] = current
+			models.RuleConfigOf(*rule).ApplyTo(&current)
+			current.UpdatedBy = &userID
+			updates = append(updates, &current)
+			result.Action = "update"
+			result.RuleID = current.ID
+		} else {
+			creates = append(creates, rule)
+			result.Action = "create"
+		}
+		results = append(results, result)
+	}
+
+	resp := map[string]any{
+		"dryRun":  dryRun,
+		"created": len(creates),
+		"updated": len(updates),
+		"results": results,
+	}
+	if invalidCount > 0 {
+		resp["created"], resp["updated"] = 0, 0
+		return c.JSON(http.StatusUnprocessableEntity, resp)
+	}
+	if dryRun {
+		return c.JSON(http.StatusOK, resp)
+	}
+
+	if err := h.rules.Import(ctx, creates, updates); err != nil {
+		h.logger.Error(ctx, "database_error", "ルールのインポートに失敗", map[string]any{"guildId": guildID, "error": err.Error()})
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to import rules")
+	}
+
+	createIndex := 0
+	for i := range results {
+		if results[i].Action == "create" {
+			results[i].RuleID = creates[createIndex].ID
+			createIndex++
+		}
+	}
+	for _, rule := range creates {
+		h.audit.Record(ctx, ruleAuditEvent(c, *rule, models.AuditActionRuleCreate), nil, rule)
+	}
+	for _, rule := range updates {
+		h.audit.Record(ctx, ruleAuditEvent(c, *rule, models.AuditActionRuleUpdate), befores[rule.ID], rule)
+	}
+	h.logger.Info(ctx, "rules_imported", "ルールをインポートしました", map[string]any{
+		"guildId": guildID,
+		"userId":  userID,
+		"created": len(creates),
+		"updated": len(updates),
+	})
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// decodeRuleDocument はContent-TypeまたはformatクエリでYAMLかJSONを判定して読み込む。未定義の項目はエラーにする。
+func decodeRuleDocument(c echo.Context) (*ruleDocument, error) {
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, ruleImportMaxBytes+1))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "failed to read body")
+	}
+	if len(body) > ruleImportMaxBytes {
+		return nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, "import document is too large")
+	}
+
+	var doc ruleDocument
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+	isYAML := c.QueryParam("format") == "yaml" || strings.Contains(contentType, "yaml")
+	if isYAML {
+		dec := yaml.NewDecoder(bytes.NewReader(body))
+		dec.KnownFields(true)
+		err = dec.Decode(&doc)
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&doc)
+	}
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid document: "+err.Error())
+	}
+	if err := markPresentFields(&doc, body, isYAML); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid document: "+err.Error())
+	}
+
+	if doc.Version != ruleDocumentVersion {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "unsupported version: "+strconv.Itoa(doc.Version))
+	}
+	if len(doc.Rules) == 0 {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "rules are required")
+	}
+	if len(doc.Rules) > ruleImportMaxRules {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "too many rules (max 200)")
+	}
+	return &doc, nil
+}
+
+// markPresentFields はrules[]の各要素に書かれていた項目名を記録する。
+func markPresentFields(doc *ruleDocument, body []byte, isYAML bool) error {
+	var keys struct {
+		Rules []map[string]any `json:"rules" yaml:"rules"`
+	}
+	var err error
+	if isYAML {
+		err = yaml.Unmarshal(body, &keys)
+	} else {
+		err = json.Unmarshal(body, &keys)
+	}
+	if err != nil {
+		return err
+	}
+	for i := range doc.Rules {
+		doc.Rules[i].present = map[string]bool{}
+		if i >= len(keys.Rules) {
+			continue
+		}
+		for key := range keys.Rules[i] {
+			doc.Rules[i].present[key] = true
+		}
+	}
+	return nil
+}
+
+// importRule は1件分の定義を検証し、チャンネルを解決したルールを組み立てる。
+// baseが指定された場合（同名の既存ルールの更新）は既存の設定から始め、ドキュメントに書かれた項目だけを上書きする。
+func (h *RuleHandler) importRule(guildID string, userID int64, entry ruleDocumentRule, base *models.Rule, channelNames map[string]string, channelIDs map[string][]string) (*models.Rule, error) {
+	if strings.TrimSpace(entry.Name) == "" {
+		return nil, errors.New("name is required")
+	}
+
+	payload := rulePayload{GuildID: guildID, IsActive: true}
+	if base != nil {
+		payload = payloadFromConfig(guildID, models.RuleConfigOf(*base))
+	}
+	if base == nil || entry.has("channel") || entry.has("channelId") {
+		channelID, channelName, err := resolveImportChannel(entry, channelNames, channelIDs)
+		if err != nil {
+			return nil, err
+		}
+		payload.ChannelID, payload.ChannelName = channelID, channelName
+	}
+	entry.applyTo(&payload)
+	payload.Keywords = trimValues(payload.Keywords)
+	payload.NotifyTypes = trimValues(payload.NotifyTypes)
+
+	if len(payload.Keywords) == 0 {
+		return nil, errors.New("keywords are required")
+	}
+	if len(payload.NotifyTypes) == 0 {
+		return nil, errors.New("notifyTypes are required")
+	}
+	if err := normalizePayload(&payload); err != nil {
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			return nil, fmt.Errorf("%v", httpErr.Message)
+		}
+		return nil, err
+	}
+
+	rule := ruleFromPayload(userID, payload)
+	return &rule, nil
+}
+
+// applyTo はドキュメントに書かれていた項目をペイロードへ反映する。
+func (r ruleDocumentRule) applyTo(p *rulePayload) {
+	p.Name = strings.TrimSpace(r.Name)
+	if r.has("description") {
+		p.Description = r.Description
+	}
+	if r.has("keywords") {
+		p.Keywords = r.Keywords
+	}
+	if r.has("notifyTypes") {
+		p.NotifyTypes = r.NotifyTypes
+	}
+	if r.has("location") {
+		p.Location = r.Location
+	}
+	if r.has("prefectures") {
+		p.Prefectures = r.Prefectures
+	}
+	if r.has("venueType") {
+		p.VenueType = r.VenueType
+	}
+	if r.has("weekdays") {
+		p.Weekdays = r.Weekdays
+	}
+	if r.has("timeFrom") {
+		p.TimeFrom = r.TimeFrom
+	}
+	if r.has("timeTo") {
+		p.TimeTo = r.TimeTo
+	}
+	if r.has("withinDays") {
+		p.WithinDays = r.WithinDays
+	}
+	if r.has("matchMode") {
+		p.MatchMode = r.MatchMode
+	}
+	if r.has("matchPatterns") {
+		p.MatchPatterns = r.MatchPatterns
+	}
+	if r.has("fuzzyDistance") {
+		p.FuzzyDistance = r.FuzzyDistance
+	}
+	if r.has("capacityThreshold") {
+		p.CapacityThresh = r.CapacityThreshold
+	}
+	if r.has("fillHorizonHours") {
+		p.FillHorizonHours = r.FillHorizonHours
+	}
+	if r.has("excludeKeywords") {
+		p.ExcludeKeywords = r.ExcludeKeywords
+	}
+	if r.has("excludeOwners") {
+		p.ExcludeOwners = r.ExcludeOwners
+	}
+	if r.has("excludeSeries") {
+		p.ExcludeSeries = r.ExcludeSeries
+	}
+	if r.has("isActive") && r.IsActive != nil {
+		p.IsActive = *r.IsActive
+	}
+	if r.has("baselineMode") {
+		p.BaselineMode = r.BaselineMode
+	}
+}
+
+// resolveImportChannel はギルドに存在するchannelIdを優先し、なければチャンネル名で解決する。
+func resolveImportChannel(entry ruleDocumentRule, channelNames map[string]string, channelIDs map[string][]string) (string, string, error) {
+	if name, ok := channelNames[entry.ChannelID]; ok {
+		return entry.ChannelID, name, nil
+	}
+	name := strings.TrimPrefix(strings.TrimSpace(entry.Channel), "#")
+	if name == "" {
+		if entry.ChannelID != "" {
+			return "", "", fmt.Errorf("channel %s not found in guild", entry.ChannelID)
+		}
+		return "", "", errors.New("channel or channelId is required")
+	}
+	ids := channelIDs[name]
+	switch len(ids) {
+	case 0:
+		return "", "", fmt.Errorf("text channel #%s not found in guild", name)
+	case 1:
+		return ids[0], name, nil
+	default:
+		return "", "", fmt.Errorf("multiple text channels are named #%s; specify channelId", name)
+	}
+}
@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
@@ -14,34 +18,46 @@ import (
 
 // RuleHandler は通知ルール操作API。
 type RuleHandler struct {
-	rules   *repository.RuleRepository
-	users   *repository.UserRepository
-	logger  *services.LoggerService
-	discord *services.DiscordService
+	rules     *repository.RuleRepository
+	authz     *Authorizer
+	logger    *services.LoggerService
+	discord   *services.DiscordService
+	simulator *services.SimulatorService
+	audit     *services.AuditService
 }
 
-func NewRuleHandler(rules *repository.RuleRepository, users *repository.UserRepository, logger *services.LoggerService, discord *services.DiscordService) *RuleHandler {
-	return &RuleHandler{rules: rules, users: users, logger: logger, discord: discord}
+func NewRuleHandler(rules *repository.RuleRepository, authz *Authorizer, logger *services.LoggerService, discord *services.DiscordService, simulator *services.SimulatorService, audit *services.AuditService) *RuleHandler {
+	return &RuleHandler{rules: rules, authz: authz, logger: logger, discord: discord, simulator: simulator, audit: audit}
 }
 
 // RegisterRuleRoutes はルール関連のルートを登録する。
 func RegisterRuleRoutes(g *echo.Group, handler *RuleHandler) {
-	g.GET("/rules", handler.List)
+	guildViewer := handler.authz.RequireGuildRole(models.GuildRoleViewer, GuildFromQuery("guild_id"))
+	guildParamViewer := handler.authz.RequireGuildRole(models.GuildRoleViewer, GuildFromParam("guildId"))
+	guildParamEditor := handler.authz.RequireGuildRole(models.GuildRoleEditor, GuildFromParam("guildId"))
+	viewer := handler.authz.RequireRuleRole(models.GuildRoleViewer, "id")
+	editor := handler.authz.RequireRuleRole(models.GuildRoleEditor, "id")
+
+	g.GET("/rules", handler.List, guildViewer)
 	g.POST("/rules", handler.Create)
-	g.GET("/rules/:id", handler.Get)
-	g.PUT("/rules/:id", handler.Update)
-	g.DELETE("/rules/:id", handler.Delete)
-	g.POST("/rules/:id/test", handler.Test)
+	g.POST("/rules/simulate", handler.Simulate)
+	g.GET("/rules/:id", handler.Get, viewer)
+	g.PUT("/rules/:id", handler.Update, editor)
+	g.DELETE("/rules/:id", handler.Delete, editor)
+	g.POST("/rules/:id/test", handler.Test, editor)
+	g.POST("/rules/:id/transfer", handler.Transfer, editor)
+	g.GET("/rules/:id/revisions", handler.ListRevisions, viewer)
+	g.POST("/rules/:id/revisions/:rev/restore", handler.RestoreRevision, editor)
+	g.GET("/guilds/:guildId/rules/export", handler.Export, guildParamViewer)
+	g.POST("/guilds/:guildId/rules/import", handler.Import, guildParamEditor)
+	g.GET("/guilds/:guildId/rules/trash", handler.ListTrash, guildParamViewer)
+	g.POST("/guilds/:guildId/rules/trash/:id/restore", handler.RestoreFromTrash, guildParamEditor)
 }
 
 func (h *RuleHandler) List(c echo.Context) error {
-	userID := MustUserID(c)
 	guildID := c.QueryParam("guild_id")
-	if guildID == "" {
-		return echo.NewHTTPError(http.StatusBadRequest, "guild_id is required")
-	}
 
-	rules, err := h.rules.ListByUserAndGuild(c.Request().Context(), userID, guildID)
+	rules, err := h.rules.ListByGuild(c.Request().Context(), guildID)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch rules")
 	}
@@ -50,16 +66,30 @@ func (h *RuleHandler) List(c echo.Context) error {
 }
 
 type rulePayload struct {
-	GuildID        string   `json:"guildId"`
-	ChannelID      string   `json:"channelId"`
-	ChannelName    string   `json:"channelName"`
-	Name           string   `json:"name"`
-	Description    string   `json:"description"`
-	Location       string   `json:"location"`
-	CapacityThresh int      `json:"capacityThreshold"`
-	Keywords       []string `json:"keywords"`
-	NotifyTypes    []string `json:"notifyTypes"`
-	IsActive       bool     `json:"isActive"`
+	GuildID          string   `json:"guildId"`
+	ChannelID        string   `json:"channelId"`
+	ChannelName      string   `json:"channelName"`
+	Name             string   `json:"name"`
+	Description      string   `json:"description"`
+	Location         string   `json:"location"`
+	Prefectures      []string `json:"prefectures"`
+	VenueType        string   `json:"venueType"`
+	Weekdays         []int    `json:"weekdays"`
+	TimeFrom         string   `json:"timeFrom"`
+	TimeTo           string   `json:"timeTo"`
+	WithinDays       int      `json:"withinDays"`
+	MatchMode        string   `json:"matchMode"`
+	MatchPatterns    []string `json:"matchPatterns"`
+	FuzzyDistance    int      `json:"fuzzyDistance"`
+	CapacityThresh   int      `json:"capacityThreshold"`
+	FillHorizonHours int      `json:"fillHorizonHours"`
+	Keywords         []string `json:"keywords"`
+	ExcludeKeywords  []string `json:"excludeKeywords"`
+	ExcludeOwners    []string `json:"excludeOwners"`
+	ExcludeSeries    []string `json:"excludeSeries"`
+	NotifyTypes      []string `json:"notifyTypes"`
+	IsActive         bool     `json:"isActive"`
+	BaselineMode     string   `json:"baselineMode"`
 }
 
 func (h *RuleHandler) Create(c echo.Context) error {
@@ -69,167 +99,476 @@ func (h *RuleHandler) Create(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid body")
 	}
 
-	if err := h.ensureGuildPermission(c, userID, payload.GuildID); err != nil {
+	if err := h.authz.Require(c, payload.GuildID, models.GuildRoleEditor); err != nil {
 		return err
 	}
-
-	rule := models.Rule{
-		UserID:         userID,
-		GuildID:        payload.GuildID,
-		ChannelID:      payload.ChannelID,
-		ChannelName:    payload.ChannelName,
-		Name:           strings.TrimSpace(payload.Name),
-		Description:    strings.TrimSpace(payload.Description),
-		Location:       strings.TrimSpace(payload.Location),
-		CapacityThresh: payload.CapacityThresh,
-		Keywords:       payload.Keywords,
-		NotifyTypes:    payload.NotifyTypes,
-		IsActive:       payload.IsActive,
+	if err := normalizePayload(&payload); err != nil {
+		return err
 	}
 
+	rule := ruleFromPayload(userID, payload)
+
 	if err := h.rules.Create(c.Request().Context(), &rule); err != nil {
 		h.logger.Error(c.Request().Context(), "database_error", "ルール作成に失敗", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create rule")
 	}
+	h.logger.Info(c.Request().Context(), "rule_created", "ルールを作成しました", ruleLogMetadata(rule, userID))
+	h.audit.Record(c.Request().Context(), ruleAuditEvent(c, rule, models.AuditActionRuleCreate), nil, rule)
 
 	return c.JSON(http.StatusCreated, rule)
 }
 
-func (h *RuleHandler) Get(c echo.Context) error {
-	ruleID, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+// ruleFromPayload は検証済みのペイロードから新規ルールを組み立てる。
+func ruleFromPayload(userID int64, payload rulePayload) models.Rule {
+	return models.Rule{
+		UserID:           userID,
+		GuildID:          payload.GuildID,
+		ChannelID:        payload.ChannelID,
+		ChannelName:      payload.ChannelName,
+		Name:             strings.TrimSpace(payload.Name),
+		Description:      strings.TrimSpace(payload.Description),
+		Location:         strings.TrimSpace(payload.Location),
+		Prefectures:      payload.Prefectures,
+		VenueType:        payload.VenueType,
+		Weekdays:         payload.Weekdays,
+		TimeFrom:         payload.TimeFrom,
+		TimeTo:           payload.TimeTo,
+		WithinDays:       payload.WithinDays,
+		MatchMode:        payload.MatchMode,
+		MatchPatterns:    payload.MatchPatterns,
+		FuzzyDistance:    payload.FuzzyDistance,
+		CapacityThresh:   payload.CapacityThresh,
+		FillHorizonHours: payload.FillHorizonHours,
+		Keywords:         payload.Keywords,
+		ExcludeKeywords:  trimValues(payload.ExcludeKeywords),
+		ExcludeOwners:    trimValues(payload.ExcludeOwners),
+		ExcludeSeries:    trimValues(payload.ExcludeSeries),
+		NotifyTypes:      payload.NotifyTypes,
+		IsActive:         payload.IsActive,
+		BaselineMode:     payload.BaselineMode,
 	}
+}
 
-	rule, err := h.rules.Get(c.Request().Context(), ruleID)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch rule")
-	}
-	if rule == nil {
-		return echo.NewHTTPError(http.StatusNotFound, "rule not found")
+// ruleAuditEvent はルール操作の監査ログを組み立てる。
+func ruleAuditEvent(c echo.Context, rule models.Rule, action string) models.AuditEvent {
+	return newAuditEvent(c, rule.GuildID, action, models.AuditTargetRule, strconv.FormatInt(rule.ID, 10))
+}
+
+// ruleLogMetadata はルール操作ログ（Webhook配信を含む）の共通メタデータ。
+func ruleLogMetadata(rule models.Rule, userID int64) map[string]any {
+	return map[string]any{
+		"ruleId":    rule.ID,
+		"ruleName":  rule.Name,
+		"guildId":   rule.GuildID,
+		"channelId": rule.ChannelID,
+		"isActive":  rule.IsActive,
+		"userId":    userID,
 	}
+}
 
-	return c.JSON(http.StatusOK, rule)
+type simulatePayload struct {
+	rulePayload
+	RuleID        int64      `json:"ruleId"`
+	ReferenceTime *time.Time `json:"referenceTime"`
 }
 
-func (h *RuleHandler) Update(c echo.Context) error {
+// Simulate はルールを保存・送信せずに評価し、発火するトリガーと除外理由を返す。
+func (h *RuleHandler) Simulate(c echo.Context) error {
 	userID := MustUserID(c)
-	ruleID, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
-	}
-
-	var payload rulePayload
+	var payload simulatePayload
 	if err := c.Bind(&payload); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid body")
 	}
 
-	rule, err := h.rules.Get(c.Request().Context(), ruleID)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch rule")
+	if err := h.authz.Require(c, payload.GuildID, models.GuildRoleEditor); err != nil {
+		return err
 	}
-	if rule == nil {
-		return echo.NewHTTPError(http.StatusNotFound, "rule not found")
+	if err := normalizePayload(&payload.rulePayload); err != nil {
+		return err
 	}
-	if rule.UserID != userID {
-		return echo.NewHTTPError(http.StatusForbidden, "permission denied")
+
+	rule := ruleFromPayload(userID, payload.rulePayload)
+	if len(trimValues(rule.Keywords)) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "keywords are required")
+	}
+	// 保存済みのルールを指定した場合は、一致履歴・通知履歴・ベースライン状態を反映する
+	if payload.RuleID != 0 {
+		existing, err := h.authz.Rule(c, payload.RuleID, models.GuildRoleEditor)
+		if err != nil {
+			return err
+		}
+		if existing.GuildID != payload.GuildID {
+			return echo.NewHTTPError(http.StatusBadRequest, "rule belongs to another guild")
+		}
+		rule.ID = existing.ID
+		rule.BaselineAt = existing.BaselineAt
+	}
+
+	now := time.Now()
+	if payload.ReferenceTime != nil {
+		now = *payload.ReferenceTime
 	}
 
-	if err := h.ensureGuildPermission(c, userID, payload.GuildID); err != nil {
+	result, err := h.simulator.Simulate(c.Request().Context(), rule, now)
+	if err != nil {
+		h.logger.Error(c.Request().Context(), "rule_simulate_error", "ルールのシミュレーションに失敗", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to simulate rule")
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h *RuleHandler) Get(c echo.Context) error {
+	return c.JSON(http.StatusOK, MustRule(c))
+}
+
+func (h *RuleHandler) Update(c echo.Context) error {
+	userID := MustUserID(c)
+	rule := MustRule(c)
+
+	var payload rulePayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid body")
+	}
+	// ルールはギルドに属するため、別ギルドへの移動は受け付けない
+	if payload.GuildID != rule.GuildID {
+		return echo.NewHTTPError(http.StatusBadRequest, "guildId cannot be changed")
+	}
+	if err := normalizePayload(&payload); err != nil {
 		return err
 	}
 
-	rule.GuildID = payload.GuildID
+	before := *rule
 	rule.ChannelID = payload.ChannelID
 	rule.ChannelName = payload.ChannelName
 	rule.Name = strings.TrimSpace(payload.Name)
 	rule.Description = strings.TrimSpace(payload.Description)
 	rule.Location = strings.TrimSpace(payload.Location)
+	rule.Prefectures = payload.Prefectures
+	rule.VenueType = payload.VenueType
+	rule.Weekdays = payload.Weekdays
+	rule.TimeFrom = payload.TimeFrom
+	rule.TimeTo = payload.TimeTo
+	rule.WithinDays = payload.WithinDays
+	rule.MatchMode = payload.MatchMode
+	rule.MatchPatterns = payload.MatchPatterns
+	rule.FuzzyDistance = payload.FuzzyDistance
 	rule.CapacityThresh = payload.CapacityThresh
+	rule.FillHorizonHours = payload.FillHorizonHours
 	rule.Keywords = payload.Keywords
+	rule.ExcludeKeywords = trimValues(payload.ExcludeKeywords)
+	rule.ExcludeOwners = trimValues(payload.ExcludeOwners)
+	rule.ExcludeSeries = trimValues(payload.ExcludeSeries)
 	rule.NotifyTypes = payload.NotifyTypes
 	rule.IsActive = payload.IsActive
+	rule.BaselineMode = payload.BaselineMode
+	rule.UpdatedBy = &userID
 
 	if err := h.rules.Update(c.Request().Context(), rule); err != nil {
 		h.logger.Error(c.Request().Context(), "database_error", "ルール更新に失敗", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update rule")
 	}
+	h.logger.Info(c.Request().Context(), "rule_updated", "ルールを更新しました", ruleLogMetadata(*rule, userID))
+	h.audit.Record(c.Request().Context(), ruleAuditEvent(c, *rule, models.AuditActionRuleUpdate), before, rule)
 
 	return c.JSON(http.StatusOK, rule)
 }
 
 func (h *RuleHandler) Delete(c echo.Context) error {
 	userID := MustUserID(c)
-	ruleID, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
-	}
-
-	rule, err := h.rules.Get(c.Request().Context(), ruleID)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch rule")
-	}
-	if rule == nil {
-		return echo.NewHTTPError(http.StatusNotFound, "rule not found")
-	}
-	if rule.UserID != userID {
-		return echo.NewHTTPError(http.StatusForbidden, "permission denied")
-	}
+	rule := MustRule(c)
 
-	if err := h.rules.Delete(c.Request().Context(), ruleID); err != nil {
+	if err := h.rules.Delete(c.Request().Context(), rule.ID, userID); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete rule")
 	}
+	h.logger.Info(c.Request().Context(), "rule_deleted", "ルールをゴミ箱へ移しました", ruleLogMetadata(*rule, userID))
+	h.audit.Record(c.Request().Context(), ruleAuditEvent(c, *rule, models.AuditActionRuleDelete), rule, nil)
 
 	return c.NoContent(http.StatusNoContent)
 }
 
-func (h *RuleHandler) Test(c echo.Context) error {
-	if h.discord == nil {
-		return echo.NewHTTPError(http.StatusServiceUnavailable, "discord integration is disabled")
+// ListTrash はギルドのゴミ箱にあるルールを返す。保持期間を過ぎたものはスケジューラが完全に削除する。
+func (h *RuleHandler) ListTrash(c echo.Context) error {
+	rules, err := h.rules.ListDeletedByGuild(c.Request().Context(), c.Param("guildId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch rules")
 	}
+	return c.JSON(http.StatusOK, rules)
+}
+
+// RestoreFromTrash はゴミ箱のルールを元に戻す。
+func (h *RuleHandler) RestoreFromTrash(c echo.Context) error {
 	userID := MustUserID(c)
 	ruleID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid rule id")
 	}
 
-	rule, err := h.rules.Get(c.Request().Context(), ruleID)
+	ctx := c.Request().Context()
+	rule, err := h.rules.Undelete(ctx, c.Param("guildId"), ruleID, userID)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch rule")
+		h.logger.Error(ctx, "database_error", "ルールの復元に失敗", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to restore rule")
 	}
 	if rule == nil {
-		return echo.NewHTTPError(http.StatusNotFound, "rule not found")
+		return echo.NewHTTPError(http.StatusNotFound, "rule not found in trash")
 	}
-	if rule.UserID != userID {
-		return echo.NewHTTPError(http.StatusForbidden, "permission denied")
+	h.logger.Info(ctx, "rule_undeleted", "ルールをゴミ箱から戻しました", ruleLogMetadata(*rule, userID))
+	h.audit.Record(ctx, ruleAuditEvent(c, *rule, models.AuditActionRuleUndelete), map[string]any{"deleted": true}, map[string]any{"deleted": false})
+
+	return c.JSON(http.StatusOK, rule)
+}
+
+func (h *RuleHandler) Test(c echo.Context) error {
+	if h.discord == nil {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "discord integration is disabled")
 	}
+	rule := MustRule(c)
 
 	message := "テスト通知です。Discord Botの接続とチャンネル権限を確認しました。"
 	if err := h.discord.SendMessage(c.Request().Context(), rule.ChannelID, message); err != nil {
 		return echo.NewHTTPError(http.StatusBadGateway, "failed to send test notification")
 	}
+	h.audit.Record(c.Request().Context(), ruleAuditEvent(c, *rule, models.AuditActionRuleTest), nil, map[string]any{"channelId": rule.ChannelID})
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "テスト通知を送信しました"})
 }
 
-func (h *RuleHandler) ensureGuildPermission(c echo.Context, userID int64, guildID string) error {
-	if guildID == "" {
-		return echo.NewHTTPError(http.StatusBadRequest, "guildId is required")
+type transferPayload struct {
+	UserID int64 `json:"userId"`
+}
+
+// Transfer はルールの担当者を同じギルドで編集権限を持つ別ユーザーへ変更する。
+func (h *RuleHandler) Transfer(c echo.Context) error {
+	userID := MustUserID(c)
+	rule := MustRule(c)
+
+	var payload transferPayload
+	if err := c.Bind(&payload); err != nil || payload.UserID == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "userId is required")
 	}
 
-	guilds, err := h.users.ListGuildPermissions(c.Request().Context(), userID)
+	ctx := c.Request().Context()
+	targetRole, err := h.authz.Role(ctx, payload.UserID, rule.GuildID)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to verify guild")
 	}
+	if !models.GuildRoleAtLeast(targetRole, models.GuildRoleEditor) {
+		return echo.NewHTTPError(http.StatusBadRequest, "new owner must be an editor of the guild")
+	}
+
+	previousOwner := rule.UserID
+	if err := h.rules.TransferOwnership(ctx, rule.ID, payload.UserID, userID); err != nil {
+		// 確認後にゴミ箱へ移動された場合
+		if errors.Is(err, sql.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "rule not found")
+		}
+		h.logger.Error(ctx, "database_error", "ルールの担当者変更に失敗", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to transfer rule")
+	}
+
+	metadata := ruleLogMetadata(*rule, userID)
+	metadata["previousOwnerId"] = previousOwner
+	metadata["newOwnerId"] = payload.UserID
+	h.logger.Info(ctx, "rule_transferred", "ルールの担当者を変更しました", metadata)
+	h.audit.Record(ctx, ruleAuditEvent(c, *rule, models.AuditActionRuleTransfer), map[string]any{"userId": previousOwner}, map[string]any{"userId": payload.UserID})
+
+	updated, err := h.rules.Get(ctx, rule.ID)
+	if err != nil || updated == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch rule")
+	}
+	return c.JSON(http.StatusOK, updated)
+}
+
+// ListRevisions はルールのリビジョンを新しい順に、直前のリビジョンとの差分付きで返す。
+func (h *RuleHandler) ListRevisions(c echo.Context) error {
+	rule := MustRule(c)
+	revisions, err := h.rules.ListRevisions(c.Request().Context(), rule.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch revisions")
+	}
 
-	for _, guild := range guilds {
-		if guild.GuildID == guildID {
-			if guild.CanManage {
-				return nil
-			}
-			return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
+	for i := range revisions {
+		var previous any
+		if i+1 < len(revisions) {
+			previous = revisions[i+1].Config
+		}
+		diff, err := revisionDiff(previous, revisions[i].Config)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to diff revisions")
 		}
+		revisions[i].Diff = diff
 	}
+	return c.JSON(http.StatusOK, revisions)
+}
 
-	return echo.NewHTTPError(http.StatusForbidden, "guild access denied")
+func revisionDiff(previous, current any) ([]byte, error) {
+	var before []byte
+	if previous != nil {
+		var err error
+		if before, err = json.Marshal(previous); err != nil {
+			return nil, err
+		}
+	}
+	after, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	return services.DiffJSON(before, after)
+}
+
+// RestoreRevision はルールの設定を指定したリビジョンの状態へ戻す。復元も新しいリビジョンとして記録する。
+func (h *RuleHandler) RestoreRevision(c echo.Context) error {
+	userID := MustUserID(c)
+	rule := MustRule(c)
+	revision, err := strconv.Atoi(c.Param("rev"))
+	if err != nil || revision <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid revision")
+	}
+
+	ctx := c.Request().Context()
+	rev, err := h.rules.GetRevision(ctx, rule.ID, revision)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch revision")
+	}
+	if rev == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "revision not found")
+	}
+
+	before := *rule
+	rev.Config.ApplyTo(rule)
+	rule.UpdatedBy = &userID
+	if err := h.rules.Restore(ctx, rule, revision); err != nil {
+		h.logger.Error(ctx, "database_error", "ルールの復元に失敗", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to restore rule")
+	}
+
+	metadata := ruleLogMetadata(*rule, userID)
+	metadata["revision"] = revision
+	h.logger.Info(ctx, "rule_restored", "ルールをリビジョンから復元しました", metadata)
+	h.audit.Record(ctx, ruleAuditEvent(c, *rule, models.AuditActionRuleRestore), before, rule)
+
+	return c.JSON(http.StatusOK, rule)
+}
+
+// normalizePayload はルール設定の各項目を検証し、既定値を補完する。
+func normalizePayload(payload *rulePayload) error {
+	if err := normalizeFilters(payload); err != nil {
+		return err
+	}
+	if err := normalizeMatchMode(payload); err != nil {
+		return err
+	}
+
+	switch {
+	case payload.FillHorizonHours == 0:
+		payload.FillHorizonHours = services.DefaultFillHorizonHours
+	case payload.FillHorizonHours < 0 || payload.FillHorizonHours > services.MaxFillHorizonHours:
+		return echo.NewHTTPError(http.StatusBadRequest, "fillHorizonHours must be between 1 and 168")
+	}
+
+	payload.BaselineMode = strings.TrimSpace(payload.BaselineMode)
+	switch payload.BaselineMode {
+	case "":
+		payload.BaselineMode = models.BaselineModeSilent
+	case models.BaselineModeSilent, models.BaselineModeSummary:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid baselineMode")
+	}
+
+	return nil
+}
+
+// normalizeFilters は開催地・日時フィルタを検証し、既定値を補完する。
+func normalizeFilters(payload *rulePayload) error {
+	payload.VenueType = strings.TrimSpace(payload.VenueType)
+	switch payload.VenueType {
+	case "":
+		payload.VenueType = models.VenueTypeAny
+	case models.VenueTypeAny, models.VenueTypeOnline, models.VenueTypeOffline:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid venueType")
+	}
+
+	prefectures := make([]string, 0, len(payload.Prefectures))
+	for _, code := range payload.Prefectures {
+		code = strings.ToLower(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+		if !services.IsValidPrefecture(code) {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid prefecture: "+code)
+		}
+		prefectures = append(prefectures, code)
+	}
+	if payload.VenueType == models.VenueTypeOnline && len(prefectures) > 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "prefectures cannot be combined with online venueType")
+	}
+	payload.Prefectures = prefectures
+
+	for _, day := range payload.Weekdays {
+		if day < 0 || day > 6 {
+			return echo.NewHTTPError(http.StatusBadRequest, "weekdays must be between 0 (Sunday) and 6 (Saturday)")
+		}
+	}
+	if payload.Weekdays == nil {
+		payload.Weekdays = []int{}
+	}
+
+	payload.TimeFrom = strings.TrimSpace(payload.TimeFrom)
+	payload.TimeTo = strings.TrimSpace(payload.TimeTo)
+	if !services.IsValidClock(payload.TimeFrom) || !services.IsValidClock(payload.TimeTo) {
+		return echo.NewHTTPError(http.StatusBadRequest, "timeFrom and timeTo must be HH:MM")
+	}
+
+	if payload.WithinDays < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "withinDays must not be negative")
+	}
+
+	return nil
+}
+
+// normalizeMatchMode はローカル照合モードとパターンを検証する。
+func normalizeMatchMode(payload *rulePayload) error {
+	payload.MatchMode = strings.TrimSpace(payload.MatchMode)
+	switch payload.MatchMode {
+	case "":
+		payload.MatchMode = models.MatchModeOff
+	case models.MatchModeOff, models.MatchModeNormalized, models.MatchModeRegex, models.MatchModeFuzzy:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid matchMode")
+	}
+
+	payload.MatchPatterns = trimValues(payload.MatchPatterns)
+	patterns := payload.MatchPatterns
+	if len(patterns) == 0 {
+		patterns = payload.Keywords
+	}
+	for _, pattern := range patterns {
+		if err := services.CompilePattern(payload.MatchMode, pattern); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	// fuzzyDistanceの0（省略）は既定値1として扱う
+	switch {
+	case payload.MatchMode != models.MatchModeFuzzy:
+		payload.FuzzyDistance = 0
+	case payload.FuzzyDistance == 0:
+		payload.FuzzyDistance = 1
+	case payload.FuzzyDistance < 1 || payload.FuzzyDistance > services.MaxFuzzyDistance:
+		return echo.NewHTTPError(http.StatusBadRequest, "fuzzyDistance must be between 1 and 3 (0 or omitted uses 1); the effective distance is capped at pattern length / 4")
+	}
+
+	return nil
+}
+
+func trimValues(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if trimmed := strings.TrimSpace(v); trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
 }
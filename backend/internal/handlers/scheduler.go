@@ -10,18 +10,25 @@ import (
 
 type SchedulerHandler struct {
 	scheduler *services.SchedulerService
+	authz     *Authorizer
 }
 
-func NewSchedulerHandler(scheduler *services.SchedulerService) *SchedulerHandler {
+func NewSchedulerHandler(scheduler *services.SchedulerService, authz *Authorizer) *SchedulerHandler {
 	return &SchedulerHandler{
 		scheduler: scheduler,
+		authz:     authz,
 	}
 }
 
+// RunNow は全ギルドのルールを即時実行する。システム管理者のみ。
 func (h *SchedulerHandler) RunNow(c echo.Context) error {
+	if err := h.authz.RequireSystemAdmin(c); err != nil {
+		return err
+	}
 	ctx := c.Request().Context()
 
-	if err := h.scheduler.Run(ctx); err != nil {
+	stats, err := h.scheduler.Run(ctx)
+	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]any{
 			"message": "スケジューラーの実行に失敗しました",
 			"error":   err.Error(),
@@ -30,6 +37,7 @@ func (h *SchedulerHandler) RunNow(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, map[string]any{
 		"message": "スケジューラーを実行しました",
+		"stats":   stats,
 	})
 }
 
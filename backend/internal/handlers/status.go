@@ -10,11 +10,12 @@ import (
 
 // StatusHandler はスケジューラ状態取得API。
 type StatusHandler struct {
-	logs *repository.LogRepository
+	logs  *repository.LogRepository
+	authz *Authorizer
 }
 
-func NewStatusHandler(logs *repository.LogRepository) *StatusHandler {
-	return &StatusHandler{logs: logs}
+func NewStatusHandler(logs *repository.LogRepository, authz *Authorizer) *StatusHandler {
+	return &StatusHandler{logs: logs, authz: authz}
 }
 
 // RegisterStatusRoutes はステータス関連ルートを登録。
@@ -22,6 +23,7 @@ func RegisterStatusRoutes(g *echo.Group, handler *StatusHandler) {
 	g.GET("/status", handler.GetStatus)
 }
 
+// GetStatus はスケジューラの状態を返す。エラー詳細は他ギルドの情報を含みうるためシステム管理者のみに返す。
 func (h *StatusHandler) GetStatus(c echo.Context) error {
 	status, err := h.logs.GetSchedulerStatus(c.Request().Context())
 	if err != nil {
@@ -31,5 +33,13 @@ func (h *StatusHandler) GetStatus(c echo.Context) error {
 		return c.JSON(http.StatusOK, map[string]any{"status": "unknown"})
 	}
 
+	admin, err := h.authz.IsSystemAdmin(c)
+	if err != nil {
+		return err
+	}
+	if !admin && status.LastError != "" {
+		status.LastError = "scheduler run failed"
+	}
+
 	return c.JSON(http.StatusOK, status)
 }
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"connpass-requirement/internal/models"
+	"connpass-requirement/internal/repository"
+	"connpass-requirement/internal/services"
+)
+
+// テンプレートのname・descriptionで置き換えるプレースホルダ
+const (
+	templateKeywordsPlaceholder = "{{keywords}}"
+	templateLocationPlaceholder = "{{location}}"
+)
+
+// TemplateHandler はルールテンプレートAPI。
+type TemplateHandler struct {
+	templates *repository.RuleTemplateRepository
+	rules     *repository.RuleRepository
+	authz     *Authorizer
+	discord   *services.DiscordService
+	logger    *services.LoggerService
+	audit     *services.AuditService
+}
+
+func NewTemplateHandler(
+	templates *repository.RuleTemplateRepository,
+	rules *repository.RuleRepository,
+	authz *Authorizer,
+	discord *services.DiscordService,
+	logger *services.LoggerService,
+	audit *services.AuditService,
+) *TemplateHandler {
+	return &TemplateHandler{templates: templates, rules: rules, authz: authz, discord: discord, logger: logger, audit: audit}
+}
+
+// RegisterTemplateRoutes はテンプレート関連ルートを登録する。
+func RegisterTemplateRoutes(g *echo.Group, handler *TemplateHandler) {
+	viewer := handler.authz.RequireGuildRole(models.GuildRoleViewer, GuildFromParam("guildId"))
+	editor := handler.authz.RequireGuildRole(models.GuildRoleEditor, GuildFromParam("guildId"))
+
+	g.GET("/guilds/:guildId/templates", handler.List, viewer)
+	g.POST("/guilds/:guildId/templates", handler.Create, editor)
+	g.DELETE("/guilds/:guildId/templates/:id", handler.Delete, editor)
+	g.POST("/guilds/:guildId/templates/:id/instantiate", handler.Instantiate, editor)
+	g.GET("/templates/popular", handler.ListPopular)
+	g.POST("/templates/:id/promote", handler.Promote)
+}
+
+func (h *TemplateHandler) List(c echo.Context) error {
+	templates, err := h.templates.ListForGuild(c.Request().Context(), c.Param("guildId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch templates")
+	}
+	return c.JSON(http.StatusOK, templates)
+}
+
+type templatePayload struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	RuleID      int64              `json:"ruleId"`
+	Config      *models.RuleConfig `json:"config"`
+}
+
+// Create はギルド独自のテンプレートを登録する。configを直接指定するか、ruleIdで既存ルールの設定を元にする。
+func (h *TemplateHandler) Create(c echo.Context) error {
+	guildID := c.Param("guildId")
+	var payload templatePayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid body")
+	}
+	payload.Name = strings.TrimSpace(payload.Name)
+	if payload.Name == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "name is required")
+	}
+
+	var config models.RuleConfig
+	switch {
+	case payload.RuleID != 0:
+		rule, err := h.authz.Rule(c, payload.RuleID, models.GuildRoleViewer)
+		if err != nil {
+			return err
+		}
+		if rule.GuildID != guildID {
+			return echo.NewHTTPError(http.StatusBadRequest, "rule belongs to another guild")
+		}
+		config = models.RuleConfigOf(*rule)
+	case payload.Config != nil:
+		config = *payload.Config
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "config or ruleId is required")
+	}
+
+	rulePayload := payloadFromConfig(guildID, config)
+	if err := normalizePayload(&rulePayload); err != nil {
+		return err
+	}
+	config = models.RuleConfigOf(ruleFromPayload(0, rulePayload))
+	config.ChannelID, config.ChannelName = "", ""
+
+	userID := MustUserID(c)
+	template := models.RuleTemplate{
+		GuildID:     &guildID,
+		Name:        payload.Name,
+		Description: strings.TrimSpace(payload.Description),
+		Config:      config,
+		CreatedBy:   &userID,
+	}
+	if err := h.templates.Create(c.Request().Context(), &template); err != nil {
+		h.logger.Error(c.Request().Context(), "database_error", "テンプレート登録に失敗", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create template")
+	}
+	event := newAuditEvent(c, guildID, models.AuditActionTemplateCreate, models.AuditTargetTemplate, strconv.FormatInt(template.ID, 10))
+	h.audit.Record(c.Request().Context(), event, nil, template)
+
+	return c.JSON(http.StatusCreated, template)
+}
+
+func (h *TemplateHandler) Delete(c echo.Context) error {
+	guildID := c.Param("guildId")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid template id")
+	}
+
+	ctx := c.Request().Context()
+	template, err := h.guildTemplate(c, id)
+	if err != nil {
+		return err
+	}
+	if template.System {
+		return echo.NewHTTPError(http.StatusForbidden, "system templates cannot be deleted")
+	}
+	if _, err := h.templates.Delete(ctx, guildID, id); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete template")
+	}
+	event := newAuditEvent(c, guildID, models.AuditActionTemplateDelete, models.AuditTargetTemplate, c.Param("id"))
+	h.audit.Record(ctx, event, template, nil)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+type instantiatePayload struct {
+	Name      string   `json:"name"`
+	Channel   string   `json:"channel"`
+	ChannelID string   `json:"channelId"`
+	Location  string   `json:"location"`
+	Keywords  []string `json:"keywords"`
+	IsActive  *bool    `json:"isActive"`
+}
+
+// Instantiate はテンプレートからルールを作成する。チャンネル・開催地・キーワードを置き換え、利用回数を記録する。
+func (h *TemplateHandler) Instantiate(c echo.Context) error {
+	if h.discord == nil {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "discord integration is disabled")
+	}
+	userID := MustUserID(c)
+	guildID := c.Param("guildId")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid template id")
+	}
+	var payload instantiatePayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid body")
+	}
+
+	template, err := h.guildTemplate(c, id)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	channels, err := h.discord.ListTextChannels(ctx, guildID)
+	if err != nil {
+		if errors.Is(err, services.ErrMissingAccess) {
+			return echo.NewHTTPError(http.StatusForbidden, "bot does not have access to this guild")
+		}
+		return echo.NewHTTPError(http.StatusBadGateway, "failed to fetch channels")
+	}
+	channelNames := make(map[string]string, len(channels))
+	channelIDs := make(map[string][]string, len(channels))
+	for _, ch := range channels {
+		channelNames[ch.ID] = ch.Name
+		channelIDs[ch.Name] = append(channelIDs[ch.Name], ch.ID)
+	}
+	channelID, channelName, err := resolveImportChannel(ruleDocumentRule{Channel: payload.Channel, ChannelID: payload.ChannelID}, channelNames, channelIDs)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	config := applyTemplateParams(template.Config, payload)
+	config.ChannelID, config.ChannelName = channelID, channelName
+
+	rulePayload := payloadFromConfig(guildID, config)
+	if len(trimValues(rulePayload.Keywords)) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "keywords are required")
+	}
+	if strings.TrimSpace(rulePayload.Name) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "name is required")
+	}
+	if err := normalizePayload(&rulePayload); err != nil {
+		return err
+	}
+
+	rule := ruleFromPayload(userID, rulePayload)
+	if err := h.rules.Create(ctx, &rule); err != nil {
+		h.logger.Error(ctx, "database_error", "テンプレートからのルール作成に失敗", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create rule")
+	}
+	if err := h.templates.RecordUse(ctx, template.ID, guildID, rule.ID, userID); err != nil {
+		h.logger.Error(ctx, "database_error", "テンプレート利用の記録に失敗", map[string]any{"guildId": guildID, "templateId": template.ID, "error": err.Error()})
+	}
+
+	metadata := ruleLogMetadata(rule, userID)
+	metadata["templateId"] = template.ID
+	h.logger.Info(ctx, "rule_created", "テンプレートからルールを作成しました", metadata)
+	h.audit.Record(ctx, ruleAuditEvent(c, rule, models.AuditActionRuleCreate), nil, rule)
+
+	return c.JSON(http.StatusCreated, rule)
+}
+
+// ListPopular はギルド独自テンプレートを、同じ設定を利用したギルド数の多い順に返す。システム管理者専用。
+func (h *TemplateHandler) ListPopular(c echo.Context) error {
+	if err := h.authz.RequireSystemAdmin(c); err != nil {
+		return err
+	}
+	templates, err := h.templates.ListPopular(c.Request().Context(), 50)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch templates")
+	}
+	return c.JSON(http.StatusOK, templates)
+}
+
+// Promote はギルド独自テンプレートをシステム提供に昇格させ、全ギルドで使えるようにする。システム管理者専用。
+func (h *TemplateHandler) Promote(c echo.Context) error {
+	if err := h.authz.RequireSystemAdmin(c); err != nil {
+		return err
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid template id")
+	}
+
+	ctx := c.Request().Context()
+	template, err := h.templates.Get(ctx, id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch template")
+	}
+	if template == nil || template.System {
+		return echo.NewHTTPError(http.StatusNotFound, "template not found")
+	}
+	if _, err := h.templates.Promote(ctx, id); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to promote template")
+	}
+	event := newAuditEvent(c, *template.GuildID, models.AuditActionTemplatePromote, models.AuditTargetTemplate, c.Param("id"))
+	h.audit.Record(ctx, event, map[string]any{"system": false}, map[string]any{"system": true})
+
+	promoted, err := h.templates.Get(ctx, id)
+	if err != nil || promoted == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch template")
+	}
+	return c.JSON(http.StatusOK, promoted)
+}
+
+// guildTemplate はギルドから利用できるテンプレート（システム提供または自ギルドのもの）を返す。
+func (h *TemplateHandler) guildTemplate(c echo.Context, id int64) (*models.RuleTemplate, error) {
+	template, err := h.templates.Get(c.Request().Context(), id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch template")
+	}
+	if template == nil || (!template.System && *template.GuildID != c.Param("guildId")) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "template not found")
+	}
+	return template, nil
+}
+
+// applyTemplateParams はテンプレートの設定へ指定されたパラメータを反映し、プレースホルダを置き換える。
+func applyTemplateParams(config models.RuleConfig, params instantiatePayload) models.RuleConfig {
+	if keywords := trimValues(params.Keywords); len(keywords) > 0 {
+		config.Keywords = keywords
+	}
+	if location := strings.TrimSpace(params.Location); location != "" {
+		config.Location = location
+	}
+	if params.IsActive != nil {
+		config.IsActive = *params.IsActive
+	}
+
+	replacer := strings.NewReplacer(
+		templateKeywordsPlaceholder, strings.Join(config.Keywords, "・"),
+		templateLocationPlaceholder, config.Location,
+	)
+	config.Name = strings.TrimSpace(replacer.Replace(config.Name))
+	config.Description = strings.TrimSpace(replacer.Replace(config.Description))
+	if name := strings.TrimSpace(params.Name); name != "" {
+		config.Name = name
+	}
+	return config
+}
+
+// payloadFromConfig はルール設定を検証用のペイロードへ変換する。
+func payloadFromConfig(guildID string, config models.RuleConfig) rulePayload {
+	return rulePayload{
+		GuildID:          guildID,
+		ChannelID:        config.ChannelID,
+		ChannelName:      config.ChannelName,
+		Name:             config.Name,
+		Description:      config.Description,
+		Location:         config.Location,
+		Prefectures:      config.Prefectures,
+		VenueType:        config.VenueType,
+		Weekdays:         config.Weekdays,
+		TimeFrom:         config.TimeFrom,
+		TimeTo:           config.TimeTo,
+		WithinDays:       config.WithinDays,
+		MatchMode:        config.MatchMode,
+		MatchPatterns:    config.MatchPatterns,
+		FuzzyDistance:    config.FuzzyDistance,
+		CapacityThresh:   config.CapacityThresh,
+		FillHorizonHours: config.FillHorizonHours,
+		Keywords:         config.Keywords,
+		ExcludeKeywords:  config.ExcludeKeywords,
+		ExcludeOwners:    config.ExcludeOwners,
+		ExcludeSeries:    config.ExcludeSeries,
+		NotifyTypes:      config.NotifyTypes,
+		IsActive:         config.IsActive,
+		BaselineMode:     config.BaselineMode,
+	}
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"connpass-requirement/internal/models"
+	"connpass-requirement/internal/repository"
+	"connpass-requirement/internal/services"
+)
+
+// WebhookHandler はギルドの送信Webhook管理API。
+type WebhookHandler struct {
+	webhooks *repository.WebhookRepository
+	authz    *Authorizer
+	service  *services.WebhookService
+	logger   *services.LoggerService
+	audit    *services.AuditService
+}
+
+func NewWebhookHandler(webhooks *repository.WebhookRepository, authz *Authorizer, service *services.WebhookService, logger *services.LoggerService, audit *services.AuditService) *WebhookHandler {
+	return &WebhookHandler{webhooks: webhooks, authz: authz, service: service, logger: logger, audit: audit}
+}
+
+// RegisterWebhookRoutes はWebhook関連ルートを登録する。ギルドのadminロールが必要。
+func RegisterWebhookRoutes(g *echo.Group, handler *WebhookHandler) {
+	hooks := g.Group("/guilds/:guildId/webhooks", handler.authz.RequireGuildRole(models.GuildRoleAdmin, GuildFromParam("guildId")))
+	hooks.GET("", handler.List)
+	hooks.POST("", handler.Create)
+	hooks.PUT("/:id", handler.Update)
+	hooks.DELETE("/:id", handler.Delete)
+	hooks.GET("/:id/deliveries", handler.ListDeliveries)
+	hooks.POST("/:id/deliveries/:deliveryId/replay", handler.Replay)
+}
+
+type webhookPayload struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"eventTypes"`
+	IsActive   *bool    `json:"isActive"`
+}
+
+func (h *WebhookHandler) List(c echo.Context) error {
+	guildID := c.Param("guildId")
+	webhooks, err := h.webhooks.ListByGuild(c.Request().Context(), guildID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch webhooks")
+	}
+	return c.JSON(http.StatusOK, webhooks)
+}
+
+// Create はWebhookを登録する。署名用シークレットは登録時のレスポンスでのみ返す。
+func (h *WebhookHandler) Create(c echo.Context) error {
+	guildID := c.Param("guildId")
+	var payload webhookPayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid body")
+	}
+	if err := normalizeWebhookPayload(c.Request().Context(), &payload); err != nil {
+		return err
+	}
+
+	secret, err := services.GenerateWebhookSecret()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate secret")
+	}
+	userID := MustUserID(c)
+	webhook := models.Webhook{
+		GuildID:    guildID,
+		URL:        payload.URL,
+		Secret:     secret,
+		EventTypes: payload.EventTypes,
+		IsActive:   payload.IsActive == nil || *payload.IsActive,
+		CreatedBy:  &userID,
+	}
+	if err := h.webhooks.Create(c.Request().Context(), &webhook); err != nil {
+		h.logger.Error(c.Request().Context(), "database_error", "Webhook登録に失敗", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create webhook")
+	}
+	h.recordAudit(c, models.AuditActionWebhookCreate, webhook.ID, nil, webhook)
+
+	return c.JSON(http.StatusCreated, map[string]any{
+		"webhook": webhook,
+		"secret":  secret,
+	})
+}
+
+func (h *WebhookHandler) Update(c echo.Context) error {
+	webhook, err := h.guildWebhook(c)
+	if err != nil {
+		return err
+	}
+	var payload webhookPayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid body")
+	}
+	if err := normalizeWebhookPayload(c.Request().Context(), &payload); err != nil {
+		return err
+	}
+
+	before := *webhook
+	webhook.URL = payload.URL
+	webhook.EventTypes = payload.EventTypes
+	if payload.IsActive != nil {
+		webhook.IsActive = *payload.IsActive
+	}
+	if err := h.webhooks.Update(c.Request().Context(), webhook); err != nil {
+		h.logger.Error(c.Request().Context(), "database_error", "Webhook更新に失敗", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update webhook")
+	}
+	h.recordAudit(c, models.AuditActionWebhookUpdate, webhook.ID, before, webhook)
+	return c.JSON(http.StatusOK, webhook)
+}
+
+func (h *WebhookHandler) Delete(c echo.Context) error {
+	webhook, err := h.guildWebhook(c)
+	if err != nil {
+		return err
+	}
+	if err := h.webhooks.Delete(c.Request().Context(), webhook.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete webhook")
+	}
+	h.recordAudit(c, models.AuditActionWebhookDelete, webhook.ID, webhook, nil)
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) ListDeliveries(c echo.Context) error {
+	webhook, err := h.guildWebhook(c)
+	if err != nil {
+		return err
+	}
+	limit := 50
+	if v := c.QueryParam("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 || parsed > 200 {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be between 1 and 200")
+		}
+		limit = parsed
+	}
+
+	deliveries, err := h.webhooks.ListDeliveries(c.Request().Context(), webhook.ID, limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch deliveries")
+	}
+	return c.JSON(http.StatusOK, deliveries)
+}
+
+// Replay は過去の配信を同じ本文で再送する。
+func (h *WebhookHandler) Replay(c echo.Context) error {
+	webhook, err := h.guildWebhook(c)
+	if err != nil {
+		return err
+	}
+	deliveryID, err := strconv.ParseInt(c.Param("deliveryId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid deliveryId")
+	}
+
+	original, err := h.webhooks.GetDelivery(c.Request().Context(), deliveryID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch delivery")
+	}
+	if original == nil || original.WebhookID != webhook.ID {
+		return echo.NewHTTPError(http.StatusNotFound, "delivery not found")
+	}
+	if !webhook.IsActive {
+		return echo.NewHTTPError(http.StatusConflict, "webhook is disabled")
+	}
+
+	delivery, err := h.service.Replay(c.Request().Context(), *original)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to replay delivery")
+	}
+	h.recordAudit(c, models.AuditActionWebhookReplay, webhook.ID, nil, map[string]any{
+		"deliveryId": delivery.ID,
+		"replayOf":   original.ID,
+		"eventType":  original.EventType,
+	})
+	return c.JSON(http.StatusAccepted, delivery)
+}
+
+// recordAudit はWebhookの変更を監査ログに記録する。シークレットはJSONに含まれない。
+func (h *WebhookHandler) recordAudit(c echo.Context, action string, webhookID int64, before, after any) {
+	event := newAuditEvent(c, c.Param("guildId"), action, models.AuditTargetWebhook, strconv.FormatInt(webhookID, 10))
+	h.audit.Record(c.Request().Context(), event, before, after)
+}
+
+func (h *WebhookHandler) guildWebhook(c echo.Context) (*models.Webhook, error) {
+	guildID := c.Param("guildId")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+	}
+	webhook, err := h.webhooks.Get(c.Request().Context(), id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch webhook")
+	}
+	if webhook == nil || webhook.GuildID != guildID {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "webhook not found")
+	}
+	return webhook, nil
+}
+
+func normalizeWebhookPayload(ctx context.Context, payload *webhookPayload) error {
+	payload.URL = strings.TrimSpace(payload.URL)
+	if err := services.ValidateWebhookURL(ctx, payload.URL); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "url must be an https URL on a public host")
+	}
+
+	if len(payload.EventTypes) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "eventTypes is required")
+	}
+	seen := make(map[string]bool)
+	eventTypes := make([]string, 0, len(payload.EventTypes))
+	for _, eventType := range payload.EventTypes {
+		if !isWebhookEventType(eventType) {
+			return echo.NewHTTPError(http.StatusBadRequest, "unsupported event type: "+eventType)
+		}
+		if seen[eventType] {
+			continue
+		}
+		seen[eventType] = true
+		eventTypes = append(eventTypes, eventType)
+	}
+	payload.EventTypes = eventTypes
+	return nil
+}
+
+func isWebhookEventType(eventType string) bool {
+	for _, t := range models.WebhookEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// パーソナルアクセストークンのスコープ
+const (
+	ScopeRulesRead    = "rules:read"
+	ScopeRulesWrite   = "rules:write"
+	ScopeEventsRead   = "events:read"
+	ScopeLogsRead     = "logs:read"
+	ScopeSchedulerRun = "scheduler:run"
+)
+
+// APITokenScopes は発行時に指定できるスコープの一覧。
+var APITokenScopes = []string{ScopeRulesRead, ScopeRulesWrite, ScopeEventsRead, ScopeLogsRead, ScopeSchedulerRun}
+
+// IsValidAPITokenScope はスコープが定義済みか判定する。
+func IsValidAPITokenScope(scope string) bool {
+	for _, s := range APITokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIToken はスクリプトなどから利用するパーソナルアクセストークン。トークン本体はハッシュのみ保存する。
+type APIToken struct {
+	ID          int64      `db:"id" json:"id"`
+	UserID      int64      `db:"user_id" json:"userId"`
+	Name        string     `db:"name" json:"name"`
+	TokenPrefix string     `db:"token_prefix" json:"tokenPrefix"`
+	Scopes      []string   `db:"scopes" json:"scopes"`
+	ExpiresAt   time.Time  `db:"expires_at" json:"expiresAt"`
+	LastUsedAt  *time.Time `db:"last_used_at" json:"lastUsedAt,omitempty"`
+	CreatedAt   time.Time  `db:"created_at" json:"createdAt"`
+	RevokedAt   *time.Time `db:"revoked_at" json:"revokedAt,omitempty"`
+}
+
+// HasScope はトークンにスコープが付与されているか判定する。
+func (t APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APITokenUse はアクセストークンの利用履歴。
+type APITokenUse struct {
+	ID        int64     `db:"id" json:"id"`
+	TokenID   int64     `db:"token_id" json:"tokenId"`
+	UserID    int64     `db:"user_id" json:"userId"`
+	Method    string    `db:"method" json:"method"`
+	Path      string    `db:"path" json:"path"`
+	Status    int       `db:"status" json:"status"`
+	IPAddress string    `db:"ip_address" json:"ipAddress"`
+	UserAgent string    `db:"user_agent" json:"userAgent"`
+	UsedAt    time.Time `db:"used_at" json:"usedAt"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 監査ログの操作種別
+const (
+	AuditActionRuleCreate        = "rule.create"
+	AuditActionRuleUpdate        = "rule.update"
+	AuditActionRuleDelete        = "rule.delete"
+	AuditActionRuleTest          = "rule.test"
+	AuditActionRuleTransfer      = "rule.transfer"
+	AuditActionRuleRestore       = "rule.restore"
+	AuditActionRuleUndelete      = "rule.undelete"
+	AuditActionChannelCreate     = "channel.create"
+	AuditActionMemberRoleSet     = "role.member.set"
+	AuditActionMemberRoleDelete  = "role.member.delete"
+	AuditActionRoleMappingSet    = "role.mapping.set"
+	AuditActionRoleMappingDelete = "role.mapping.delete"
+	AuditActionTemplateCreate    = "template.create"
+	AuditActionTemplateDelete    = "template.delete"
+	AuditActionTemplatePromote   = "template.promote"
+	AuditActionWebhookCreate     = "webhook.create"
+	AuditActionWebhookUpdate     = "webhook.update"
+	AuditActionWebhookDelete     = "webhook.delete"
+	AuditActionWebhookReplay     = "webhook.replay"
+	AuditActionFeedTokenRotate   = "feed_token.rotate"
+	AuditActionFeedTokenRevoke   = "feed_token.revoke"
+)
+
+// 監査ログの対象種別
+const (
+	AuditTargetRule        = "rule"
+	AuditTargetChannel     = "channel"
+	AuditTargetMemberRole  = "member_role"
+	AuditTargetRoleMapping = "role_mapping"
+	AuditTargetTemplate    = "template"
+	AuditTargetWebhook     = "webhook"
+	AuditTargetFeedToken   = "feed_token"
+)
+
+// AuditEvent はギルド設定の変更履歴。誰が・いつ・何をどう変えたかを記録する。
+// Diffは変更のあった項目ごとの{before, after}。
+type AuditEvent struct {
+	ID          int64           `db:"id" json:"id"`
+	GuildID     string          `db:"guild_id" json:"guildId"`
+	ActorUserID *int64          `db:"actor_user_id" json:"actorUserId,omitempty"`
+	ActorName   string          `db:"-" json:"actorName,omitempty"`
+	APITokenID  *int64          `db:"api_token_id" json:"apiTokenId,omitempty"`
+	Action      string          `db:"action" json:"action"`
+	TargetType  string          `db:"target_type" json:"targetType"`
+	TargetID    string          `db:"target_id" json:"targetId"`
+	Before      json.RawMessage `db:"before" json:"before"`
+	After       json.RawMessage `db:"after" json:"after"`
+	Diff        json.RawMessage `db:"diff" json:"diff"`
+	IPAddress   string          `db:"ip_address" json:"ipAddress"`
+	UserAgent   string          `db:"user_agent" json:"userAgent"`
+	CreatedAt   time.Time       `db:"created_at" json:"createdAt"`
+}
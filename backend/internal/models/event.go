@@ -7,6 +7,7 @@ type Event struct {
 	ID            int64     `db:"id" json:"id"`
 	EventID       int64     `db:"event_id" json:"eventId"`
 	Title         string    `db:"title" json:"title"`
+	Catch         string    `db:"catch" json:"catch"`
 	EventURL      string    `db:"event_url" json:"eventUrl"`
 	StartedAt     time.Time `db:"started_at" json:"startedAt"`
 	EndedAt       time.Time `db:"ended_at" json:"endedAt"`
@@ -17,7 +18,22 @@ type Event struct {
 	RetrievedAt   time.Time `db:"retrieved_at" json:"retrievedAt"`
 	OwnerNickname string    `db:"owner_nickname" json:"ownerNickname"`
 	SeriesTitle   string    `db:"series_title" json:"seriesTitle"`
+	Address       string    `db:"address" json:"address"`
+	Place         string    `db:"place" json:"place"`
 	HashDigest    string    `db:"hash_digest" json:"hashDigest"`
+	Revision      int       `db:"revision" json:"revision"`
+	RevisedAt     time.Time `db:"revised_at" json:"revisedAt"`
+}
+
+// EventSnapshot はイベントの申込状況の時系列記録。ハッシュが変化した時点のみ追記される。
+type EventSnapshot struct {
+	ID         int64     `db:"id" json:"id"`
+	EventID    int64     `db:"event_id" json:"eventId"`
+	Limit      int       `db:"limit" json:"limit"`
+	Accepted   int       `db:"accepted" json:"accepted"`
+	Waiting    int       `db:"waiting" json:"waiting"`
+	HashDigest string    `db:"hash_digest" json:"hashDigest"`
+	ObservedAt time.Time `db:"observed_at" json:"observedAt"`
 }
 
 // Notification は通知済みイベントの履歴。
@@ -27,4 +43,17 @@ type Notification struct {
 	EventID   int64     `db:"event_id" json:"eventId"`
 	NotifyKey string    `db:"notify_key" json:"notifyKey"`
 	SentAt    time.Time `db:"sent_at" json:"sentAt"`
+	Baseline  bool      `db:"baseline" json:"baseline"`
+}
+
+// RuleEvent はルールごとのイベント一致履歴と、前回一致時点の状態。
+type RuleEvent struct {
+	RuleID         int64     `db:"rule_id" json:"ruleId"`
+	EventID        int64     `db:"event_id" json:"eventId"`
+	FirstMatchedAt time.Time `db:"first_matched_at" json:"firstMatchedAt"`
+	LastMatchedAt  time.Time `db:"last_matched_at" json:"lastMatchedAt"`
+	Limit          int       `db:"limit" json:"limit"`
+	Accepted       int       `db:"accepted" json:"accepted"`
+	Waiting        int       `db:"waiting" json:"waiting"`
+	HashDigest     string    `db:"hash_digest" json:"hashDigest"`
 }
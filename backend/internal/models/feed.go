@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// フィードの公開範囲。
+const (
+	FeedScopeRule  = "rule"
+	FeedScopeGuild = "guild"
+)
+
+// FeedToken はiCal等の購読フィードを認証するトークン。トークン本体はハッシュのみ保持する。
+type FeedToken struct {
+	ID         int64      `db:"id" json:"id"`
+	Scope      string     `db:"scope" json:"scope"`
+	RuleID     *int64     `db:"rule_id" json:"ruleId,omitempty"`
+	GuildID    string     `db:"guild_id" json:"guildId,omitempty"`
+	TokenHash  string     `db:"token_hash" json:"-"`
+	CreatedBy  *int64     `db:"created_by" json:"createdBy,omitempty"`
+	CreatedAt  time.Time  `db:"created_at" json:"createdAt"`
+	LastUsedAt *time.Time `db:"last_used_at" json:"lastUsedAt,omitempty"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// ギルドごとのアプリ内ロール。後ろほど強い権限を持つ。
+const (
+	GuildRoleViewer = "viewer"
+	GuildRoleEditor = "editor"
+	GuildRoleAdmin  = "admin"
+)
+
+var guildRoleRanks = map[string]int{
+	GuildRoleViewer: 1,
+	GuildRoleEditor: 2,
+	GuildRoleAdmin:  3,
+}
+
+// IsValidGuildRole はアプリ内ロールとして有効か判定する。
+func IsValidGuildRole(role string) bool {
+	return guildRoleRanks[role] > 0
+}
+
+// GuildRoleAtLeast はroleがrequired以上の権限か判定する。空文字はどのロールも満たさない。
+func GuildRoleAtLeast(role, required string) bool {
+	return guildRoleRanks[role] > 0 && guildRoleRanks[role] >= guildRoleRanks[required]
+}
+
+// HigherGuildRole は2つのロールのうち強い方を返す。
+func HigherGuildRole(a, b string) string {
+	if guildRoleRanks[b] > guildRoleRanks[a] {
+		return b
+	}
+	return a
+}
+
+// GuildMemberRole はユーザーへ個別に付与したロール。
+type GuildMemberRole struct {
+	GuildID         string    `db:"guild_id" json:"guildId"`
+	UserID          int64     `db:"user_id" json:"userId"`
+	DiscordUsername string    `db:"discord_username" json:"discordUsername"`
+	Role            string    `db:"role" json:"role"`
+	GrantedBy       *int64    `db:"granted_by" json:"grantedBy,omitempty"`
+	CreatedAt       time.Time `db:"created_at" json:"createdAt"`
+	UpdatedAt       time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+// GuildRoleMapping はDiscordのロールIDとアプリ内ロールの対応。
+type GuildRoleMapping struct {
+	GuildID       string    `db:"guild_id" json:"guildId"`
+	DiscordRoleID string    `db:"discord_role_id" json:"discordRoleId"`
+	Role          string    `db:"role" json:"role"`
+	CreatedBy     *int64    `db:"created_by" json:"createdBy,omitempty"`
+	CreatedAt     time.Time `db:"created_at" json:"createdAt"`
+}
@@ -7,6 +7,7 @@ type ImportantLog struct {
 	ID        int64     `db:"id" json:"id"`
 	Level     string    `db:"level" json:"level"`
 	EventType string    `db:"event_type" json:"eventType"`
+	GuildID   *string   `db:"guild_id" json:"guildId,omitempty"`
 	Message   string    `db:"message" json:"message"`
 	Metadata  string    `db:"metadata" json:"metadata"`
 	CreatedAt time.Time `db:"created_at" json:"createdAt"`
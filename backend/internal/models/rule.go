@@ -2,23 +2,70 @@ package models
 
 import "time"
 
-// Rule は通知ルールの基本情報。
+// 開催形式フィルタの値。
+const (
+	VenueTypeAny     = "any"
+	VenueTypeOnline  = "online"
+	VenueTypeOffline = "offline"
+)
+
+// キーワードのローカル照合モード。
+const (
+	MatchModeOff        = "off"
+	MatchModeNormalized = "normalized"
+	MatchModeRegex      = "regex"
+	MatchModeFuzzy      = "fuzzy"
+)
+
+// 初回実行時の既存イベントの扱い。
+const (
+	BaselineModeSilent  = "silent"
+	BaselineModeSummary = "summary"
+)
+
+// 除外リストの種別。
+const (
+	ExclusionKindKeyword = "keyword"
+	ExclusionKindOwner   = "owner"
+	ExclusionKindSeries  = "series"
+)
+
+// Rule は通知ルールの基本情報。ルールはギルドに属し、UserIDは現在の担当者を表す。
 type Rule struct {
-	ID             int64     `db:"id" json:"id"`
-	UserID         int64     `db:"user_id" json:"userId"`
-	GuildID        string    `db:"guild_id" json:"guildId"`
-	ChannelID      string    `db:"channel_id" json:"channelId"`
-	ChannelName    string    `db:"channel_name" json:"channelName"`
-	Name           string    `db:"name" json:"name"`
-	Description    string    `db:"description" json:"description"`
-	NotifyTypes    []string  `json:"notifyTypes"`
-	Keywords       []string  `json:"keywords"`
-	Tags           []string  `json:"tags"`
-	Location       string    `db:"location" json:"location"`
-	CapacityThresh int       `db:"capacity_threshold" json:"capacityThreshold"`
-	IsActive       bool      `db:"is_active" json:"isActive"`
-	CreatedAt      time.Time `db:"created_at" json:"createdAt"`
-	UpdatedAt      time.Time `db:"updated_at" json:"updatedAt"`
+	ID               int64      `db:"id" json:"id"`
+	UserID           int64      `db:"user_id" json:"userId"`
+	CreatedBy        *int64     `db:"created_by" json:"createdBy"`
+	UpdatedBy        *int64     `db:"updated_by" json:"updatedBy"`
+	GuildID          string     `db:"guild_id" json:"guildId"`
+	ChannelID        string     `db:"channel_id" json:"channelId"`
+	ChannelName      string     `db:"channel_name" json:"channelName"`
+	Name             string     `db:"name" json:"name"`
+	Description      string     `db:"description" json:"description"`
+	NotifyTypes      []string   `json:"notifyTypes"`
+	Keywords         []string   `json:"keywords"`
+	ExcludeKeywords  []string   `json:"excludeKeywords"`
+	ExcludeOwners    []string   `json:"excludeOwners"`
+	ExcludeSeries    []string   `json:"excludeSeries"`
+	Tags             []string   `json:"tags"`
+	Location         string     `db:"location" json:"location"`
+	Prefectures      []string   `json:"prefectures"`
+	VenueType        string     `db:"venue_type" json:"venueType"`
+	Weekdays         []int      `db:"weekdays" json:"weekdays"`
+	TimeFrom         string     `db:"time_from" json:"timeFrom"`
+	TimeTo           string     `db:"time_to" json:"timeTo"`
+	WithinDays       int        `db:"within_days" json:"withinDays"`
+	MatchMode        string     `db:"match_mode" json:"matchMode"`
+	MatchPatterns    []string   `json:"matchPatterns"`
+	FuzzyDistance    int        `db:"fuzzy_distance" json:"fuzzyDistance"`
+	CapacityThresh   int        `db:"capacity_threshold" json:"capacityThreshold"`
+	FillHorizonHours int        `db:"fill_horizon_hours" json:"fillHorizonHours"`
+	IsActive         bool       `db:"is_active" json:"isActive"`
+	BaselineMode     string     `db:"baseline_mode" json:"baselineMode"`
+	BaselineAt       *time.Time `db:"baseline_at" json:"baselineAt"`
+	CreatedAt        time.Time  `db:"created_at" json:"createdAt"`
+	UpdatedAt        time.Time  `db:"updated_at" json:"updatedAt"`
+	DeletedAt        *time.Time `db:"deleted_at" json:"deletedAt,omitempty"`
+	DeletedBy        *int64     `db:"deleted_by" json:"deletedBy,omitempty"`
 }
 
 // RuleKeyword はルールとキーワードのマッピング。
@@ -32,3 +79,10 @@ type RuleNotifyType struct {
 	RuleID    int64  `db:"rule_id"`
 	NotifyKey string `db:"notify_key"`
 }
+
+// RuleExclusion はルールの除外条件マッピング。
+type RuleExclusion struct {
+	RuleID int64  `db:"rule_id"`
+	Kind   string `db:"kind"`
+	Value  string `db:"value"`
+}
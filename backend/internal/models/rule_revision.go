@@ -0,0 +1,128 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// ルールリビジョンの作成契機
+const (
+	RuleRevisionCreate  = "create"
+	RuleRevisionUpdate  = "update"
+	RuleRevisionRestore = "restore"
+	// RuleRevisionInitial はリビジョン導入前から存在したルールの、最初の更新直前の状態。
+	RuleRevisionInitial = "initial"
+)
+
+// RuleConfig はリビジョンとして保存するルールの設定項目。担当者・ベースライン状態などの運用情報は含めない。
+type RuleConfig struct {
+	ChannelID        string   `json:"channelId"`
+	ChannelName      string   `json:"channelName"`
+	Name             string   `json:"name"`
+	Description      string   `json:"description"`
+	Location         string   `json:"location"`
+	Prefectures      []string `json:"prefectures"`
+	VenueType        string   `json:"venueType"`
+	Weekdays         []int    `json:"weekdays"`
+	TimeFrom         string   `json:"timeFrom"`
+	TimeTo           string   `json:"timeTo"`
+	WithinDays       int      `json:"withinDays"`
+	MatchMode        string   `json:"matchMode"`
+	MatchPatterns    []string `json:"matchPatterns"`
+	FuzzyDistance    int      `json:"fuzzyDistance"`
+	CapacityThresh   int      `json:"capacityThreshold"`
+	FillHorizonHours int      `json:"fillHorizonHours"`
+	Keywords         []string `json:"keywords"`
+	ExcludeKeywords  []string `json:"excludeKeywords"`
+	ExcludeOwners    []string `json:"excludeOwners"`
+	ExcludeSeries    []string `json:"excludeSeries"`
+	NotifyTypes      []string `json:"notifyTypes"`
+	IsActive         bool     `json:"isActive"`
+	BaselineMode     string   `json:"baselineMode"`
+}
+
+// RuleConfigOf はルールの設定項目を取り出す。一覧系の項目は保存時と同じく重複・空文字を除いて並べ替える。
+func RuleConfigOf(rule Rule) RuleConfig {
+	return RuleConfig{
+		ChannelID:        rule.ChannelID,
+		ChannelName:      rule.ChannelName,
+		Name:             rule.Name,
+		Description:      rule.Description,
+		Location:         rule.Location,
+		Prefectures:      sortedStrings(rule.Prefectures),
+		VenueType:        rule.VenueType,
+		Weekdays:         append([]int{}, rule.Weekdays...),
+		TimeFrom:         rule.TimeFrom,
+		TimeTo:           rule.TimeTo,
+		WithinDays:       rule.WithinDays,
+		MatchMode:        rule.MatchMode,
+		MatchPatterns:    sortedStrings(rule.MatchPatterns),
+		FuzzyDistance:    rule.FuzzyDistance,
+		CapacityThresh:   rule.CapacityThresh,
+		FillHorizonHours: rule.FillHorizonHours,
+		Keywords:         sortedStrings(rule.Keywords),
+		ExcludeKeywords:  sortedStrings(rule.ExcludeKeywords),
+		ExcludeOwners:    sortedStrings(rule.ExcludeOwners),
+		ExcludeSeries:    sortedStrings(rule.ExcludeSeries),
+		NotifyTypes:      sortedStrings(rule.NotifyTypes),
+		IsActive:         rule.IsActive,
+		BaselineMode:     rule.BaselineMode,
+	}
+}
+
+// ApplyTo は設定項目をルールへ書き戻す。
+func (c RuleConfig) ApplyTo(rule *Rule) {
+	rule.ChannelID = c.ChannelID
+	rule.ChannelName = c.ChannelName
+	rule.Name = c.Name
+	rule.Description = c.Description
+	rule.Location = c.Location
+	rule.Prefectures = c.Prefectures
+	rule.VenueType = c.VenueType
+	rule.Weekdays = c.Weekdays
+	rule.TimeFrom = c.TimeFrom
+	rule.TimeTo = c.TimeTo
+	rule.WithinDays = c.WithinDays
+	rule.MatchMode = c.MatchMode
+	rule.MatchPatterns = c.MatchPatterns
+	rule.FuzzyDistance = c.FuzzyDistance
+	rule.CapacityThresh = c.CapacityThresh
+	rule.FillHorizonHours = c.FillHorizonHours
+	rule.Keywords = c.Keywords
+	rule.ExcludeKeywords = c.ExcludeKeywords
+	rule.ExcludeOwners = c.ExcludeOwners
+	rule.ExcludeSeries = c.ExcludeSeries
+	rule.NotifyTypes = c.NotifyTypes
+	rule.IsActive = c.IsActive
+	rule.BaselineMode = c.BaselineMode
+}
+
+func sortedStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// RuleRevision はルール設定の不変な履歴。更新・復元のたびに新しい番号で追加する。
+// Diffは一覧取得時に直前のリビジョンと比較して求める。
+type RuleRevision struct {
+	ID            int64           `db:"id" json:"id"`
+	RuleID        int64           `db:"rule_id" json:"ruleId"`
+	Revision      int             `db:"revision" json:"revision"`
+	Action        string          `db:"action" json:"action"`
+	RestoredFrom  *int            `db:"restored_from" json:"restoredFrom,omitempty"`
+	Config        RuleConfig      `db:"config" json:"config"`
+	CreatedBy     *int64          `db:"created_by" json:"createdBy,omitempty"`
+	CreatedByName string          `db:"-" json:"createdByName,omitempty"`
+	CreatedAt     time.Time       `db:"created_at" json:"createdAt"`
+	Diff          json.RawMessage `db:"-" json:"diff"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// RuleTemplate はルール作成用のテンプレート。GuildIDがnilのものはシステム提供で全ギルドから利用できる。
+// Configのname・descriptionには{{keywords}}・{{location}}を埋め込める。チャンネルは作成時に指定する。
+type RuleTemplate struct {
+	ID          int64      `db:"id" json:"id"`
+	GuildID     *string    `db:"guild_id" json:"guildId,omitempty"`
+	System      bool       `db:"-" json:"system"`
+	Name        string     `db:"name" json:"name"`
+	Description string     `db:"description" json:"description"`
+	Config      RuleConfig `db:"config" json:"config"`
+	UseCount    int        `db:"use_count" json:"useCount"`
+	LastUsedAt  *time.Time `db:"last_used_at" json:"lastUsedAt,omitempty"`
+	CreatedBy   *int64     `db:"created_by" json:"createdBy,omitempty"`
+	PromotedAt  *time.Time `db:"promoted_at" json:"promotedAt,omitempty"`
+	CreatedAt   time.Time  `db:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updatedAt"`
+	// GuildCount は同じ設定のテンプレートを利用したギルド数。昇格候補の一覧でのみ設定する
+	GuildCount int `db:"-" json:"guildCount,omitempty"`
+}
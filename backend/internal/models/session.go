@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Session はログインごとのサーバー側セッション。IDはアクセストークンのjtiとして埋め込む。
+type Session struct {
+	ID         string     `db:"id" json:"id"`
+	UserID     int64      `db:"user_id" json:"userId"`
+	UserAgent  string     `db:"user_agent" json:"userAgent"`
+	IPAddress  string     `db:"ip_address" json:"ipAddress"`
+	CreatedAt  time.Time  `db:"created_at" json:"createdAt"`
+	LastSeenAt time.Time  `db:"last_seen_at" json:"lastSeenAt"`
+	ExpiresAt  time.Time  `db:"expires_at" json:"expiresAt"`
+	RevokedAt  *time.Time `db:"revoked_at" json:"revokedAt,omitempty"`
+	Current    bool       `db:"-" json:"current"`
+}
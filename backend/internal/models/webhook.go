@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Webhookで配信するシステムイベント種別。
+const (
+	WebhookEventNotificationSent   = "notification.sent"
+	WebhookEventNotificationFailed = "notification.failed"
+	WebhookEventRuleCreated        = "rule.created"
+	WebhookEventRuleUpdated        = "rule.updated"
+	WebhookEventRuleDeleted        = "rule.deleted"
+	WebhookEventSchedulerCompleted = "scheduler.run.completed"
+)
+
+// WebhookEventTypes は購読可能なイベント種別の一覧。
+var WebhookEventTypes = []string{
+	WebhookEventNotificationSent,
+	WebhookEventNotificationFailed,
+	WebhookEventRuleCreated,
+	WebhookEventRuleUpdated,
+	WebhookEventRuleDeleted,
+	WebhookEventSchedulerCompleted,
+}
+
+// Webhook配信の状態。
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook はギルド管理者が登録した外部送信先。
+type Webhook struct {
+	ID         int64     `db:"id" json:"id"`
+	GuildID    string    `db:"guild_id" json:"guildId"`
+	URL        string    `db:"url" json:"url"`
+	Secret     string    `db:"secret" json:"-"`
+	EventTypes []string  `db:"event_types" json:"eventTypes"`
+	IsActive   bool      `db:"is_active" json:"isActive"`
+	CreatedBy  *int64    `db:"created_by" json:"createdBy,omitempty"`
+	CreatedAt  time.Time `db:"created_at" json:"createdAt"`
+	UpdatedAt  time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+// WebhookDelivery はWebhookへの1件の配信と再試行状況。
+type WebhookDelivery struct {
+	ID             int64      `db:"id" json:"id"`
+	WebhookID      int64      `db:"webhook_id" json:"webhookId"`
+	EventID        string     `db:"event_id" json:"eventId"`
+	EventType      string     `db:"event_type" json:"eventType"`
+	Payload        string     `db:"payload" json:"payload"`
+	Status         string     `db:"status" json:"status"`
+	Attempts       int        `db:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time  `db:"next_attempt_at" json:"nextAttemptAt"`
+	ResponseStatus *int       `db:"response_status" json:"responseStatus,omitempty"`
+	LastError      string     `db:"last_error" json:"lastError,omitempty"`
+	ReplayOf       *int64     `db:"replay_of" json:"replayOf,omitempty"`
+	CreatedAt      time.Time  `db:"created_at" json:"createdAt"`
+	DeliveredAt    *time.Time `db:"delivered_at" json:"deliveredAt,omitempty"`
+}
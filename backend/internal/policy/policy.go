@@ -0,0 +1,177 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"connpass-requirement/internal/models"
+	"connpass-requirement/internal/repository"
+)
+
+var (
+	// ErrNotFound は対象が存在しないか、存在を明かすべきでない場合に返す。
+	ErrNotFound = errors.New("resource not found")
+	// ErrGuildAccessDenied はギルドに所属していない場合に返す。
+	ErrGuildAccessDenied = errors.New("guild access denied")
+	// ErrInsufficientRole はギルドロールが不足している場合に返す。
+	ErrInsufficientRole = errors.New("insufficient permissions")
+	// ErrSystemAdminRequired はシステム管理者のみ許可される操作で返す。
+	ErrSystemAdminRequired = errors.New("system admin required")
+)
+
+// Policy はリソースごとの認可判断を集約する。ハンドラは必ずここを経由して権限を確認する。
+type Policy struct {
+	users    *repository.UserRepository
+	roles    *repository.GuildRoleRepository
+	rules    *repository.RuleRepository
+	adminIDs map[string]struct{}
+}
+
+// New はPolicyを生成する。adminDiscordIDsはシステム管理者とするDiscordユーザーID。
+func New(users *repository.UserRepository, roles *repository.GuildRoleRepository, rules *repository.RuleRepository, adminDiscordIDs []string) *Policy {
+	adminIDs := make(map[string]struct{}, len(adminDiscordIDs))
+	for _, id := range adminDiscordIDs {
+		if id != "" {
+			adminIDs[id] = struct{}{}
+		}
+	}
+	return &Policy{users: users, roles: roles, rules: rules, adminIDs: adminIDs}
+}
+
+// GuildRole はユーザーのギルドでの実効ロールを返す。ギルドに所属していない場合は空文字。
+// Discordの管理権限・個別付与・Discordロール対応のうち最も強いロールを採用する。
+func (p *Policy) GuildRole(ctx context.Context, userID int64, guildID string) (string, error) {
+	perm, err := p.users.FindGuildPermission(ctx, userID, guildID)
+	if err != nil || perm == nil {
+		return "", err
+	}
+	return p.roleFor(ctx, *perm)
+}
+
+func (p *Policy) roleFor(ctx context.Context, perm models.GuildPermission) (string, error) {
+	role := models.GuildRoleViewer
+	if perm.CanManage {
+		role = models.GuildRoleAdmin
+	}
+
+	granted, err := p.roles.FindMemberRole(ctx, perm.GuildID, perm.UserID)
+	if err != nil {
+		return "", err
+	}
+	role = models.HigherGuildRole(role, granted)
+
+	mapped, err := p.roles.ListMappedRoles(ctx, perm.GuildID, perm.RoleIDs)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range mapped {
+		role = models.HigherGuildRole(role, r)
+	}
+	return role, nil
+}
+
+// AttachRoles はギルド一覧の各要素へ実効ロールを設定する。
+func (p *Policy) AttachRoles(ctx context.Context, guilds []models.GuildPermission) error {
+	for i := range guilds {
+		role, err := p.roleFor(ctx, guilds[i])
+		if err != nil {
+			return err
+		}
+		guilds[i].Role = role
+	}
+	return nil
+}
+
+// RequireGuildRole はユーザーがギルドでrequired以上のロールを持つか検証し、実効ロールを返す。
+func (p *Policy) RequireGuildRole(ctx context.Context, userID int64, guildID, required string) (string, error) {
+	role, err := p.GuildRole(ctx, userID, guildID)
+	if err != nil {
+		return "", fmt.Errorf("resolve guild role: %w", err)
+	}
+	if role == "" {
+		return "", ErrGuildAccessDenied
+	}
+	if !models.GuildRoleAtLeast(role, required) {
+		return "", ErrInsufficientRole
+	}
+	return role, nil
+}
+
+// Rule はルールを読み込み、ユーザーがそのギルドでrequired以上のロールを持つか検証する。
+// ギルドに所属していない場合はルールの存在を明かさないためErrNotFoundを返す。
+func (p *Policy) Rule(ctx context.Context, userID, ruleID int64, required string) (*models.Rule, string, error) {
+	rule, err := p.rules.Get(ctx, ruleID)
+	if err != nil {
+		return nil, "", fmt.Errorf("get rule: %w", err)
+	}
+	if rule == nil {
+		return nil, "", ErrNotFound
+	}
+	role, err := p.RequireGuildRole(ctx, userID, rule.GuildID, required)
+	if errors.Is(err, ErrGuildAccessDenied) {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return rule, role, nil
+}
+
+// IsSystemAdmin はユーザーがシステム管理者か判定する。
+func (p *Policy) IsSystemAdmin(ctx context.Context, userID int64) (bool, error) {
+	if len(p.adminIDs) == 0 {
+		return false, nil
+	}
+	user, err := p.users.FindByID(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("find user: %w", err)
+	}
+	if user == nil {
+		return false, nil
+	}
+	_, ok := p.adminIDs[user.DiscordUserID]
+	return ok, nil
+}
+
+// RequireSystemAdmin はシステム管理者のみ許可される操作を検証する。
+func (p *Policy) RequireSystemAdmin(ctx context.Context, userID int64) error {
+	ok, err := p.IsSystemAdmin(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSystemAdminRequired
+	}
+	return nil
+}
+
+// LogScope はユーザーが閲覧できるログの範囲を返す。allがtrueの場合はギルドを特定できないログを含む全件。
+// guildIDを指定した場合はそのギルドのみに絞り込み、viewer以上であることを要求する。
+func (p *Policy) LogScope(ctx context.Context, userID int64, guildID string) (guildIDs []string, all bool, err error) {
+	admin, err := p.IsSystemAdmin(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if guildID != "" {
+		if !admin {
+			if _, err := p.RequireGuildRole(ctx, userID, guildID, models.GuildRoleViewer); err != nil {
+				return nil, false, err
+			}
+		}
+		return []string{guildID}, false, nil
+	}
+	if admin {
+		return nil, true, nil
+	}
+
+	guilds, err := p.users.ListGuildPermissions(ctx, userID)
+	if err != nil {
+		return nil, false, fmt.Errorf("list guilds: %w", err)
+	}
+	guildIDs = make([]string, 0, len(guilds))
+	for _, guild := range guilds {
+		guildIDs = append(guildIDs, guild.GuildID)
+	}
+	return guildIDs, false, nil
+}
@@ -0,0 +1,52 @@
+package policy
+
+import (
+	"net/http"
+
+	"connpass-requirement/internal/models"
+)
+
+// scopeAny はトークンが有効であればスコープを問わず許可するルート。
+const scopeAny = ""
+
+// tokenRouteScopes はパーソナルアクセストークンで呼び出せるルートと必要なスコープ。
+// ここにないルート（トークン・セッション・ロール・Webhookの管理など）はブラウザのセッションからのみ利用できる。
+var tokenRouteScopes = map[string]string{
+	http.MethodGet + " /api/auth/me":                                    scopeAny,
+	http.MethodGet + " /api/me/guilds":                                  models.ScopeRulesRead,
+	http.MethodGet + " /api/guilds/:guildId/channels":                   models.ScopeRulesRead,
+	http.MethodPost + " /api/guilds/:guildId/channels":                  models.ScopeRulesWrite,
+	http.MethodGet + " /api/rules":                                      models.ScopeRulesRead,
+	http.MethodPost + " /api/rules":                                     models.ScopeRulesWrite,
+	http.MethodPost + " /api/rules/simulate":                            models.ScopeRulesRead,
+	http.MethodGet + " /api/rules/:id":                                  models.ScopeRulesRead,
+	http.MethodPut + " /api/rules/:id":                                  models.ScopeRulesWrite,
+	http.MethodDelete + " /api/rules/:id":                               models.ScopeRulesWrite,
+	http.MethodPost + " /api/rules/:id/test":                            models.ScopeRulesWrite,
+	http.MethodPost + " /api/rules/:id/transfer":                        models.ScopeRulesWrite,
+	http.MethodGet + " /api/rules/:id/revisions":                        models.ScopeRulesRead,
+	http.MethodPost + " /api/rules/:id/revisions/:rev/restore":          models.ScopeRulesWrite,
+	http.MethodGet + " /api/guilds/:guildId/rules/export":               models.ScopeRulesRead,
+	http.MethodPost + " /api/guilds/:guildId/rules/import":              models.ScopeRulesWrite,
+	http.MethodGet + " /api/guilds/:guildId/templates":                  models.ScopeRulesRead,
+	http.MethodPost + " /api/guilds/:guildId/templates":                 models.ScopeRulesWrite,
+	http.MethodDelete + " /api/guilds/:guildId/templates/:id":           models.ScopeRulesWrite,
+	http.MethodPost + " /api/guilds/:guildId/templates/:id/instantiate": models.ScopeRulesWrite,
+	http.MethodGet + " /api/guilds/:guildId/rules/trash":                models.ScopeRulesRead,
+	http.MethodPost + " /api/guilds/:guildId/rules/trash/:id/restore":   models.ScopeRulesWrite,
+	http.MethodGet + " /api/events":                                     models.ScopeEventsRead,
+	http.MethodGet + " /api/events/:eventId/history":                    models.ScopeEventsRead,
+	http.MethodGet + " /api/logs":                                       models.ScopeLogsRead,
+	http.MethodGet + " /api/status":                                     models.ScopeLogsRead,
+	http.MethodPost + " /api/scheduler/run":                             models.ScopeSchedulerRun,
+}
+
+// TokenAllows はトークンでルートを呼び出せるか判定する。routeはechoのルートパターン（c.Path()）。
+// スコープに加えてギルドロールなど通常の認可も適用される。
+func TokenAllows(token models.APIToken, method, route string) bool {
+	scope, ok := tokenRouteScopes[method+" "+route]
+	if !ok {
+		return false
+	}
+	return scope == scopeAny || token.HasScope(scope)
+}
@@ -0,0 +1,47 @@
+package policy
+
+import (
+	"net/http"
+	"testing"
+
+	"connpass-requirement/internal/models"
+)
+
+func TestTokenAllows(t *testing.T) {
+	readOnly := models.APIToken{Scopes: []string{models.ScopeRulesRead}}
+	noScopes := models.APIToken{}
+
+	tests := []struct {
+		name   string
+		token  models.APIToken
+		method string
+		route  string
+		want   bool
+	}{
+		{name: "スコープが一致", token: readOnly, method: http.MethodGet, route: "/api/rules", want: true},
+		{name: "読み取りスコープで書き込み", token: readOnly, method: http.MethodPost, route: "/api/rules", want: false},
+		{name: "同じパスでもメソッドごとに判定", token: readOnly, method: http.MethodDelete, route: "/api/rules/:id", want: false},
+		{name: "simulateは読み取りスコープ", token: readOnly, method: http.MethodPost, route: "/api/rules/simulate", want: true},
+		{name: "スコープ不問のルート", token: noScopes, method: http.MethodGet, route: "/api/auth/me", want: true},
+		{name: "スコープなしのトークン", token: noScopes, method: http.MethodGet, route: "/api/rules", want: false},
+		{name: "別スコープ", token: readOnly, method: http.MethodGet, route: "/api/events", want: false},
+		{name: "トークン管理はセッションのみ", token: readOnly, method: http.MethodGet, route: "/api/tokens", want: false},
+		{name: "Webhook管理はセッションのみ", token: models.APIToken{Scopes: models.APITokenScopes}, method: http.MethodPost, route: "/api/guilds/:guildId/webhooks", want: false},
+		{name: "実パスではなくルートパターンで判定", token: readOnly, method: http.MethodGet, route: "/api/rules/1", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TokenAllows(tt.token, tt.method, tt.route); got != tt.want {
+				t.Errorf("TokenAllows(%v, %s %s) = %v, want %v", tt.token.Scopes, tt.method, tt.route, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenRouteScopesAreDefined(t *testing.T) {
+	for route, scope := range tokenRouteScopes {
+		if scope != scopeAny && !models.IsValidAPITokenScope(scope) {
+			t.Errorf("route %q requires undefined scope %q", route, scope)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"connpass-requirement/internal/models"
+)
+
+// APITokenRepository はパーソナルアクセストークンと利用履歴を扱う。
+type APITokenRepository struct {
+	db *sql.DB
+}
+
+func NewAPITokenRepository(db *sql.DB) *APITokenRepository {
+	return &APITokenRepository{db: db}
+}
+
+const apiTokenColumns = `
+	t.id, t.user_id, t.name, t.token_prefix, t.scopes, t.expires_at, t.last_used_at, t.created_at, t.revoked_at
+`
+
+func scanAPIToken(row rowScanner, t *models.APIToken) error {
+	var scopes pq.StringArray
+	if err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.TokenPrefix, &scopes, &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt, &t.RevokedAt); err != nil {
+		return err
+	}
+	t.Scopes = []string(scopes)
+	return nil
+}
+
+// Create はトークンを登録する。トークン本体はハッシュのみ保存する。
+func (r *APITokenRepository) Create(ctx context.Context, token *models.APIToken, tokenHash string) error {
+	if err := r.db.QueryRowContext(ctx, `
+	INSERT INTO api_tokens (user_id, name, token_prefix, token_hash, scopes, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at
+	`, token.UserID, token.Name, token.TokenPrefix, tokenHash, pq.Array(nonNilStrings(token.Scopes)), token.ExpiresAt).Scan(&token.ID, &token.CreatedAt); err != nil {
+		return fmt.Errorf("insert api token: %w", err)
+	}
+	return nil
+}
+
+// ListByUser はユーザーの未失効のトークンを新しい順に返す。期限切れのものも含める。
+func (r *APITokenRepository) ListByUser(ctx context.Context, userID int64) ([]models.APIToken, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT `+apiTokenColumns+`
+	FROM api_tokens t
+	WHERE t.user_id = $1 AND t.revoked_at IS NULL
+	ORDER BY t.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("select api tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		var t models.APIToken
+		if err := scanAPIToken(rows, &t); err != nil {
+			return nil, fmt.Errorf("scan api token: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// FindActiveByHash は失効・期限切れでないトークンと所有者のDiscordユーザーIDを返す。見つからない場合はnil。
+func (r *APITokenRepository) FindActiveByHash(ctx context.Context, tokenHash string) (*models.APIToken, string, error) {
+	var (
+		t             models.APIToken
+		discordUserID string
+		scopes        pq.StringArray
+	)
+	if err := r.db.QueryRowContext(ctx, `
+	SELECT `+apiTokenColumns+`, u.discord_user_id
+	FROM api_tokens t
+	JOIN users u ON u.id = t.user_id
+	WHERE t.token_hash = $1 AND t.revoked_at IS NULL AND t.expires_at > NOW()
+	`, tokenHash).Scan(&t.ID, &t.UserID, &t.Name, &t.TokenPrefix, &scopes, &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt, &t.RevokedAt, &discordUserID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("select api token: %w", err)
+	}
+	t.Scopes = []string(scopes)
+	return &t, discordUserID, nil
+}
+
+// Revoke はユーザーのトークンを失効させる。該当するトークンがない場合はfalse。
+func (r *APITokenRepository) Revoke(ctx context.Context, userID, id int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE api_tokens SET revoked_at = NOW()
+	WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("revoke api token: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("revoke api token rows: %w", err)
+	}
+	return n > 0, nil
+}
+
+// RecordUse は利用履歴を記録し、最終利用日時を更新する。
+func (r *APITokenRepository) RecordUse(ctx context.Context, use models.APITokenUse) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback() //nolint:errcheck
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `
+	INSERT INTO api_token_uses (token_id, user_id, method, path, status, ip_address, user_agent)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, use.TokenID, use.UserID, use.Method, use.Path, use.Status, use.IPAddress, use.UserAgent); err != nil {
+		return fmt.Errorf("insert api token use: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE api_tokens SET last_used_at = NOW() WHERE id = $1`, use.TokenID); err != nil {
+		return fmt.Errorf("touch api token: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ListUses はユーザーが所有するトークンの利用履歴を新しい順に返す。
+func (r *APITokenRepository) ListUses(ctx context.Context, userID, tokenID int64, limit int) ([]models.APITokenUse, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT id, token_id, user_id, method, path, status, ip_address, user_agent, used_at
+	FROM api_token_uses
+	WHERE token_id = $1 AND user_id = $2
+	ORDER BY used_at DESC
+	LIMIT $3
+	`, tokenID, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select api token uses: %w", err)
+	}
+	defer rows.Close()
+
+	uses := []models.APITokenUse{}
+	for rows.Next() {
+		var u models.APITokenUse
+		if err := rows.Scan(&u.ID, &u.TokenID, &u.UserID, &u.Method, &u.Path, &u.Status, &u.IPAddress, &u.UserAgent, &u.UsedAt); err != nil {
+			return nil, fmt.Errorf("scan api token use: %w", err)
+		}
+		uses = append(uses, u)
+	}
+	return uses, rows.Err()
+}
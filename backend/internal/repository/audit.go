@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"connpass-requirement/internal/models"
+)
+
+// AuditRepository はギルド設定の監査ログを扱う。
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// AuditSearchParams は監査ログの検索条件。ゼロ値の項目は条件に含めない。
+type AuditSearchParams struct {
+	GuildID     string
+	Action      string
+	ActorUserID int64
+	TargetType  string
+	TargetID    string
+	Since       time.Time
+	Until       time.Time
+	BeforeID    int64
+	Limit       int
+}
+
+// Create は監査ログを1件記録する。Before/Afterがnilの場合はNULLとして保存する。
+func (r *AuditRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	if err := r.db.QueryRowContext(ctx, `
+	INSERT INTO audit_events (guild_id, actor_user_id, api_token_id, action, target_type, target_id, before, after, diff, ip_address, user_agent)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	RETURNING id, created_at
+	`, event.GuildID, event.ActorUserID, event.APITokenID, event.Action, event.TargetType, event.TargetID,
+		nullableJSON(event.Before), nullableJSON(event.After), string(event.Diff), event.IPAddress, event.UserAgent,
+	).Scan(&event.ID, &event.CreatedAt); err != nil {
+		return fmt.Errorf("insert audit event: %w", err)
+	}
+	return nil
+}
+
+// Search はギルドの監査ログを新しい順に返す。
+func (r *AuditRepository) Search(ctx context.Context, params AuditSearchParams) ([]models.AuditEvent, error) {
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{`a.guild_id = ` + arg(params.GuildID)}
+	if params.Action != "" {
+		conditions = append(conditions, `a.action = `+arg(params.Action))
+	}
+	if params.ActorUserID != 0 {
+		conditions = append(conditions, `a.actor_user_id = `+arg(params.ActorUserID))
+	}
+	if params.TargetType != "" {
+		conditions = append(conditions, `a.target_type = `+arg(params.TargetType))
+	}
+	if params.TargetID != "" {
+		conditions = append(conditions, `a.target_id = `+arg(params.TargetID))
+	}
+	if !params.Since.IsZero() {
+		conditions = append(conditions, `a.created_at >= `+arg(params.Since))
+	}
+	if !params.Until.IsZero() {
+		conditions = append(conditions, `a.created_at < `+arg(params.Until))
+	}
+	if params.BeforeID != 0 {
+		conditions = append(conditions, `a.id < `+arg(params.BeforeID))
+	}
+
+	query := `
+	SELECT a.id, a.guild_id, a.actor_user_id, COALESCE(u.discord_username, ''), a.api_token_id, a.action, a.target_type, a.target_id,
+		a.before, a.after, a.diff, a.ip_address, a.user_agent, a.created_at
+	FROM audit_events a
+	LEFT JOIN users u ON u.id = a.actor_user_id
+	WHERE ` + strings.Join(conditions, ` AND `) + `
+	ORDER BY a.id DESC
+	LIMIT ` + arg(params.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]models.AuditEvent, 0, params.Limit)
+	for rows.Next() {
+		var (
+			e             models.AuditEvent
+			before, after []byte
+			diff          []byte
+		)
+		if err := rows.Scan(&e.ID, &e.GuildID, &e.ActorUserID, &e.ActorName, &e.APITokenID, &e.Action, &e.TargetType, &e.TargetID,
+			&before, &after, &diff, &e.IPAddress, &e.UserAgent, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan audit event: %w", err)
+		}
+		e.Before, e.After, e.Diff = before, after, diff
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// nullableJSON は空のJSONをNULLとして渡す。
+func nullableJSON(raw []byte) any {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	return string(raw)
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// encryptedPrefix は暗号化済みの値を平文と区別するための接頭辞。
+// v2は保存先（テーブル・カラム・行ID）を追加認証データに含める。v1はAADなしの旧形式で、復号のみ対応する。
+const (
+	encryptedPrefix       = "enc:v2:"
+	legacyEncryptedPrefix = "enc:v1:"
+)
+
+// ErrUnknownEncryptionKey は暗号化に使われた鍵IDが設定に存在しない場合に返す。
+var ErrUnknownEncryptionKey = errors.New("unknown encryption key")
+
+// Cipher は機密カラムをエンベロープ暗号化する。
+// 値ごとに生成したデータ鍵で本文をAES-GCM暗号化し、データ鍵を設定のマスター鍵で暗号化して保存する。
+// 形式: enc:v2:<鍵ID>:<暗号化したデータ鍵>:<暗号文>（いずれもnonce付き・base64url）
+// 値を別の行・カラムへ移し替えても復号できないよう、columnAADを追加認証データとして両方の暗号化に使う。
+type Cipher struct {
+	keys      map[string]cipher.AEAD
+	primaryID string
+}
+
+// NewCipher はマスター鍵（鍵ID→32バイトの鍵）からCipherを生成する。新規の暗号化にはprimaryIDの鍵を使う。
+// keysが空の場合は暗号化せず平文のまま扱う。
+func NewCipher(keys map[string][]byte, primaryID string) (*Cipher, error) {
+	c := &Cipher{keys: make(map[string]cipher.AEAD, len(keys)), primaryID: primaryID}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid encryption key id %q", id)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %s: %w", id, err)
+		}
+		c.keys[id] = aead
+	}
+	if len(c.keys) > 0 {
+		if _, ok := c.keys[primaryID]; !ok {
+			return nil, fmt.Errorf("primary encryption key %q is not configured", primaryID)
+		}
+	}
+	return c, nil
+}
+
+// Enabled は暗号化鍵が設定されているか返す。
+func (c *Cipher) Enabled() bool {
+	return c != nil && len(c.keys) > 0
+}
+
+// columnAAD は暗号化した値の保存先を表す追加認証データ（table:column:id）を返す。
+func columnAAD(table, column string, id int64) string {
+	return table + ":" + column + ":" + strconv.FormatInt(id, 10)
+}
+
+// Encrypt は値をaadに紐づけて暗号化する。空文字と、鍵が未設定の場合はそのまま返す。
+func (c *Cipher) Encrypt(plaintext, aad string) (string, error) {
+	if plaintext == "" || !c.Enabled() {
+		return plaintext, nil
+	}
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("generate data key: %w", err)
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	body, err := seal(dataAEAD, []byte(plaintext), []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(c.keys[c.primaryID], dataKey, []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + c.primaryID + ":" + wrapped + ":" + body, nil
+}
+
+// Decrypt は暗号化済みの値をaadで検証して復号する。接頭辞のない値は暗号化導入前の平文としてそのまま返す。
+// 旧形式（v1）の値はAADなしで復号する。
+func (c *Cipher) Decrypt(value, aad string) (string, error) {
+	var additional []byte
+	switch {
+	case strings.HasPrefix(value, encryptedPrefix):
+		value = strings.TrimPrefix(value, encryptedPrefix)
+		additional = []byte(aad)
+	case strings.HasPrefix(value, legacyEncryptedPrefix):
+		value = strings.TrimPrefix(value, legacyEncryptedPrefix)
+	default:
+		return value, nil
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	if c == nil {
+		return "", ErrUnknownEncryptionKey
+	}
+	master, ok := c.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownEncryptionKey, parts[0])
+	}
+	dataKey, err := open(master, parts[1], additional)
+	if err != nil {
+		return "", fmt.Errorf("unwrap data key: %w", err)
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataAEAD, parts[2], additional)
+	if err != nil {
+		return "", fmt.Errorf("decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsReencrypt は値が平文・旧形式、または現在の主鍵以外で暗号化されているか判定する。
+func (c *Cipher) NeedsReencrypt(value string) bool {
+	if value == "" || !c.Enabled() {
+		return false
+	}
+	return !strings.HasPrefix(value, encryptedPrefix+c.primaryID+":")
+}
+
+// Reencrypt は値を復号し、現在の主鍵とaadで暗号化し直す。
+func (c *Cipher) Reencrypt(value, aad string) (string, error) {
+	plaintext, err := c.Decrypt(value, aad)
+	if err != nil {
+		return "", err
+	}
+	return c.Encrypt(plaintext, aad)
+}
+
+// decryptColumns は同じ行の複数のカラム（カラム名→値）をまとめて復号する。
+func (c *Cipher) decryptColumns(table string, id int64, columns map[string]*string) error {
+	for column, v := range columns {
+		plaintext, err := c.Decrypt(*v, columnAAD(table, column, id))
+		if err != nil {
+			return fmt.Errorf("%s: %w", column, err)
+		}
+		*v = plaintext
+	}
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(aead cipher.AEAD, plaintext, additional []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, additional)), nil
+}
+
+func open(aead cipher.AEAD, encoded string, additional []byte) ([]byte, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := raw[:aead.NonceSize()], raw[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additional)
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestCipher(t *testing.T, keys map[string][]byte, primaryID string) *Cipher {
+	t.Helper()
+	c, err := NewCipher(keys, primaryID)
+	if err != nil {
+		t.Fatalf("NewCipher: %v", err)
+	}
+	return c
+}
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	c := newTestCipher(t, map[string][]byte{"k1": testKey(1)}, "k1")
+	aad := columnAAD("users", "access_token", 42)
+
+	encrypted, err := c.Encrypt("secret-token", aad)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !strings.HasPrefix(encrypted, encryptedPrefix+"k1:") || strings.Contains(encrypted, "secret-token") {
+		t.Fatalf("Encrypt() = %q, want an enc:v2 value for k1", encrypted)
+	}
+	if c.NeedsReencrypt(encrypted) {
+		t.Error("NeedsReencrypt() = true for a value encrypted with the primary key")
+	}
+
+	decrypted, err := c.Decrypt(encrypted, aad)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if decrypted != "secret-token" {
+		t.Errorf("Decrypt() = %q, want %q", decrypted, "secret-token")
+	}
+}
+
+func TestCipherRejectsOtherLocation(t *testing.T) {
+	c := newTestCipher(t, map[string][]byte{"k1": testKey(1)}, "k1")
+	encrypted, err := c.Encrypt("secret-token", columnAAD("users", "access_token", 42))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	for _, aad := range []string{
+		columnAAD("users", "access_token", 43),
+		columnAAD("users", "refresh_token", 42),
+		columnAAD("webhooks", "access_token", 42),
+	} {
+		if _, err := c.Decrypt(encrypted, aad); err == nil {
+			t.Errorf("Decrypt() with aad %q succeeded, want error", aad)
+		}
+	}
+}
+
+func TestCipherPassThrough(t *testing.T) {
+	disabled := newTestCipher(t, nil, "")
+	if got, err := disabled.Encrypt("plain", "aad"); err != nil || got != "plain" {
+		t.Errorf("Encrypt() without keys = (%q, %v), want plaintext", got, err)
+	}
+
+	c := newTestCipher(t, map[string][]byte{"k1": testKey(1)}, "k1")
+	if got, err := c.Encrypt("", "aad"); err != nil || got != "" {
+		t.Errorf("Encrypt(\"\") = (%q, %v), want empty", got, err)
+	}
+	if got, err := c.Decrypt("legacy-plaintext", "aad"); err != nil || got != "legacy-plaintext" {
+		t.Errorf("Decrypt(plaintext) = (%q, %v), want plaintext", got, err)
+	}
+	if !c.NeedsReencrypt("legacy-plaintext") {
+		t.Error("NeedsReencrypt(plaintext) = false, want true")
+	}
+}
+
+func TestCipherKeyRotation(t *testing.T) {
+	oldCipher := newTestCipher(t, map[string][]byte{"k1": testKey(1)}, "k1")
+	aad := columnAAD("webhooks", "secret", 7)
+	encrypted, err := oldCipher.Encrypt("whsec", aad)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	rotated := newTestCipher(t, map[string][]byte{"k1": testKey(1), "k2": testKey(2)}, "k2")
+	if !rotated.NeedsReencrypt(encrypted) {
+		t.Fatal("NeedsReencrypt() = false for a value encrypted with the old key")
+	}
+	reencrypted, err := rotated.Reencrypt(encrypted, aad)
+	if err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+	if !strings.HasPrefix(reencrypted, encryptedPrefix+"k2:") || rotated.NeedsReencrypt(reencrypted) {
+		t.Errorf("Reencrypt() = %q, want a value encrypted with k2", reencrypted)
+	}
+
+	newOnly := newTestCipher(t, map[string][]byte{"k2": testKey(2)}, "k2")
+	if got, err := newOnly.Decrypt(reencrypted, aad); err != nil || got != "whsec" {
+		t.Errorf("Decrypt() after removing k1 = (%q, %v), want %q", got, err, "whsec")
+	}
+	if _, err := newOnly.Decrypt(encrypted, aad); !errors.Is(err, ErrUnknownEncryptionKey) {
+		t.Errorf("Decrypt() with removed key error = %v, want ErrUnknownEncryptionKey", err)
+	}
+}
+
+func TestCipherDecryptsLegacyValues(t *testing.T) {
+	key := testKey(1)
+	c := newTestCipher(t, map[string][]byte{"k1": key}, "k1")
+
+	// v1はAADなしで同じ構造の値を保存していた
+	legacySeal := func(key, plaintext []byte) string {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, nil))
+	}
+	dataKey := testKey(9)
+	legacy := legacyEncryptedPrefix + "k1:" + legacySeal(key, dataKey) + ":" + legacySeal(dataKey, []byte("old-token"))
+
+	aad := columnAAD("users", "refresh_token", 1)
+	if got, err := c.Decrypt(legacy, aad); err != nil || got != "old-token" {
+		t.Fatalf("Decrypt(v1) = (%q, %v), want %q", got, err, "old-token")
+	}
+	if !c.NeedsReencrypt(legacy) {
+		t.Error("NeedsReencrypt(v1) = false, want true")
+	}
+	migrated, err := c.Reencrypt(legacy, aad)
+	if err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+	if got, err := c.Decrypt(migrated, aad); err != nil || got != "old-token" {
+		t.Errorf("Decrypt(migrated) = (%q, %v), want %q", got, err, "old-token")
+	}
+}
+
+func TestNewCipherValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      map[string][]byte
+		primaryID string
+	}{
+		{name: "鍵長が不正", keys: map[string][]byte{"k1": testKey(1)[:16]}, primaryID: "k1"},
+		{name: "鍵IDにコロン", keys: map[string][]byte{"k:1": testKey(1)}, primaryID: "k:1"},
+		{name: "主鍵が未設定", keys: map[string][]byte{"k1": testKey(1)}, primaryID: "k2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCipher(tt.keys, tt.primaryID); err == nil {
+				t.Error("NewCipher() succeeded, want error")
+			}
+		})
+	}
+}
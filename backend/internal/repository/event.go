@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"connpass-requirement/internal/models"
@@ -18,13 +19,24 @@ func NewEventRepository(db *sql.DB) *EventRepository {
 	return &EventRepository{db: db}
 }
 
+// Upsert はイベントキャッシュを最新状態へ更新し、ハッシュが変化した場合はスナップショットを追記する。
 func (r *EventRepository) Upsert(ctx context.Context, event *models.Event) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback() //nolint:errcheck
+		}
+	}()
+
 	query := `
 	INSERT INTO events_cache (
 		event_id, title, event_url, started_at, ended_at, "limit",
 		accepted, waiting, updated_at, retrieved_at, owner_nickname,
-		series_title, hash_digest
-	) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)
+		series_title, hash_digest, address, place, catch, revised_at
+	) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$10)
 	ON CONFLICT (event_id)
 	DO UPDATE SET
 		title = EXCLUDED.title,
@@ -38,11 +50,18 @@ func (r *EventRepository) Upsert(ctx context.Context, event *models.Event) error
 		retrieved_at = EXCLUDED.retrieved_at,
 		owner_nickname = EXCLUDED.owner_nickname,
 		series_title = EXCLUDED.series_title,
-		hash_digest = EXCLUDED.hash_digest
-	RETURNING id
+		hash_digest = EXCLUDED.hash_digest,
+		address = EXCLUDED.address,
+		place = EXCLUDED.place,
+		catch = EXCLUDED.catch,
+		revision = CASE WHEN events_cache.hash_digest <> EXCLUDED.hash_digest
+			THEN events_cache.revision + 1 ELSE events_cache.revision END,
+		revised_at = CASE WHEN events_cache.hash_digest <> EXCLUDED.hash_digest
+			THEN EXCLUDED.retrieved_at ELSE events_cache.revised_at END
+	RETURNING id, revision, revised_at
 	`
 
-	return r.db.QueryRowContext(
+	err = tx.QueryRowContext(
 		ctx,
 		query,
 		event.EventID,
@@ -58,18 +77,43 @@ func (r *EventRepository) Upsert(ctx context.Context, event *models.Event) error
 		event.OwnerNickname,
 		event.SeriesTitle,
 		event.HashDigest,
-	).Scan(&event.ID)
+		event.Address,
+		event.Place,
+		event.Catch,
+	).Scan(&event.ID, &event.Revision, &event.RevisedAt)
+	if err != nil {
+		return fmt.Errorf("upsert event: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO event_snapshots (event_id, "limit", accepted, waiting, hash_digest, observed_at)
+	SELECT $1, $2, $3, $4, $5, $6
+	WHERE COALESCE((
+		SELECT hash_digest FROM event_snapshots
+		WHERE event_id = $1
+		ORDER BY observed_at DESC
+		LIMIT 1
+	), '') <> $5
+	`, event.EventID, event.Limit, event.Accepted, event.Waiting, event.HashDigest, event.RetrievedAt)
+	if err != nil {
+		return fmt.Errorf("insert event snapshot: %w", err)
+	}
+
+	return tx.Commit()
 }
 
-func (r *EventRepository) FindByEventID(ctx context.Context, eventID int64) (*models.Event, error) {
-	var event models.Event
-	if err := r.db.QueryRowContext(ctx, `
-	SELECT id, event_id, title, event_url, started_at, ended_at,
-		"limit", accepted, waiting, updated_at, retrieved_at,
-		owner_nickname, series_title, hash_digest
-	FROM events_cache
-	WHERE event_id = $1
-	`, eventID).Scan(
+const eventColumns = `
+	id, event_id, title, event_url, started_at, ended_at,
+	"limit", accepted, waiting, updated_at, retrieved_at,
+	owner_nickname, series_title, hash_digest, address, place, catch,
+	revision, revised_at
+`
+
+// eventSearchText はトライグラム索引と同じ検索対象の式。
+const eventSearchText = `(title || ' ' || COALESCE(owner_nickname, '') || ' ' || COALESCE(series_title, ''))`
+
+func scanEvent(row rowScanner, event *models.Event) error {
+	return row.Scan(
 		&event.ID,
 		&event.EventID,
 		&event.Title,
@@ -84,7 +128,22 @@ func (r *EventRepository) FindByEventID(ctx context.Context, eventID int64) (*mo
 		&event.OwnerNickname,
 		&event.SeriesTitle,
 		&event.HashDigest,
-	); err != nil {
+		&event.Address,
+		&event.Place,
+		&event.Catch,
+		&event.Revision,
+		&event.RevisedAt,
+	)
+}
+
+func (r *EventRepository) FindByEventID(ctx context.Context, eventID int64) (*models.Event, error) {
+	var event models.Event
+	row := r.db.QueryRowContext(ctx, `
+	SELECT `+eventColumns+`
+	FROM events_cache
+	WHERE event_id = $1
+	`, eventID)
+	if err := scanEvent(row, &event); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -93,9 +152,224 @@ func (r *EventRepository) FindByEventID(ctx context.Context, eventID int64) (*mo
 	return &event, nil
 }
 
+// EventSearchParams はイベントキャッシュ検索の条件。ゼロ値（nil）の項目は絞り込まない。
+// VisibleToUserIDを指定すると、そのユーザーの所属ギルドのルールが一致したイベントに限る。
+type EventSearchParams struct {
+	Query           string
+	From            time.Time
+	To              time.Time
+	MinFill         *float64
+	MaxFill         *float64
+	RuleID          int64
+	VisibleToUserID int64
+	AfterTime       time.Time
+	AfterID         int64
+	Limit           int
+}
+
+// Search はイベントキャッシュを開催日時順に検索する。AfterTime/AfterIDより後ろの行から返す。
+func (r *EventRepository) Search(ctx context.Context, params EventSearchParams) ([]models.Event, error) {
+	var conditions []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if params.Query != "" {
+		conditions = append(conditions, eventSearchText+` ILIKE `+arg("%"+escapeLike(params.Query)+"%"))
+	}
+	if !params.From.IsZero() {
+		conditions = append(conditions, `started_at >= `+arg(params.From))
+	}
+	if !params.To.IsZero() {
+		conditions = append(conditions, `started_at < `+arg(params.To))
+	}
+	if params.MinFill != nil {
+		conditions = append(conditions, `"limit" > 0 AND accepted * 100.0 / "limit" >= `+arg(*params.MinFill))
+	}
+	if params.MaxFill != nil {
+		conditions = append(conditions, `"limit" > 0 AND accepted * 100.0 / "limit" <= `+arg(*params.MaxFill))
+	}
+	if params.RuleID != 0 {
+		conditions = append(conditions, `EXISTS (SELECT 1 FROM rule_events re WHERE re.event_id = events_cache.event_id AND re.rule_id = `+arg(params.RuleID)+`)`)
+	}
+	if params.VisibleToUserID != 0 {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1
+			FROM rule_events re
+			JOIN rules ru ON ru.id = re.rule_id
+			JOIN guild_permissions gp ON gp.guild_id = ru.guild_id
+			WHERE re.event_id = events_cache.event_id AND gp.user_id = `+arg(params.VisibleToUserID)+` AND ru.deleted_at IS NULL
+		)`)
+	}
+	if !params.AfterTime.IsZero() || params.AfterID != 0 {
+		conditions = append(conditions, `(started_at, event_id) > (`+arg(params.AfterTime)+`, `+arg(params.AfterID)+`)`)
+	}
+
+	query := `SELECT ` + eventColumns + ` FROM events_cache`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+	query += ` ORDER BY started_at ASC, event_id ASC LIMIT ` + arg(params.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]models.Event, 0, params.Limit)
+	for rows.Next() {
+		var event models.Event
+		if err := scanEvent(rows, &event); err != nil {
+			return nil, fmt.Errorf("scan event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// ListMatchedByRule はルールが一致したイベントのうち、since以降に開催されるものを開催順に返す。
+func (r *EventRepository) ListMatchedByRule(ctx context.Context, ruleID int64, since time.Time) ([]models.Event, error) {
+	return r.listMatched(ctx, `SELECT event_id FROM rule_events WHERE rule_id = $1`, ruleID, since)
+}
+
+// ListMatchedByGuild はギルド内のいずれかのルールが一致したイベントを開催順に返す。
+func (r *EventRepository) ListMatchedByGuild(ctx context.Context, guildID string, since time.Time) ([]models.Event, error) {
+	return r.listMatched(ctx, `
+		SELECT re.event_id FROM rule_events re
+		JOIN rules r ON r.id = re.rule_id
+		WHERE r.guild_id = $1 AND r.deleted_at IS NULL
+	`, guildID, since)
+}
+
+func (r *EventRepository) listMatched(ctx context.Context, matched string, key any, since time.Time) ([]models.Event, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT `+eventColumns+`
+	FROM events_cache
+	WHERE event_id IN (`+matched+`) AND started_at >= $2
+	ORDER BY started_at ASC, event_id ASC
+	`, key, since)
+	if err != nil {
+		return nil, fmt.Errorf("select matched events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.Event
+	for rows.Next() {
+		var event models.Event
+		if err := scanEvent(rows, &event); err != nil {
+			return nil, fmt.Errorf("scan event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
 func (r *EventRepository) Cleanup(ctx context.Context, before time.Time) error {
 	if _, err := r.db.ExecContext(ctx, `DELETE FROM events_cache WHERE retrieved_at < $1`, before); err != nil {
 		return fmt.Errorf("cleanup events cache: %w", err)
 	}
 	return nil
 }
+
+// ListSnapshots はイベントのスナップショットを観測順に返す。
+func (r *EventRepository) ListSnapshots(ctx context.Context, eventID int64) ([]models.EventSnapshot, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT id, event_id, "limit", accepted, waiting, hash_digest, observed_at
+	FROM event_snapshots
+	WHERE event_id = $1
+	ORDER BY observed_at ASC
+	`, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("select event snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := make([]models.EventSnapshot, 0)
+	for rows.Next() {
+		var snapshot models.EventSnapshot
+		if err := rows.Scan(
+			&snapshot.ID,
+			&snapshot.EventID,
+			&snapshot.Limit,
+			&snapshot.Accepted,
+			&snapshot.Waiting,
+			&snapshot.HashDigest,
+			&snapshot.ObservedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan event snapshot: %w", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, rows.Err()
+}
+
+// ListSnapshotsSince はsince以降のスナップショットを、since時点の状態を表す直前の1件を含めて観測順に返す。
+func (r *EventRepository) ListSnapshotsSince(ctx context.Context, eventID int64, since time.Time) ([]models.EventSnapshot, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT id, event_id, "limit", accepted, waiting, hash_digest, observed_at
+	FROM event_snapshots
+	WHERE event_id = $1
+		AND observed_at >= COALESCE((
+			SELECT MAX(observed_at) FROM event_snapshots
+			WHERE event_id = $1 AND observed_at <= $2
+		), $2)
+	ORDER BY observed_at ASC
+	`, eventID, since)
+	if err != nil {
+		return nil, fmt.Errorf("select event snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := make([]models.EventSnapshot, 0)
+	for rows.Next() {
+		var snapshot models.EventSnapshot
+		if err := rows.Scan(
+			&snapshot.ID,
+			&snapshot.EventID,
+			&snapshot.Limit,
+			&snapshot.Accepted,
+			&snapshot.Waiting,
+			&snapshot.HashDigest,
+			&snapshot.ObservedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan event snapshot: %w", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, rows.Err()
+}
+
+// IsVisibleToUser はユーザーが所属するギルドのルールがイベントに一致したことがあるか判定する。
+func (r *EventRepository) IsVisibleToUser(ctx context.Context, eventID, userID int64) (bool, error) {
+	var visible bool
+	if err := r.db.QueryRowContext(ctx, `
+	SELECT EXISTS (
+		SELECT 1
+		FROM rule_events re
+		JOIN rules ru ON ru.id = re.rule_id
+		JOIN guild_permissions gp ON gp.guild_id = ru.guild_id
+		WHERE re.event_id = $1 AND gp.user_id = $2 AND ru.deleted_at IS NULL
+	)
+	`, eventID, userID).Scan(&visible); err != nil {
+		return false, fmt.Errorf("check event visibility: %w", err)
+	}
+	return visible, nil
+}
+
+func (r *EventRepository) CleanupSnapshots(ctx context.Context, before time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM event_snapshots WHERE observed_at < $1`, before); err != nil {
+		return fmt.Errorf("cleanup event snapshots: %w", err)
+	}
+	return nil
+}
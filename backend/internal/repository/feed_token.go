@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"connpass-requirement/internal/models"
+)
+
+// FeedTokenRepository は購読フィードのトークンを扱う。
+type FeedTokenRepository struct {
+	db *sql.DB
+}
+
+func NewFeedTokenRepository(db *sql.DB) *FeedTokenRepository {
+	return &FeedTokenRepository{db: db}
+}
+
+// Rotate はフィードのトークンを差し替える。既存のトークンは無効になる。
+func (r *FeedTokenRepository) Rotate(ctx context.Context, token *models.FeedToken) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback() //nolint:errcheck
+		}
+	}()
+
+	if err = deleteFeedToken(ctx, tx, token.Scope, token.RuleID, token.GuildID); err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO feed_tokens (scope, rule_id, guild_id, token_hash, created_by)
+	VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+	RETURNING id, created_at
+	`, token.Scope, token.RuleID, token.GuildID, token.TokenHash, token.CreatedBy).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert feed token: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Revoke はフィードのトークンを削除する。
+func (r *FeedTokenRepository) Revoke(ctx context.Context, scope string, ruleID *int64, guildID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback() //nolint:errcheck
+		}
+	}()
+
+	if err = deleteFeedToken(ctx, tx, scope, ruleID, guildID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func deleteFeedToken(ctx context.Context, tx *sql.Tx, scope string, ruleID *int64, guildID string) error {
+	var err error
+	if scope == models.FeedScopeRule {
+		_, err = tx.ExecContext(ctx, `DELETE FROM feed_tokens WHERE scope = $1 AND rule_id = $2`, scope, ruleID)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM feed_tokens WHERE scope = $1 AND guild_id = $2`, scope, guildID)
+	}
+	if err != nil {
+		return fmt.Errorf("delete feed token: %w", err)
+	}
+	return nil
+}
+
+// FindByHash はトークンハッシュに対応するフィードを返し、最終利用日時を更新する。存在しない場合はnil。
+func (r *FeedTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*models.FeedToken, error) {
+	var token models.FeedToken
+	var guildID sql.NullString
+	if err := r.db.QueryRowContext(ctx, `
+	UPDATE feed_tokens SET last_used_at = $2
+	WHERE token_hash = $1
+	RETURNING id, scope, rule_id, guild_id, token_hash, created_by, created_at, last_used_at
+	`, tokenHash, time.Now().UTC()).Scan(
+		&token.ID,
+		&token.Scope,
+		&token.RuleID,
+		&guildID,
+		&token.TokenHash,
+		&token.CreatedBy,
+		&token.CreatedAt,
+		&token.LastUsedAt,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("select feed token: %w", err)
+	}
+	token.GuildID = guildID.String
+	return &token, nil
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"connpass-requirement/internal/models"
+)
+
+// GuildRoleRepository はギルドごとのアプリ内ロールを扱う。
+type GuildRoleRepository struct {
+	db *sql.DB
+}
+
+func NewGuildRoleRepository(db *sql.DB) *GuildRoleRepository {
+	return &GuildRoleRepository{db: db}
+}
+
+// FindMemberRole はユーザーへ個別に付与されたロールを返す。未付与の場合は空文字。
+func (r *GuildRoleRepository) FindMemberRole(ctx context.Context, guildID string, userID int64) (string, error) {
+	var role string
+	if err := r.db.QueryRowContext(ctx, `
+	SELECT role FROM guild_member_roles WHERE guild_id = $1 AND user_id = $2
+	`, guildID, userID).Scan(&role); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("select member role: %w", err)
+	}
+	return role, nil
+}
+
+// FindMappingRole はDiscordロールに対応付けたアプリ内ロールを返す。未設定の場合は空文字。
+func (r *GuildRoleRepository) FindMappingRole(ctx context.Context, guildID, discordRoleID string) (string, error) {
+	var role string
+	if err := r.db.QueryRowContext(ctx, `
+	SELECT role FROM guild_role_mappings WHERE guild_id = $1 AND discord_role_id = $2
+	`, guildID, discordRoleID).Scan(&role); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("select role mapping: %w", err)
+	}
+	return role, nil
+}
+
+// ListMappedRoles はDiscordロールIDに対応付けられたアプリ内ロールを返す。
+func (r *GuildRoleRepository) ListMappedRoles(ctx context.Context, guildID string, discordRoleIDs []string) ([]string, error) {
+	if len(discordRoleIDs) == 0 {
+		return nil, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT role FROM guild_role_mappings
+	WHERE guild_id = $1 AND discord_role_id = ANY($2)
+	`, guildID, pq.Array(discordRoleIDs))
+	if err != nil {
+		return nil, fmt.Errorf("select mapped roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("scan mapped role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// ListMembers はギルドで個別にロールを付与されたユーザーを返す。
+func (r *GuildRoleRepository) ListMembers(ctx context.Context, guildID string) ([]models.GuildMemberRole, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT m.guild_id, m.user_id, u.discord_username, m.role, m.granted_by, m.created_at, m.updated_at
+	FROM guild_member_roles m
+	JOIN users u ON u.id = m.user_id
+	WHERE m.guild_id = $1
+	ORDER BY u.discord_username ASC
+	`, guildID)
+	if err != nil {
+		return nil, fmt.Errorf("select member roles: %w", err)
+	}
+	defer rows.Close()
+
+	members := []models.GuildMemberRole{}
+	for rows.Next() {
+		var m models.GuildMemberRole
+		if err := rows.Scan(&m.GuildID, &m.UserID, &m.DiscordUsername, &m.Role, &m.GrantedBy, &m.CreatedAt, &m.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan member role: %w", err)
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// SetMemberRole はユーザーへロールを付与する。既に付与済みの場合は置き換える。
+func (r *GuildRoleRepository) SetMemberRole(ctx context.Context, member *models.GuildMemberRole) error {
+	if err := r.db.QueryRowContext(ctx, `
+	INSERT INTO guild_member_roles (guild_id, user_id, role, granted_by)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (guild_id, user_id)
+	DO UPDATE SET role = EXCLUDED.role, granted_by = EXCLUDED.granted_by, updated_at = NOW()
+	RETURNING created_at, updated_at
+	`, member.GuildID, member.UserID, member.Role, member.GrantedBy).Scan(&member.CreatedAt, &member.UpdatedAt); err != nil {
+		return fmt.Errorf("upsert member role: %w", err)
+	}
+	return nil
+}
+
+func (r *GuildRoleRepository) DeleteMemberRole(ctx context.Context, guildID string, userID int64) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM guild_member_roles WHERE guild_id = $1 AND user_id = $2`, guildID, userID); err != nil {
+		return fmt.Errorf("delete member role: %w", err)
+	}
+	return nil
+}
+
+// ListMappings はギルドのDiscordロール対応表を返す。
+func (r *GuildRoleRepository) ListMappings(ctx context.Context, guildID string) ([]models.GuildRoleMapping, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT guild_id, discord_role_id, role, created_by, created_at
+	FROM guild_role_mappings
+	WHERE guild_id = $1
+	ORDER BY created_at ASC
+	`, guildID)
+	if err != nil {
+		return nil, fmt.Errorf("select role mappings: %w", err)
+	}
+	defer rows.Close()
+
+	mappings := []models.GuildRoleMapping{}
+	for rows.Next() {
+		var m models.GuildRoleMapping
+		if err := rows.Scan(&m.GuildID, &m.DiscordRoleID, &m.Role, &m.CreatedBy, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan role mapping: %w", err)
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, rows.Err()
+}
+
+// SetMapping はDiscordロールとアプリ内ロールの対応を登録する。
+func (r *GuildRoleRepository) SetMapping(ctx context.Context, mapping *models.GuildRoleMapping) error {
+	if err := r.db.QueryRowContext(ctx, `
+	INSERT INTO guild_role_mappings (guild_id, discord_role_id, role, created_by)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (guild_id, discord_role_id)
+	DO UPDATE SET role = EXCLUDED.role
+	RETURNING created_by, created_at
+	`, mapping.GuildID, mapping.DiscordRoleID, mapping.Role, mapping.CreatedBy).Scan(&mapping.CreatedBy, &mapping.CreatedAt); err != nil {
+		return fmt.Errorf("upsert role mapping: %w", err)
+	}
+	return nil
+}
+
+func (r *GuildRoleRepository) DeleteMapping(ctx context.Context, guildID, discordRoleID string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM guild_role_mappings WHERE guild_id = $1 AND discord_role_id = $2`, guildID, discordRoleID); err != nil {
+		return fmt.Errorf("delete role mapping: %w", err)
+	}
+	return nil
+}
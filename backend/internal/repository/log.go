@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/lib/pq"
+
 	"connpass-requirement/internal/models"
 )
 
@@ -20,22 +22,24 @@ func NewLogRepository(db *sql.DB) *LogRepository {
 
 func (r *LogRepository) Save(ctx context.Context, log models.ImportantLog) error {
 	_, err := r.db.ExecContext(ctx, `
-	INSERT INTO important_logs (level, event_type, message, metadata)
-	VALUES ($1, $2, $3, $4)
-	`, log.Level, log.EventType, log.Message, log.Metadata)
+	INSERT INTO important_logs (level, event_type, guild_id, message, metadata)
+	VALUES ($1, $2, $3, $4, $5)
+	`, log.Level, log.EventType, log.GuildID, log.Message, log.Metadata)
 	if err != nil {
 		return fmt.Errorf("insert important log: %w", err)
 	}
 	return nil
 }
 
-func (r *LogRepository) ListRecent(ctx context.Context, limit int) ([]models.ImportantLog, error) {
+// ListRecent は新しい順にログを返す。allがfalseの場合はguildIDsのギルドに紐づくログのみ。
+func (r *LogRepository) ListRecent(ctx context.Context, limit int, guildIDs []string, all bool) ([]models.ImportantLog, error) {
 	rows, err := r.db.QueryContext(ctx, `
-	SELECT id, level, event_type, message, metadata, created_at
+	SELECT id, level, event_type, guild_id, message, metadata, created_at
 	FROM important_logs
+	WHERE $2 OR guild_id = ANY($3)
 	ORDER BY created_at DESC
 	LIMIT $1
-	`, limit)
+	`, limit, all, pq.Array(nonNilStrings(guildIDs)))
 	if err != nil {
 		return nil, fmt.Errorf("select important logs: %w", err)
 	}
@@ -48,6 +52,7 @@ func (r *LogRepository) ListRecent(ctx context.Context, limit int) ([]models.Imp
 			&log.ID,
 			&log.Level,
 			&log.EventType,
+			&log.GuildID,
 			&log.Message,
 			&log.Metadata,
 			&log.CreatedAt,
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 	"time"
+
+	"connpass-requirement/internal/models"
 )
 
 // NotificationRepository は通知履歴を扱う。
@@ -42,6 +44,45 @@ func (r *NotificationRepository) Record(ctx context.Context, ruleID, eventID int
 	return nil
 }
 
+// RecordBaseline は初回実行時に既存イベントを送信せず通知済みとして記録する。
+func (r *NotificationRepository) RecordBaseline(ctx context.Context, ruleID, eventID int64, notifyKey string) error {
+	_, err := r.db.ExecContext(ctx, `
+	INSERT INTO notifications (rule_id, event_id, notify_key, sent_at, baseline)
+	VALUES ($1, $2, $3, $4, TRUE)
+	ON CONFLICT (rule_id, event_id, notify_key)
+	DO NOTHING
+	`, ruleID, eventID, notifyKey, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("insert baseline notification: %w", err)
+	}
+	return nil
+}
+
+// ListByRule はルールの通知履歴を送信日時順に返す。
+func (r *NotificationRepository) ListByRule(ctx context.Context, ruleID int64) ([]models.Notification, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT id, rule_id, event_id, notify_key, sent_at, baseline
+	FROM notifications
+	WHERE rule_id = $1
+	ORDER BY sent_at ASC, id ASC
+	`, ruleID)
+	if err != nil {
+		return nil, fmt.Errorf("select notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.ID, &n.RuleID, &n.EventID, &n.NotifyKey, &n.SentAt, &n.Baseline); err != nil {
+			return nil, fmt.Errorf("scan notification: %w", err)
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
 func (r *NotificationRepository) Cleanup(ctx context.Context, before time.Time) error {
 	if _, err := r.db.ExecContext(ctx, `DELETE FROM notifications WHERE sent_at < $1`, before); err != nil {
 		return fmt.Errorf("cleanup notifications: %w", err)
@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
+
+	"github.com/lib/pq"
 
 	"connpass-requirement/internal/models"
 )
@@ -17,14 +20,75 @@ func NewRuleRepository(db *sql.DB) *RuleRepository {
 	return &RuleRepository{db: db}
 }
 
-// ListActive はアクティブなルールを全件取得する。スケジューラ専用。
+const ruleColumns = `
+	id, user_id, guild_id, channel_id, channel_name, name,
+	description, location, venue_type, weekdays, time_from, time_to,
+	within_days, match_mode, fuzzy_distance, capacity_threshold,
+	fill_horizon_hours, is_active, baseline_mode, baseline_at, created_by, updated_by,
+	created_at, updated_at, deleted_at, deleted_by
+`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRule(row rowScanner, rule *models.Rule) error {
+	var weekdays pq.Int64Array
+	var baselineAt sql.NullTime
+	if err := row.Scan(
+		&rule.ID,
+		&rule.UserID,
+		&rule.GuildID,
+		&rule.ChannelID,
+		&rule.ChannelName,
+		&rule.Name,
+		&rule.Description,
+		&rule.Location,
+		&rule.VenueType,
+		&weekdays,
+		&rule.TimeFrom,
+		&rule.TimeTo,
+		&rule.WithinDays,
+		&rule.MatchMode,
+		&rule.FuzzyDistance,
+		&rule.CapacityThresh,
+		&rule.FillHorizonHours,
+		&rule.IsActive,
+		&rule.BaselineMode,
+		&baselineAt,
+		&rule.CreatedBy,
+		&rule.UpdatedBy,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+		&rule.DeletedAt,
+		&rule.DeletedBy,
+	); err != nil {
+		return err
+	}
+	if baselineAt.Valid {
+		rule.BaselineAt = &baselineAt.Time
+	}
+	rule.Weekdays = make([]int, 0, len(weekdays))
+	for _, day := range weekdays {
+		rule.Weekdays = append(rule.Weekdays, int(day))
+	}
+	return nil
+}
+
+func weekdaysArray(weekdays []int) pq.Int64Array {
+	arr := make(pq.Int64Array, 0, len(weekdays))
+	for _, day := range weekdays {
+		arr = append(arr, int64(day))
+	}
+	return arr
+}
+
+// ListActive はアクティブなルールを全件取得する。ゴミ箱のルールは含めない。スケジューラ専用。
 func (r *RuleRepository) ListActive(ctx context.Context) ([]models.Rule, error) {
 	rows, err := r.db.QueryContext(ctx, `
-	SELECT id, user_id, guild_id, channel_id, channel_name, name,
-		description, location, capacity_threshold, is_active,
-		created_at, updated_at
+	SELECT `+ruleColumns+`
 	FROM rules
-	WHERE is_active = TRUE
+	WHERE is_active = TRUE AND deleted_at IS NULL
 	ORDER BY updated_at DESC
 	`)
 	if err != nil {
@@ -35,20 +99,7 @@ func (r *RuleRepository) ListActive(ctx context.Context) ([]models.Rule, error)
 	var rules []models.Rule
 	for rows.Next() {
 		var rule models.Rule
-		if err := rows.Scan(
-			&rule.ID,
-			&rule.UserID,
-			&rule.GuildID,
-			&rule.ChannelID,
-			&rule.ChannelName,
-			&rule.Name,
-			&rule.Description,
-			&rule.Location,
-			&rule.CapacityThresh,
-			&rule.IsActive,
-			&rule.CreatedAt,
-			&rule.UpdatedAt,
-		); err != nil {
+		if err := scanRule(rows, &rule); err != nil {
 			return nil, fmt.Errorf("scan active rule: %w", err)
 		}
 		if err := r.attachKeywordsAndTypes(ctx, &rule); err != nil {
@@ -60,16 +111,28 @@ func (r *RuleRepository) ListActive(ctx context.Context) ([]models.Rule, error)
 	return rules, rows.Err()
 }
 
-// ListByUserAndGuild は指定ユーザー・ギルドのルールを一覧取得する。
-func (r *RuleRepository) ListByUserAndGuild(ctx context.Context, userID int64, guildID string) ([]models.Rule, error) {
-	rows, err := r.db.QueryContext(ctx, `
-	SELECT id, user_id, guild_id, channel_id, channel_name, name,
-		description, location, capacity_threshold, is_active,
-		created_at, updated_at
+// ListByGuild は指定ギルドのルールを作成者に関わらず一覧取得する。ゴミ箱のルールは含めない。
+func (r *RuleRepository) ListByGuild(ctx context.Context, guildID string) ([]models.Rule, error) {
+	return r.listByGuild(ctx, `
+	SELECT `+ruleColumns+`
 	FROM rules
-	WHERE user_id = $1 AND guild_id = $2
+	WHERE guild_id = $1 AND deleted_at IS NULL
 	ORDER BY created_at DESC
-	`, userID, guildID)
+	`, guildID)
+}
+
+// ListDeletedByGuild はギルドのゴミ箱にあるルールを削除日時の新しい順に返す。
+func (r *RuleRepository) ListDeletedByGuild(ctx context.Context, guildID string) ([]models.Rule, error) {
+	return r.listByGuild(ctx, `
+	SELECT `+ruleColumns+`
+	FROM rules
+	WHERE guild_id = $1 AND deleted_at IS NOT NULL
+	ORDER BY deleted_at DESC
+	`, guildID)
+}
+
+func (r *RuleRepository) listByGuild(ctx context.Context, query, guildID string) ([]models.Rule, error) {
+	rows, err := r.db.QueryContext(ctx, query, guildID)
 	if err != nil {
 		return nil, fmt.Errorf("select rules: %w", err)
 	}
@@ -79,20 +142,7 @@ func (r *RuleRepository) ListByUserAndGuild(ctx context.Context, userID int64, g
 
 	for rows.Next() {
 		var rule models.Rule
-		if err := rows.Scan(
-			&rule.ID,
-			&rule.UserID,
-			&rule.GuildID,
-			&rule.ChannelID,
-			&rule.ChannelName,
-			&rule.Name,
-			&rule.Description,
-			&rule.Location,
-			&rule.CapacityThresh,
-			&rule.IsActive,
-			&rule.CreatedAt,
-			&rule.UpdatedAt,
-		); err != nil {
+		if err := scanRule(rows, &rule); err != nil {
 			return nil, fmt.Errorf("scan rule: %w", err)
 		}
 
@@ -106,29 +156,15 @@ func (r *RuleRepository) ListByUserAndGuild(ctx context.Context, userID int64, g
 	return rules, rows.Err()
 }
 
-// Get は単一ルールを取得する。
+// Get は単一ルールを取得する。ゴミ箱のルールは見つからない扱いでnilを返す。
 func (r *RuleRepository) Get(ctx context.Context, ruleID int64) (*models.Rule, error) {
 	var rule models.Rule
-	if err := r.db.QueryRowContext(ctx, `
-	SELECT id, user_id, guild_id, channel_id, channel_name, name,
-		description, location, capacity_threshold, is_active,
-		created_at, updated_at
+	row := r.db.QueryRowContext(ctx, `
+	SELECT `+ruleColumns+`
 	FROM rules
-	WHERE id = $1
-	`, ruleID).Scan(
-		&rule.ID,
-		&rule.UserID,
-		&rule.GuildID,
-		&rule.ChannelID,
-		&rule.ChannelName,
-		&rule.Name,
-		&rule.Description,
-		&rule.Location,
-		&rule.CapacityThresh,
-		&rule.IsActive,
-		&rule.CreatedAt,
-		&rule.UpdatedAt,
-	); err != nil {
+	WHERE id = $1 AND deleted_at IS NULL
+	`, ruleID)
+	if err := scanRule(row, &rule); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -154,12 +190,22 @@ func (r *RuleRepository) Create(ctx context.Context, rule *models.Rule) error {
 		}
 	}()
 
-	err = tx.QueryRowContext(ctx, `
+	if err = createRule(ctx, tx, rule); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func createRule(ctx context.Context, tx *sql.Tx, rule *models.Rule) error {
+	err := tx.QueryRowContext(ctx, `
 	INSERT INTO rules (
 		user_id, guild_id, channel_id, channel_name, name,
-		description, location, capacity_threshold, is_active
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-	RETURNING id, created_at, updated_at
+		description, location, venue_type, weekdays, time_from,
+		time_to, within_days, match_mode, fuzzy_distance,
+		capacity_threshold, is_active, baseline_mode, fill_horizon_hours,
+		created_by, updated_by
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $1, $1)
+	RETURNING id, created_by, updated_by, created_at, updated_at
 	`,
 		rule.UserID,
 		rule.GuildID,
@@ -168,25 +214,43 @@ func (r *RuleRepository) Create(ctx context.Context, rule *models.Rule) error {
 		rule.Name,
 		rule.Description,
 		rule.Location,
+		rule.VenueType,
+		weekdaysArray(rule.Weekdays),
+		rule.TimeFrom,
+		rule.TimeTo,
+		rule.WithinDays,
+		rule.MatchMode,
+		rule.FuzzyDistance,
 		rule.CapacityThresh,
 		rule.IsActive,
-	).Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+		rule.BaselineMode,
+		rule.FillHorizonHours,
+	).Scan(&rule.ID, &rule.CreatedBy, &rule.UpdatedBy, &rule.CreatedAt, &rule.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("insert rule: %w", err)
 	}
 
-	if err = insertKeywords(ctx, tx, rule.ID, rule.Keywords); err != nil {
+	if err := insertRelations(ctx, tx, rule); err != nil {
 		return err
 	}
-	if err = insertNotifyTypes(ctx, tx, rule.ID, rule.NotifyTypes); err != nil {
-		return err
-	}
-
-	return tx.Commit()
+	return insertRevision(ctx, tx, rule, models.RuleRevisionCreate, nil)
 }
 
-// Update は既存ルールを更新する。
+// Update は既存ルールを更新し、更新後の設定をリビジョンとして記録する。
 func (r *RuleRepository) Update(ctx context.Context, rule *models.Rule) error {
+	return r.update(ctx, rule, models.RuleRevisionUpdate, nil)
+}
+
+// Restore はリビジョンの設定を適用したルールを保存し、復元元を記録した新しいリビジョンを追加する。
+func (r *RuleRepository) Restore(ctx context.Context, rule *models.Rule, fromRevision int) error {
+	return r.update(ctx, rule, models.RuleRevisionRestore, &fromRevision)
+}
+
+func (r *RuleRepository) update(ctx context.Context, rule *models.Rule, action string, restoredFrom *int) error {
+	if err := r.ensureInitialRevision(ctx, rule.ID); err != nil {
+		return err
+	}
+
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
@@ -197,30 +261,64 @@ func (r *RuleRepository) Update(ctx context.Context, rule *models.Rule) error {
 		}
 	}()
 
-	_, err = tx.ExecContext(ctx, `
+	if err = updateRule(ctx, tx, rule, action, restoredFrom); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func updateRule(ctx context.Context, tx *sql.Tx, rule *models.Rule, action string, restoredFrom *int) error {
+	var baselineAt sql.NullTime
+	err := tx.QueryRowContext(ctx, `
 	UPDATE rules
 	SET channel_id = $1,
 		channel_name = $2,
 		name = $3,
 		description = $4,
 		location = $5,
-		capacity_threshold = $6,
-		is_active = $7,
+		venue_type = $6,
+		weekdays = $7,
+		time_from = $8,
+		time_to = $9,
+		within_days = $10,
+		match_mode = $11,
+		fuzzy_distance = $12,
+		capacity_threshold = $13,
+		baseline_at = CASE WHEN is_active = FALSE AND $14 = TRUE THEN NULL ELSE baseline_at END,
+		is_active = $14,
+		baseline_mode = $15,
+		fill_horizon_hours = $16,
+		updated_by = $18,
 		updated_at = NOW()
-	WHERE id = $8
+	WHERE id = $17
+	RETURNING baseline_at, updated_at
 	`,
 		rule.ChannelID,
 		rule.ChannelName,
 		rule.Name,
 		rule.Description,
 		rule.Location,
+		rule.VenueType,
+		weekdaysArray(rule.Weekdays),
+		rule.TimeFrom,
+		rule.TimeTo,
+		rule.WithinDays,
+		rule.MatchMode,
+		rule.FuzzyDistance,
 		rule.CapacityThresh,
 		rule.IsActive,
+		rule.BaselineMode,
+		rule.FillHorizonHours,
 		rule.ID,
-	)
+		rule.UpdatedBy,
+	).Scan(&baselineAt, &rule.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("update rule: %w", err)
 	}
+	rule.BaselineAt = nil
+	if baselineAt.Valid {
+		rule.BaselineAt = &baselineAt.Time
+	}
 
 	if _, err = tx.ExecContext(ctx, `DELETE FROM rule_keywords WHERE rule_id = $1`, rule.ID); err != nil {
 		return fmt.Errorf("delete rule keywords: %w", err)
@@ -228,99 +326,239 @@ func (r *RuleRepository) Update(ctx context.Context, rule *models.Rule) error {
 	if _, err = tx.ExecContext(ctx, `DELETE FROM rule_notify_types WHERE rule_id = $1`, rule.ID); err != nil {
 		return fmt.Errorf("delete notify types: %w", err)
 	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM rule_prefectures WHERE rule_id = $1`, rule.ID); err != nil {
+		return fmt.Errorf("delete rule prefectures: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM rule_exclusions WHERE rule_id = $1`, rule.ID); err != nil {
+		return fmt.Errorf("delete rule exclusions: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM rule_match_patterns WHERE rule_id = $1`, rule.ID); err != nil {
+		return fmt.Errorf("delete rule match patterns: %w", err)
+	}
 
-	if err = insertKeywords(ctx, tx, rule.ID, rule.Keywords); err != nil {
+	if err := insertRelations(ctx, tx, rule); err != nil {
 		return err
 	}
-	if err = insertNotifyTypes(ctx, tx, rule.ID, rule.NotifyTypes); err != nil {
-		return err
+	return insertRevision(ctx, tx, rule, action, restoredFrom)
+}
+
+// Import は複数ルールの作成・更新を1つのトランザクションで行う。いずれかが失敗した場合は全件取り消す。
+func (r *RuleRepository) Import(ctx context.Context, creates, updates []*models.Rule) error {
+	for _, rule := range updates {
+		if err := r.ensureInitialRevision(ctx, rule.ID); err != nil {
+			return err
+		}
 	}
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback() //nolint:errcheck
+		}
+	}()
+
+	for _, rule := range updates {
+		if err = updateRule(ctx, tx, rule, models.RuleRevisionUpdate, nil); err != nil {
+			return err
+		}
+	}
+	for _, rule := range creates {
+		if err = createRule(ctx, tx, rule); err != nil {
+			return err
+		}
+	}
 	return tx.Commit()
 }
 
-// Delete はルールを削除する。
-func (r *RuleRepository) Delete(ctx context.Context, ruleID int64) error {
-	_, err := r.db.ExecContext(ctx, `DELETE FROM rules WHERE id = $1`, ruleID)
+// Delete はルールをゴミ箱へ移す。通知履歴・一致履歴は残すため、復元しても過去のイベントを再通知しない。
+func (r *RuleRepository) Delete(ctx context.Context, ruleID, actorID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+	UPDATE rules SET deleted_at = NOW(), deleted_by = $2
+	WHERE id = $1 AND deleted_at IS NULL
+	`, ruleID, actorID)
 	if err != nil {
 		return fmt.Errorf("delete rule: %w", err)
 	}
 	return nil
 }
 
-func (r *RuleRepository) attachKeywordsAndTypes(ctx context.Context, rule *models.Rule) error {
-	keywordRows, err := r.db.QueryContext(ctx, `SELECT keyword FROM rule_keywords WHERE rule_id = $1 ORDER BY keyword`, rule.ID)
+// Undelete はギルドのゴミ箱にあるルールを元に戻す。該当するルールがない場合はnil。
+// 有効なルールはゴミ箱にあった間のイベントをまとめて通知しないよう、ベースラインを取り直す。
+func (r *RuleRepository) Undelete(ctx context.Context, guildID string, ruleID, actorID int64) (*models.Rule, error) {
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE rules
+	SET deleted_at = NULL,
+		deleted_by = NULL,
+		baseline_at = CASE WHEN is_active THEN NULL ELSE baseline_at END,
+		updated_by = $3,
+		updated_at = NOW()
+	WHERE id = $1 AND guild_id = $2 AND deleted_at IS NOT NULL
+	`, ruleID, guildID, actorID)
 	if err != nil {
-		return fmt.Errorf("select keywords: %w", err)
+		return nil, fmt.Errorf("undelete rule: %w", err)
 	}
-	defer keywordRows.Close()
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return r.Get(ctx, ruleID)
+}
 
-	var keywords []string
-	for keywordRows.Next() {
-		var keyword string
-		if err := keywordRows.Scan(&keyword); err != nil {
-			return fmt.Errorf("scan keyword: %w", err)
-		}
-		keywords = append(keywords, keyword)
+// PurgeDeleted はbefore以前にゴミ箱へ移したルールを完全に削除し、削除件数を返す。
+func (r *RuleRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM rules WHERE deleted_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("purge deleted rules: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("purge deleted rules rows: %w", err)
+	}
+	return n, nil
+}
+
+// TransferOwnership はルールの担当者を変更する。削除済み（ゴミ箱）のルールは対象外でsql.ErrNoRowsを返す。
+func (r *RuleRepository) TransferOwnership(ctx context.Context, ruleID, newOwnerID, actorID int64) error {
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE rules SET user_id = $1, updated_by = $2, updated_at = NOW()
+	WHERE id = $3 AND deleted_at IS NULL
+	`, newOwnerID, actorID, ruleID)
+	if err != nil {
+		return fmt.Errorf("transfer rule ownership: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// MarkBaselined は初回実行のベースライン取得完了を記録する。
+func (r *RuleRepository) MarkBaselined(ctx context.Context, ruleID int64, at time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `UPDATE rules SET baseline_at = $1 WHERE id = $2`, at, ruleID); err != nil {
+		return fmt.Errorf("mark rule baselined: %w", err)
+	}
+	return nil
+}
+
+func (r *RuleRepository) attachKeywordsAndTypes(ctx context.Context, rule *models.Rule) error {
+	keywords, err := r.selectStrings(ctx, `SELECT keyword FROM rule_keywords WHERE rule_id = $1 ORDER BY keyword`, rule.ID)
+	if err != nil {
+		return fmt.Errorf("select keywords: %w", err)
 	}
 	rule.Keywords = keywords
 
-	typeRows, err := r.db.QueryContext(ctx, `SELECT notify_key FROM rule_notify_types WHERE rule_id = $1 ORDER BY notify_key`, rule.ID)
+	types, err := r.selectStrings(ctx, `SELECT notify_key FROM rule_notify_types WHERE rule_id = $1 ORDER BY notify_key`, rule.ID)
 	if err != nil {
 		return fmt.Errorf("select notify types: %w", err)
 	}
-	defer typeRows.Close()
+	rule.NotifyTypes = types
 
-	var types []string
-	for typeRows.Next() {
-		var notify string
-		if err := typeRows.Scan(&notify); err != nil {
-			return fmt.Errorf("scan notify type: %w", err)
-		}
-		types = append(types, notify)
+	prefectures, err := r.selectStrings(ctx, `SELECT prefecture FROM rule_prefectures WHERE rule_id = $1 ORDER BY prefecture`, rule.ID)
+	if err != nil {
+		return fmt.Errorf("select prefectures: %w", err)
 	}
-	rule.NotifyTypes = types
+	rule.Prefectures = prefectures
 
-	return nil
+	patterns, err := r.selectStrings(ctx, `SELECT pattern FROM rule_match_patterns WHERE rule_id = $1 ORDER BY pattern`, rule.ID)
+	if err != nil {
+		return fmt.Errorf("select match patterns: %w", err)
+	}
+	rule.MatchPatterns = patterns
+
+	return r.attachExclusions(ctx, rule)
 }
 
-func insertKeywords(ctx context.Context, tx *sql.Tx, ruleID int64, keywords []string) error {
-	if len(keywords) == 0 {
-		return nil
+func (r *RuleRepository) attachExclusions(ctx context.Context, rule *models.Rule) error {
+	rows, err := r.db.QueryContext(ctx, `SELECT kind, value FROM rule_exclusions WHERE rule_id = $1 ORDER BY kind, value`, rule.ID)
+	if err != nil {
+		return fmt.Errorf("select exclusions: %w", err)
 	}
-	stmt, err := tx.PrepareContext(ctx, `INSERT INTO rule_keywords (rule_id, keyword) VALUES ($1, $2)`)
+	defer rows.Close()
+
+	rule.ExcludeKeywords = nil
+	rule.ExcludeOwners = nil
+	rule.ExcludeSeries = nil
+	for rows.Next() {
+		var exclusion models.RuleExclusion
+		if err := rows.Scan(&exclusion.Kind, &exclusion.Value); err != nil {
+			return fmt.Errorf("scan exclusion: %w", err)
+		}
+		switch exclusion.Kind {
+		case models.ExclusionKindKeyword:
+			rule.ExcludeKeywords = append(rule.ExcludeKeywords, exclusion.Value)
+		case models.ExclusionKindOwner:
+			rule.ExcludeOwners = append(rule.ExcludeOwners, exclusion.Value)
+		case models.ExclusionKindSeries:
+			rule.ExcludeSeries = append(rule.ExcludeSeries, exclusion.Value)
+		}
+	}
+	return rows.Err()
+}
+
+func (r *RuleRepository) selectStrings(ctx context.Context, query string, ruleID int64) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, query, ruleID)
 	if err != nil {
-		return fmt.Errorf("prepare insert keyword: %w", err)
+		return nil, err
 	}
-	defer stmt.Close()
+	defer rows.Close()
 
-	for _, keyword := range keywords {
-		if keyword == "" {
-			continue
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
 		}
-		if _, err := stmt.ExecContext(ctx, ruleID, keyword); err != nil {
-			return fmt.Errorf("insert keyword: %w", err)
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+func insertRelations(ctx context.Context, tx *sql.Tx, rule *models.Rule) error {
+	if err := insertStrings(ctx, tx, `INSERT INTO rule_keywords (rule_id, keyword) VALUES ($1, $2)`, rule.ID, rule.Keywords); err != nil {
+		return fmt.Errorf("insert keyword: %w", err)
+	}
+	if err := insertStrings(ctx, tx, `INSERT INTO rule_notify_types (rule_id, notify_key) VALUES ($1, $2)`, rule.ID, rule.NotifyTypes); err != nil {
+		return fmt.Errorf("insert notify type: %w", err)
+	}
+	if err := insertStrings(ctx, tx, `INSERT INTO rule_prefectures (rule_id, prefecture) VALUES ($1, $2)`, rule.ID, rule.Prefectures); err != nil {
+		return fmt.Errorf("insert prefecture: %w", err)
+	}
+	if err := insertStrings(ctx, tx, `INSERT INTO rule_match_patterns (rule_id, pattern) VALUES ($1, $2)`, rule.ID, rule.MatchPatterns); err != nil {
+		return fmt.Errorf("insert match pattern: %w", err)
+	}
+	exclusions := map[string][]string{
+		models.ExclusionKindKeyword: rule.ExcludeKeywords,
+		models.ExclusionKindOwner:   rule.ExcludeOwners,
+		models.ExclusionKindSeries:  rule.ExcludeSeries,
+	}
+	for kind, values := range exclusions {
+		if err := insertStrings(ctx, tx, `INSERT INTO rule_exclusions (rule_id, kind, value) VALUES ($1, '`+kind+`', $2)`, rule.ID, values); err != nil {
+			return fmt.Errorf("insert %s exclusion: %w", kind, err)
 		}
 	}
 	return nil
 }
 
-func insertNotifyTypes(ctx context.Context, tx *sql.Tx, ruleID int64, notifyTypes []string) error {
-	if len(notifyTypes) == 0 {
+func insertStrings(ctx context.Context, tx *sql.Tx, query string, ruleID int64, values []string) error {
+	if len(values) == 0 {
 		return nil
 	}
-	stmt, err := tx.PrepareContext(ctx, `INSERT INTO rule_notify_types (rule_id, notify_key) VALUES ($1, $2)`)
+	stmt, err := tx.PrepareContext(ctx, query)
 	if err != nil {
-		return fmt.Errorf("prepare insert notify type: %w", err)
+		return err
 	}
 	defer stmt.Close()
 
-	for _, notify := range notifyTypes {
-		if notify == "" {
+	seen := make(map[string]bool, len(values))
+	for _, value := range values {
+		if value == "" || seen[value] {
 			continue
 		}
-		if _, err := stmt.ExecContext(ctx, ruleID, notify); err != nil {
-			return fmt.Errorf("insert notify type: %w", err)
+		seen[value] = true
+		if _, err := stmt.ExecContext(ctx, ruleID, value); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"connpass-requirement/internal/models"
+)
+
+// RuleEventRepository はルールごとのイベント一致履歴を扱う。
+type RuleEventRepository struct {
+	db *sql.DB
+}
+
+func NewRuleEventRepository(db *sql.DB) *RuleEventRepository {
+	return &RuleEventRepository{db: db}
+}
+
+// Find はルールがイベントに前回一致した時点の状態を返す。未一致の場合はnil。
+func (r *RuleEventRepository) Find(ctx context.Context, ruleID, eventID int64) (*models.RuleEvent, error) {
+	var re models.RuleEvent
+	if err := r.db.QueryRowContext(ctx, `
+	SELECT rule_id, event_id, first_matched_at, last_matched_at,
+		"limit", accepted, waiting, hash_digest
+	FROM rule_events
+	WHERE rule_id = $1 AND event_id = $2
+	`, ruleID, eventID).Scan(
+		&re.RuleID,
+		&re.EventID,
+		&re.FirstMatchedAt,
+		&re.LastMatchedAt,
+		&re.Limit,
+		&re.Accepted,
+		&re.Waiting,
+		&re.HashDigest,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("select rule event: %w", err)
+	}
+	return &re, nil
+}
+
+// Record はルールとイベントの一致を記録する。初回一致時刻は保持したまま最新状態へ更新する。
+func (r *RuleEventRepository) Record(ctx context.Context, ruleID int64, event models.Event) error {
+	_, err := r.db.ExecContext(ctx, `
+	INSERT INTO rule_events (
+		rule_id, event_id, first_matched_at, last_matched_at,
+		"limit", accepted, waiting, hash_digest
+	) VALUES ($1, $2, $3, $3, $4, $5, $6, $7)
+	ON CONFLICT (rule_id, event_id)
+	DO UPDATE SET
+		last_matched_at = EXCLUDED.last_matched_at,
+		"limit" = EXCLUDED."limit",
+		accepted = EXCLUDED.accepted,
+		waiting = EXCLUDED.waiting,
+		hash_digest = EXCLUDED.hash_digest
+	`, ruleID, event.EventID, time.Now().UTC(), event.Limit, event.Accepted, event.Waiting, event.HashDigest)
+	if err != nil {
+		return fmt.Errorf("upsert rule event: %w", err)
+	}
+	return nil
+}
+
+func (r *RuleEventRepository) Cleanup(ctx context.Context, before time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM rule_events WHERE last_matched_at < $1`, before); err != nil {
+		return fmt.Errorf("cleanup rule events: %w", err)
+	}
+	return nil
+}
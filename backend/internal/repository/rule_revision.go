@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"connpass-requirement/internal/models"
+)
+
+const ruleRevisionColumns = `
+	rv.id, rv.rule_id, rv.revision, rv.action, rv.restored_from, rv.config, rv.created_by, COALESCE(u.discord_username, ''), rv.created_at
+`
+
+func scanRuleRevision(row rowScanner, rev *models.RuleRevision) error {
+	var config []byte
+	if err := row.Scan(&rev.ID, &rev.RuleID, &rev.Revision, &rev.Action, &rev.RestoredFrom, &config, &rev.CreatedBy, &rev.CreatedByName, &rev.CreatedAt); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(config, &rev.Config); err != nil {
+		return fmt.Errorf("unmarshal rule config: %w", err)
+	}
+	return nil
+}
+
+// insertRevision はルールの現在の設定を次の番号のリビジョンとして追加する。
+// 番号の採番はルール行の更新ロックを取った後に行うため、同じルールへの同時更新でも重複しない。
+func insertRevision(ctx context.Context, tx *sql.Tx, rule *models.Rule, action string, restoredFrom *int) error {
+	config, err := json.Marshal(models.RuleConfigOf(*rule))
+	if err != nil {
+		return fmt.Errorf("marshal rule config: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+	INSERT INTO rule_revisions (rule_id, revision, action, restored_from, config, created_by)
+	SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4, $5
+	FROM rule_revisions WHERE rule_id = $1
+	`, rule.ID, action, restoredFrom, string(config), rule.UpdatedBy); err != nil {
+		return fmt.Errorf("insert rule revision: %w", err)
+	}
+	return nil
+}
+
+// ensureInitialRevision はリビジョン導入前に作成されたルールについて、更新前の状態を最初のリビジョンとして保存する。
+func (r *RuleRepository) ensureInitialRevision(ctx context.Context, ruleID int64) error {
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM rule_revisions WHERE rule_id = $1)`, ruleID).Scan(&exists); err != nil {
+		return fmt.Errorf("check rule revisions: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	rule, err := r.Get(ctx, ruleID)
+	if err != nil || rule == nil {
+		return err
+	}
+	config, err := json.Marshal(models.RuleConfigOf(*rule))
+	if err != nil {
+		return fmt.Errorf("marshal rule config: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, `
+	INSERT INTO rule_revisions (rule_id, revision, action, config, created_by, created_at)
+	VALUES ($1, 1, $2, $3, $4, $5)
+	ON CONFLICT (rule_id, revision) DO NOTHING
+	`, rule.ID, models.RuleRevisionInitial, string(config), rule.UpdatedBy, rule.UpdatedAt); err != nil {
+		return fmt.Errorf("insert initial rule revision: %w", err)
+	}
+	return nil
+}
+
+// ListRevisions はルールのリビジョンを新しい順に返す。
+func (r *RuleRepository) ListRevisions(ctx context.Context, ruleID int64) ([]models.RuleRevision, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT `+ruleRevisionColumns+`
+	FROM rule_revisions rv
+	LEFT JOIN users u ON u.id = rv.created_by
+	WHERE rv.rule_id = $1
+	ORDER BY rv.revision DESC
+	`, ruleID)
+	if err != nil {
+		return nil, fmt.Errorf("select rule revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []models.RuleRevision{}
+	for rows.Next() {
+		var rev models.RuleRevision
+		if err := scanRuleRevision(rows, &rev); err != nil {
+			return nil, fmt.Errorf("scan rule revision: %w", err)
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+// GetRevision は番号を指定してリビジョンを返す。見つからない場合はnil。
+func (r *RuleRepository) GetRevision(ctx context.Context, ruleID int64, revision int) (*models.RuleRevision, error) {
+	var rev models.RuleRevision
+	row := r.db.QueryRowContext(ctx, `
+	SELECT `+ruleRevisionColumns+`
+	FROM rule_revisions rv
+	LEFT JOIN users u ON u.id = rv.created_by
+	WHERE rv.rule_id = $1 AND rv.revision = $2
+	`, ruleID, revision)
+	if err := scanRuleRevision(row, &rev); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("select rule revision: %w", err)
+	}
+	return &rev, nil
+}
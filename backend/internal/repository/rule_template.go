@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"connpass-requirement/internal/models"
+)
+
+// RuleTemplateRepository はルールテンプレートと利用履歴を扱う。
+type RuleTemplateRepository struct {
+	db *sql.DB
+}
+
+func NewRuleTemplateRepository(db *sql.DB) *RuleTemplateRepository {
+	return &RuleTemplateRepository{db: db}
+}
+
+const ruleTemplateColumns = `
+	id, guild_id, name, description, config, use_count, last_used_at, created_by, promoted_at, created_at, updated_at
+`
+
+// scanRuleTemplate はruleTemplateColumnsの順に読み込む。extraはその後ろに続く追加の列。
+func scanRuleTemplate(row rowScanner, t *models.RuleTemplate, extra ...any) error {
+	var config []byte
+	dest := []any{&t.ID, &t.GuildID, &t.Name, &t.Description, &config, &t.UseCount, &t.LastUsedAt, &t.CreatedBy, &t.PromotedAt, &t.CreatedAt, &t.UpdatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(config, &t.Config); err != nil {
+		return fmt.Errorf("unmarshal template config: %w", err)
+	}
+	t.System = t.GuildID == nil
+	return nil
+}
+
+// ListForGuild はシステム提供とギルド独自のテンプレートを利用回数の多い順に返す。
+func (r *RuleTemplateRepository) ListForGuild(ctx context.Context, guildID string) ([]models.RuleTemplate, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT `+ruleTemplateColumns+`
+	FROM rule_templates
+	WHERE guild_id IS NULL OR guild_id = $1
+	ORDER BY use_count DESC, id ASC
+	`, guildID)
+	if err != nil {
+		return nil, fmt.Errorf("select rule templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []models.RuleTemplate{}
+	for rows.Next() {
+		var t models.RuleTemplate
+		if err := scanRuleTemplate(rows, &t); err != nil {
+			return nil, fmt.Errorf("scan rule template: %w", err)
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// ListPopular はギルド独自のテンプレートを、同じ設定のテンプレートを利用したギルド数の多い順に返す。
+// システム提供への昇格候補の確認に使う。ギルド独自のテンプレートは作成したギルドでしか使えないため、
+// 各ギルドが個別に作った同じ設定（config）のテンプレートの利用をまとめて数える。
+func (r *RuleTemplateRepository) ListPopular(ctx context.Context, limit int) ([]models.RuleTemplate, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT `+ruleTemplateColumns+`, adoption.guild_count
+	FROM rule_templates t
+	CROSS JOIN LATERAL (
+		SELECT COUNT(DISTINCT u.guild_id) AS guild_count
+		FROM rule_templates same
+		JOIN rule_template_uses u ON u.template_id = same.id
+		WHERE same.guild_id IS NOT NULL AND same.config = t.config
+	) adoption
+	WHERE t.guild_id IS NOT NULL AND t.use_count > 0
+	ORDER BY adoption.guild_count DESC, t.use_count DESC, t.id ASC
+	LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select popular templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []models.RuleTemplate{}
+	for rows.Next() {
+		var t models.RuleTemplate
+		if err := scanRuleTemplate(rows, &t, &t.GuildCount); err != nil {
+			return nil, fmt.Errorf("scan rule template: %w", err)
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// Get はテンプレートを返す。見つからない場合はnil。
+func (r *RuleTemplateRepository) Get(ctx context.Context, id int64) (*models.RuleTemplate, error) {
+	var t models.RuleTemplate
+	row := r.db.QueryRowContext(ctx, `SELECT `+ruleTemplateColumns+` FROM rule_templates WHERE id = $1`, id)
+	if err := scanRuleTemplate(row, &t); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("select rule template: %w", err)
+	}
+	return &t, nil
+}
+
+// Create はギルド独自のテンプレートを登録する。
+func (r *RuleTemplateRepository) Create(ctx context.Context, t *models.RuleTemplate) error {
+	config, err := json.Marshal(t.Config)
+	if err != nil {
+		return fmt.Errorf("marshal template config: %w", err)
+	}
+	if err := r.db.QueryRowContext(ctx, `
+	INSERT INTO rule_templates (guild_id, name, description, config, created_by)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at, updated_at
+	`, t.GuildID, t.Name, t.Description, string(config), t.CreatedBy).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return fmt.Errorf("insert rule template: %w", err)
+	}
+	t.System = t.GuildID == nil
+	return nil
+}
+
+// Delete はギルド独自のテンプレートを削除する。該当するテンプレートがない場合はfalse。
+func (r *RuleTemplateRepository) Delete(ctx context.Context, guildID string, id int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM rule_templates WHERE id = $1 AND guild_id = $2`, id, guildID)
+	if err != nil {
+		return false, fmt.Errorf("delete rule template: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("delete rule template rows: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Promote はギルド独自のテンプレートをシステム提供に昇格させる。該当するテンプレートがない場合はfalse。
+func (r *RuleTemplateRepository) Promote(ctx context.Context, id int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE rule_templates SET guild_id = NULL, promoted_at = NOW(), updated_at = NOW()
+	WHERE id = $1 AND guild_id IS NOT NULL
+	`, id)
+	if err != nil {
+		return false, fmt.Errorf("promote rule template: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("promote rule template rows: %w", err)
+	}
+	return n > 0, nil
+}
+
+// RecordUse はテンプレートからルールを作成したことを記録し、利用回数を加算する。
+func (r *RuleTemplateRepository) RecordUse(ctx context.Context, templateID int64, guildID string, ruleID, userID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback() //nolint:errcheck
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `
+	INSERT INTO rule_template_uses (template_id, guild_id, rule_id, user_id)
+	VALUES ($1, $2, $3, $4)
+	`, templateID, guildID, ruleID, userID); err != nil {
+		return fmt.Errorf("insert template use: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `
+	UPDATE rule_templates SET use_count = use_count + 1, last_used_at = NOW() WHERE id = $1
+	`, templateID); err != nil {
+		return fmt.Errorf("count template use: %w", err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"connpass-requirement/internal/models"
+)
+
+// SessionRepository はログインセッションを扱う。
+type SessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+const sessionColumns = `
+	id, user_id, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at
+`
+
+func scanSession(row rowScanner, s *models.Session) error {
+	return row.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt)
+}
+
+// Create はセッションを登録する。リフレッシュトークンはハッシュのみ保存する。
+func (r *SessionRepository) Create(ctx context.Context, session *models.Session, refreshHash string) error {
+	if err := r.db.QueryRowContext(ctx, `
+	INSERT INTO sessions (id, user_id, refresh_token_hash, user_agent, ip_address, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING created_at, last_seen_at
+	`, session.ID, session.UserID, refreshHash, session.UserAgent, session.IPAddress, session.ExpiresAt).Scan(&session.CreatedAt, &session.LastSeenAt); err != nil {
+		return fmt.Errorf("insert session: %w", err)
+	}
+	return nil
+}
+
+// FindActive は失効・期限切れでないセッションを返す。見つからない場合はnil。
+func (r *SessionRepository) FindActive(ctx context.Context, id string) (*models.Session, error) {
+	var s models.Session
+	row := r.db.QueryRowContext(ctx, `
+	SELECT `+sessionColumns+`
+	FROM sessions
+	WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+	`, id)
+	if err := scanSession(row, &s); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("select session: %w", err)
+	}
+	return &s, nil
+}
+
+// Touch は最終利用日時を更新する。書き込みを抑えるため1分以内の更新は省略する。
+func (r *SessionRepository) Touch(ctx context.Context, id string) error {
+	if _, err := r.db.ExecContext(ctx, `
+	UPDATE sessions SET last_seen_at = NOW()
+	WHERE id = $1 AND last_seen_at < NOW() - INTERVAL '1 minute'
+	`, id); err != nil {
+		return fmt.Errorf("touch session: %w", err)
+	}
+	return nil
+}
+
+// Rotate は有効なリフレッシュトークンを新しいものへ置き換える。一致するセッションがない場合はnil。
+// 置き換え前のハッシュは再利用検知のためprevious_refresh_hashに残す。
+func (r *SessionRepository) Rotate(ctx context.Context, refreshHash, newHash string) (*models.Session, error) {
+	var s models.Session
+	row := r.db.QueryRowContext(ctx, `
+	UPDATE sessions
+	SET previous_refresh_hash = refresh_token_hash, refresh_token_hash = $2, rotated_at = NOW(), last_seen_at = NOW()
+	WHERE refresh_token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+	RETURNING `+sessionColumns, refreshHash, newHash)
+	if err := scanSession(row, &s); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("rotate session: %w", err)
+	}
+	return &s, nil
+}
+
+// FindRecentlyRotated は直前のリフレッシュトークンがgrace以内にローテーションされた有効なセッションを返す。
+// 該当しない場合はnil。
+func (r *SessionRepository) FindRecentlyRotated(ctx context.Context, previousHash string, grace time.Duration) (*models.Session, error) {
+	var s models.Session
+	row := r.db.QueryRowContext(ctx, `
+	SELECT `+sessionColumns+`
+	FROM sessions
+	WHERE previous_refresh_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+	  AND rotated_at > NOW() - $2 * INTERVAL '1 millisecond'
+	`, previousHash, grace.Milliseconds())
+	if err := scanSession(row, &s); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("select rotated session: %w", err)
+	}
+	return &s, nil
+}
+
+// RevokeByPreviousHash は使用済みのリフレッシュトークンが再提示されたセッションを失効させる。
+// 失効させたセッションのユーザーIDを返し、該当しない場合は0。
+func (r *SessionRepository) RevokeByPreviousHash(ctx context.Context, refreshHash string) (int64, error) {
+	var userID int64
+	if err := r.db.QueryRowContext(ctx, `
+	UPDATE sessions SET revoked_at = NOW()
+	WHERE previous_refresh_hash = $1 AND revoked_at IS NULL
+	RETURNING user_id
+	`, refreshHash).Scan(&userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("revoke reused session: %w", err)
+	}
+	return userID, nil
+}
+
+// ListActiveByUser はユーザーの有効なセッションを最終利用日時の新しい順に返す。
+func (r *SessionRepository) ListActiveByUser(ctx context.Context, userID int64) ([]models.Session, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT `+sessionColumns+`
+	FROM sessions
+	WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+	ORDER BY last_seen_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("select sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var s models.Session
+		if err := scanSession(rows, &s); err != nil {
+			return nil, fmt.Errorf("scan session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// Revoke はユーザーのセッションを1件失効させる。該当するセッションがない場合はfalse。
+func (r *SessionRepository) Revoke(ctx context.Context, userID int64, id string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE sessions SET revoked_at = NOW()
+	WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("revoke session: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("revoke session rows: %w", err)
+	}
+	return n > 0, nil
+}
+
+// RevokeAll はユーザーの全セッションを失効させる。exceptIDを指定した場合はそのセッションを残す。
+func (r *SessionRepository) RevokeAll(ctx context.Context, userID int64, exceptID string) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE sessions SET revoked_at = NOW()
+	WHERE user_id = $1 AND revoked_at IS NULL AND id <> $2
+	`, userID, exceptID)
+	if err != nil {
+		return 0, fmt.Errorf("revoke sessions: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("revoke sessions rows: %w", err)
+	}
+	return n, nil
+}
@@ -5,47 +5,82 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
+
+	"github.com/lib/pq"
 
 	"connpass-requirement/internal/models"
 )
 
 // UserRepository はユーザー関連のDB操作を担当する。
+// DiscordのOAuthトークンはcipherで暗号化して保存する。
 type UserRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	cipher *Cipher
 }
 
-func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
+func NewUserRepository(db *sql.DB, cipher *Cipher) *UserRepository {
+	return &UserRepository{db: db, cipher: cipher}
 }
 
 // Upsert はDiscordユーザー情報を保存または更新する。
-func (r *UserRepository) Upsert(ctx context.Context, user *models.User) error {
-	query := `
+// トークンは行IDに紐づけて暗号化するため、行を確定させてから同じトランザクションで保存する。
+func (r *UserRepository) Upsert(ctx context.Context, user *models.User) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback() //nolint:errcheck
+		}
+	}()
+
+	if err = tx.QueryRowContext(ctx, `
 	INSERT INTO users (
 		discord_user_id, discord_username, avatar_url,
 		access_token, refresh_token, token_expires_at
-	) VALUES ($1, $2, $3, $4, $5, $6)
+	) VALUES ($1, $2, $3, '', '', $4)
 	ON CONFLICT (discord_user_id)
 	DO UPDATE SET
 		discord_username = EXCLUDED.discord_username,
 		avatar_url = EXCLUDED.avatar_url,
-		access_token = EXCLUDED.access_token,
-		refresh_token = EXCLUDED.refresh_token,
 		token_expires_at = EXCLUDED.token_expires_at,
 		updated_at = NOW()
 	RETURNING id, created_at, updated_at
-	`
-
-	return r.db.QueryRowContext(
-		ctx,
-		query,
+	`,
 		user.DiscordUserID,
 		user.DiscordUsername,
 		user.AvatarURL,
-		user.AccessToken,
-		user.RefreshToken,
 		user.TokenExpiresAt,
-	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		return fmt.Errorf("upsert user: %w", err)
+	}
+
+	accessToken, refreshToken, err := r.encryptTokens(user.ID, user.AccessToken, user.RefreshToken)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `
+	UPDATE users SET access_token = $2, refresh_token = $3 WHERE id = $1
+	`, user.ID, accessToken, refreshToken); err != nil {
+		return fmt.Errorf("update user tokens: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// encryptTokens はユーザーのDiscordトークンを行IDに紐づけて暗号化する。
+func (r *UserRepository) encryptTokens(userID int64, accessToken, refreshToken string) (string, string, error) {
+	access, err := r.cipher.Encrypt(accessToken, columnAAD("users", "access_token", userID))
+	if err != nil {
+		return "", "", fmt.Errorf("encrypt access token: %w", err)
+	}
+	refresh, err := r.cipher.Encrypt(refreshToken, columnAAD("users", "refresh_token", userID))
+	if err != nil {
+		return "", "", fmt.Errorf("encrypt refresh token: %w", err)
+	}
+	return access, refresh, nil
 }
 
 // SaveGuildPermissions はユーザーのギルド権限情報を保存する。
@@ -64,6 +99,10 @@ func (r *UserRepository) SaveGuildPermissions(ctx context.Context, userID int64,
 		return fmt.Errorf("delete old guild permissions: %w", err)
 	}
 
+	if _, err = tx.ExecContext(ctx, `UPDATE users SET guilds_synced_at = NOW() WHERE id = $1`, userID); err != nil {
+		return fmt.Errorf("update guilds synced at: %w", err)
+	}
+
 	if len(guilds) == 0 {
 		return tx.Commit()
 	}
@@ -71,8 +110,8 @@ func (r *UserRepository) SaveGuildPermissions(ctx context.Context, userID int64,
 	stmt, err := tx.PrepareContext(ctx, `
 	INSERT INTO guild_permissions (
 		user_id, guild_id, guild_name, permissions, icon_url,
-		can_manage, can_manage_role
-	) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		can_manage, can_manage_role, role_ids
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`)
 	if err != nil {
 		return fmt.Errorf("prepare insert guild permissions: %w", err)
@@ -89,6 +128,7 @@ func (r *UserRepository) SaveGuildPermissions(ctx context.Context, userID int64,
 			g.IconURL,
 			g.CanManage,
 			g.CanManageRole,
+			pq.Array(nonNilStrings(g.RoleIDs)),
 		); err != nil {
 			return fmt.Errorf("insert guild permission: %w", err)
 		}
@@ -100,8 +140,7 @@ func (r *UserRepository) SaveGuildPermissions(ctx context.Context, userID int64,
 // ListGuildPermissions はユーザーのギルド権限を取得する。
 func (r *UserRepository) ListGuildPermissions(ctx context.Context, userID int64) ([]models.GuildPermission, error) {
 	rows, err := r.db.QueryContext(ctx, `
-	SELECT id, user_id, guild_id, guild_name, permissions, icon_url,
-		can_manage, can_manage_role
+	SELECT `+guildPermissionColumns+`
 	FROM guild_permissions
 	WHERE user_id = $1
 	ORDER BY guild_name ASC
@@ -115,16 +154,7 @@ func (r *UserRepository) ListGuildPermissions(ctx context.Context, userID int64)
 
 	for rows.Next() {
 		var g models.GuildPermission
-		if err := rows.Scan(
-			&g.ID,
-			&g.UserID,
-			&g.GuildID,
-			&g.GuildName,
-			&g.Permissions,
-			&g.IconURL,
-			&g.CanManage,
-			&g.CanManageRole,
-		); err != nil {
+		if err := scanGuildPermission(rows, &g); err != nil {
 			return nil, fmt.Errorf("scan guild permission: %w", err)
 		}
 		guilds = append(guilds, g)
@@ -133,16 +163,77 @@ func (r *UserRepository) ListGuildPermissions(ctx context.Context, userID int64)
 	return guilds, rows.Err()
 }
 
-// FindByDiscordID はDiscordユーザーIDでユーザーを検索する。
-func (r *UserRepository) FindByDiscordID(ctx context.Context, discordID string) (*models.User, error) {
-	var user models.User
+// FindGuildPermission はユーザーの特定ギルドでの権限を返す。所属していない場合はnil。
+func (r *UserRepository) FindGuildPermission(ctx context.Context, userID int64, guildID string) (*models.GuildPermission, error) {
+	var g models.GuildPermission
+	row := r.db.QueryRowContext(ctx, `
+	SELECT `+guildPermissionColumns+`
+	FROM guild_permissions
+	WHERE user_id = $1 AND guild_id = $2
+	`, userID, guildID)
+	if err := scanGuildPermission(row, &g); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("select guild permission: %w", err)
+	}
+	return &g, nil
+}
+
+const guildPermissionColumns = `
+	id, user_id, guild_id, guild_name, permissions, COALESCE(icon_url, ''),
+	can_manage, can_manage_role, role_ids
+`
+
+func scanGuildPermission(row rowScanner, g *models.GuildPermission) error {
+	var roleIDs pq.StringArray
+	if err := row.Scan(
+		&g.ID,
+		&g.UserID,
+		&g.GuildID,
+		&g.GuildName,
+		&g.Permissions,
+		&g.IconURL,
+		&g.CanManage,
+		&g.CanManageRole,
+		&roleIDs,
+	); err != nil {
+		return err
+	}
+	g.RoleIDs = []string(roleIDs)
+	return nil
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// FindGuildName は保存済みの権限情報からギルド名を返す。見つからない場合は空文字。
+func (r *UserRepository) FindGuildName(ctx context.Context, guildID string) (string, error) {
+	var name string
 	if err := r.db.QueryRowContext(ctx, `
-	SELECT id, discord_user_id, discord_username, avatar_url,
-		access_token, refresh_token, token_expires_at,
-		created_at, updated_at
-	FROM users
-	WHERE discord_user_id = $1
-	`, discordID).Scan(
+	SELECT guild_name FROM guild_permissions WHERE guild_id = $1 LIMIT 1
+	`, guildID).Scan(&name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("select guild name: %w", err)
+	}
+	return name, nil
+}
+
+const userColumns = `
+	id, discord_user_id, discord_username, avatar_url,
+	access_token, refresh_token, token_expires_at,
+	guilds_synced_at,
+	created_at, updated_at
+`
+
+func (r *UserRepository) scanUser(row rowScanner, user *models.User) error {
+	if err := row.Scan(
 		&user.ID,
 		&user.DiscordUserID,
 		&user.DiscordUsername,
@@ -150,9 +241,26 @@ func (r *UserRepository) FindByDiscordID(ctx context.Context, discordID string)
 		&user.AccessToken,
 		&user.RefreshToken,
 		&user.TokenExpiresAt,
+		&user.GuildsSyncedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	); err != nil {
+		return err
+	}
+	if err := r.cipher.decryptColumns("users", user.ID, map[string]*string{
+		"access_token":  &user.AccessToken,
+		"refresh_token": &user.RefreshToken,
+	}); err != nil {
+		return fmt.Errorf("decrypt user tokens: %w", err)
+	}
+	return nil
+}
+
+// FindByDiscordID はDiscordユーザーIDでユーザーを検索する。
+func (r *UserRepository) FindByDiscordID(ctx context.Context, discordID string) (*models.User, error) {
+	var user models.User
+	row := r.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE discord_user_id = $1`, discordID)
+	if err := r.scanUser(row, &user); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
@@ -164,23 +272,8 @@ func (r *UserRepository) FindByDiscordID(ctx context.Context, discordID string)
 // FindByID はユーザーIDでユーザーを検索する。
 func (r *UserRepository) FindByID(ctx context.Context, userID int64) (*models.User, error) {
 	var user models.User
-	if err := r.db.QueryRowContext(ctx, `
-	SELECT id, discord_user_id, discord_username, avatar_url,
-		access_token, refresh_token, token_expires_at,
-		created_at, updated_at
-	FROM users
-	WHERE id = $1
-	`, userID).Scan(
-		&user.ID,
-		&user.DiscordUserID,
-		&user.DiscordUsername,
-		&user.AvatarURL,
-		&user.AccessToken,
-		&user.RefreshToken,
-		&user.TokenExpiresAt,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	); err != nil {
+	row := r.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, userID)
+	if err := r.scanUser(row, &user); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("user not found")
 		}
@@ -189,6 +282,134 @@ func (r *UserRepository) FindByID(ctx context.Context, userID int64) (*models.Us
 	return &user, nil
 }
 
+// ListDueForGuildSync はギルド権限の再同期が必要なユーザーを返す。
+// 最終同期がsyncedBefore以前、またはトークン期限がexpiresBefore以前のユーザーが対象。
+// セッション失効済みのユーザーと、excludeIDs（同じ実行で処理済み）のユーザーは除く。
+func (r *UserRepository) ListDueForGuildSync(ctx context.Context, syncedBefore, expiresBefore time.Time, excludeIDs []int64, limit int) ([]models.User, error) {
+	if excludeIDs == nil {
+		excludeIDs = []int64{}
+	}
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT `+userColumns+`
+	FROM users
+	WHERE refresh_token <> ''
+		AND (guilds_synced_at IS NULL OR guilds_synced_at < $1 OR token_expires_at < $2)
+		AND NOT (id = ANY($3))
+	ORDER BY guilds_synced_at ASC NULLS FIRST
+	LIMIT $4
+	`, syncedBefore, expiresBefore, pq.Array(excludeIDs), limit)
+	if err != nil {
+		return nil, fmt.Errorf("select users for guild sync: %w", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := r.scanUser(rows, &user); err != nil {
+			return nil, fmt.Errorf("scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// UpdateTokens はリフレッシュ後のDiscordトークンを保存する。
+func (r *UserRepository) UpdateTokens(ctx context.Context, userID int64, accessToken, refreshToken string, expiresAt time.Time) error {
+	accessToken, refreshToken, err := r.encryptTokens(userID, accessToken, refreshToken)
+	if err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `
+	UPDATE users
+	SET access_token = $2, refresh_token = $3, token_expires_at = $4, updated_at = NOW()
+	WHERE id = $1
+	`, userID, accessToken, refreshToken, expiresAt); err != nil {
+		return fmt.Errorf("update user tokens: %w", err)
+	}
+	return nil
+}
+
+// ReencryptTokens は平文または旧鍵で暗号化されたトークンを現在の主鍵で暗号化し直し、更新した件数を返す。
+func (r *UserRepository) ReencryptTokens(ctx context.Context) (int, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, access_token, refresh_token FROM users ORDER BY id`)
+	if err != nil {
+		return 0, fmt.Errorf("select user tokens: %w", err)
+	}
+	type storedTokens struct {
+		id              int64
+		access, refresh string
+	}
+	var targets []storedTokens
+	for rows.Next() {
+		var t storedTokens
+		if err := rows.Scan(&t.id, &t.access, &t.refresh); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan user tokens: %w", err)
+		}
+		if r.cipher.NeedsReencrypt(t.access) || r.cipher.NeedsReencrypt(t.refresh) {
+			targets = append(targets, t)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, t := range targets {
+		access, err := r.cipher.Reencrypt(t.access, columnAAD("users", "access_token", t.id))
+		if err != nil {
+			return updated, fmt.Errorf("user %d: %w", t.id, err)
+		}
+		refresh, err := r.cipher.Reencrypt(t.refresh, columnAAD("users", "refresh_token", t.id))
+		if err != nil {
+			return updated, fmt.Errorf("user %d: %w", t.id, err)
+		}
+		// 同時にログイン・リフレッシュで更新された行は上書きしない
+		res, err := r.db.ExecContext(ctx, `
+		UPDATE users SET access_token = $2, refresh_token = $3
+		WHERE id = $1 AND access_token = $4 AND refresh_token = $5
+		`, t.id, access, refresh, t.access, t.refresh)
+		if err != nil {
+			return updated, fmt.Errorf("update user %d tokens: %w", t.id, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+// RevokeSessions はDiscordトークンが取り消されたユーザーのセッションとギルド権限を無効化する。
+// 以降は再ログインするまで同期対象から外れる。
+func (r *UserRepository) RevokeSessions(ctx context.Context, userID int64) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback() //nolint:errcheck
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `
+	UPDATE users
+	SET access_token = '', refresh_token = '', updated_at = NOW()
+	WHERE id = $1
+	`, userID); err != nil {
+		return fmt.Errorf("revoke user sessions: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return fmt.Errorf("revoke sessions: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM guild_permissions WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("delete guild permissions: %w", err)
+	}
+	return tx.Commit()
+}
+
 // GetGuildPermissions はユーザーのギルド権限を取得する（ListGuildPermissionsのエイリアス）。
 func (r *UserRepository) GetGuildPermissions(ctx context.Context, userID int64) ([]models.GuildPermission, error) {
 	return r.ListGuildPermissions(ctx, userID)
@@ -0,0 +1,330 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"connpass-requirement/internal/models"
+)
+
+// WebhookRepository はWebhook登録と配信履歴を扱う。URLと署名シークレットはcipherで暗号化して保存する。
+type WebhookRepository struct {
+	db     *sql.DB
+	cipher *Cipher
+}
+
+func NewWebhookRepository(db *sql.DB, cipher *Cipher) *WebhookRepository {
+	return &WebhookRepository{db: db, cipher: cipher}
+}
+
+const webhookColumns = `id, guild_id, url, secret, event_types, is_active, created_by, created_at, updated_at`
+
+func (r *WebhookRepository) scanWebhook(row rowScanner, webhook *models.Webhook) error {
+	var eventTypes pq.StringArray
+	if err := row.Scan(
+		&webhook.ID,
+		&webhook.GuildID,
+		&webhook.URL,
+		&webhook.Secret,
+		&eventTypes,
+		&webhook.IsActive,
+		&webhook.CreatedBy,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	); err != nil {
+		return err
+	}
+	webhook.EventTypes = []string(eventTypes)
+	if err := r.cipher.decryptColumns("webhooks", webhook.ID, map[string]*string{
+		"url":    &webhook.URL,
+		"secret": &webhook.Secret,
+	}); err != nil {
+		return fmt.Errorf("decrypt webhook: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepository) queryWebhooks(ctx context.Context, query string, args ...any) ([]models.Webhook, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		var webhook models.Webhook
+		if err := r.scanWebhook(rows, &webhook); err != nil {
+			return nil, fmt.Errorf("scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// ListByGuild はギルドに登録されたWebhookを返す。
+func (r *WebhookRepository) ListByGuild(ctx context.Context, guildID string) ([]models.Webhook, error) {
+	return r.queryWebhooks(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE guild_id = $1 ORDER BY id ASC`, guildID)
+}
+
+// ListSubscribers はギルドでイベント種別を購読している有効なWebhookを返す。
+func (r *WebhookRepository) ListSubscribers(ctx context.Context, guildID, eventType string) ([]models.Webhook, error) {
+	return r.queryWebhooks(ctx, `
+	SELECT `+webhookColumns+` FROM webhooks
+	WHERE is_active = TRUE AND $2 = ANY(event_types) AND guild_id = $1
+	ORDER BY id ASC
+	`, guildID, eventType)
+}
+
+func (r *WebhookRepository) Get(ctx context.Context, id int64) (*models.Webhook, error) {
+	var webhook models.Webhook
+	row := r.db.QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, id)
+	if err := r.scanWebhook(row, &webhook); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("select webhook: %w", err)
+	}
+	return &webhook, nil
+}
+
+// Create はWebhookを登録する。URLとシークレットは行IDに紐づけて暗号化するため、挿入後に同じトランザクションで保存する。
+func (r *WebhookRepository) Create(ctx context.Context, webhook *models.Webhook) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback() //nolint:errcheck
+		}
+	}()
+
+	if err = tx.QueryRowContext(ctx, `
+	INSERT INTO webhooks (guild_id, url, secret, event_types, is_active, created_by)
+	VALUES ($1, '', '', $2, $3, $4)
+	RETURNING id, created_at, updated_at
+	`, webhook.GuildID, pq.Array(webhook.EventTypes), webhook.IsActive, webhook.CreatedBy).Scan(
+		&webhook.ID,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	); err != nil {
+		return fmt.Errorf("insert webhook: %w", err)
+	}
+
+	url, err := r.cipher.Encrypt(webhook.URL, columnAAD("webhooks", "url", webhook.ID))
+	if err != nil {
+		return fmt.Errorf("encrypt webhook url: %w", err)
+	}
+	secret, err := r.cipher.Encrypt(webhook.Secret, columnAAD("webhooks", "secret", webhook.ID))
+	if err != nil {
+		return fmt.Errorf("encrypt webhook secret: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE webhooks SET url = $2, secret = $3 WHERE id = $1`, webhook.ID, url, secret); err != nil {
+		return fmt.Errorf("update webhook secrets: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *WebhookRepository) Update(ctx context.Context, webhook *models.Webhook) error {
+	url, err := r.cipher.Encrypt(webhook.URL, columnAAD("webhooks", "url", webhook.ID))
+	if err != nil {
+		return fmt.Errorf("encrypt webhook url: %w", err)
+	}
+	if err := r.db.QueryRowContext(ctx, `
+	UPDATE webhooks
+	SET url = $1, event_types = $2, is_active = $3, updated_at = NOW()
+	WHERE id = $4
+	RETURNING updated_at
+	`, url, pq.Array(webhook.EventTypes), webhook.IsActive, webhook.ID).Scan(&webhook.UpdatedAt); err != nil {
+		return fmt.Errorf("update webhook: %w", err)
+	}
+	return nil
+}
+
+// ReencryptSecrets は平文または旧鍵で暗号化されたURL・シークレットを現在の主鍵で暗号化し直し、更新した件数を返す。
+func (r *WebhookRepository) ReencryptSecrets(ctx context.Context) (int, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, url, secret FROM webhooks ORDER BY id`)
+	if err != nil {
+		return 0, fmt.Errorf("select webhook secrets: %w", err)
+	}
+	type storedSecrets struct {
+		id          int64
+		url, secret string
+	}
+	var targets []storedSecrets
+	for rows.Next() {
+		var t storedSecrets
+		if err := rows.Scan(&t.id, &t.url, &t.secret); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan webhook secrets: %w", err)
+		}
+		if r.cipher.NeedsReencrypt(t.url) || r.cipher.NeedsReencrypt(t.secret) {
+			targets = append(targets, t)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, t := range targets {
+		url, err := r.cipher.Reencrypt(t.url, columnAAD("webhooks", "url", t.id))
+		if err != nil {
+			return updated, fmt.Errorf("webhook %d: %w", t.id, err)
+		}
+		secret, err := r.cipher.Reencrypt(t.secret, columnAAD("webhooks", "secret", t.id))
+		if err != nil {
+			return updated, fmt.Errorf("webhook %d: %w", t.id, err)
+		}
+		res, err := r.db.ExecContext(ctx, `
+		UPDATE webhooks SET url = $2, secret = $3
+		WHERE id = $1 AND url = $4 AND secret = $5
+		`, t.id, url, secret, t.url, t.secret)
+		if err != nil {
+			return updated, fmt.Errorf("update webhook %d secrets: %w", t.id, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+func (r *WebhookRepository) Delete(ctx context.Context, id int64) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+	return nil
+}
+
+const deliveryColumns = `
+	id, webhook_id, event_id, event_type, payload, status, attempts,
+	next_attempt_at, response_status, last_error, replay_of, created_at, delivered_at
+`
+
+func scanDelivery(row rowScanner, delivery *models.WebhookDelivery) error {
+	var responseStatus sql.NullInt64
+	if err := row.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&responseStatus,
+		&delivery.LastError,
+		&delivery.ReplayOf,
+		&delivery.CreatedAt,
+		&delivery.DeliveredAt,
+	); err != nil {
+		return err
+	}
+	if responseStatus.Valid {
+		code := int(responseStatus.Int64)
+		delivery.ResponseStatus = &code
+	}
+	return nil
+}
+
+func (r *WebhookRepository) queryDeliveries(ctx context.Context, query string, args ...any) ([]models.WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		if err := scanDelivery(rows, &delivery); err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// CreateDelivery は配信待ちの履歴を追加する。
+func (r *WebhookRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if err := r.db.QueryRowContext(ctx, `
+	INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, replay_of)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, status, attempts, next_attempt_at, last_error, created_at
+	`, delivery.WebhookID, delivery.EventID, delivery.EventType, delivery.Payload, delivery.ReplayOf).Scan(
+		&delivery.ID,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+	); err != nil {
+		return fmt.Errorf("insert webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepository) GetDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	row := r.db.QueryRowContext(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = $1`, id)
+	if err := scanDelivery(row, &delivery); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("select webhook delivery: %w", err)
+	}
+	return &delivery, nil
+}
+
+// ListDeliveries はWebhookの配信履歴を新しい順に返す。
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, webhookID int64, limit int) ([]models.WebhookDelivery, error) {
+	return r.queryDeliveries(ctx, `
+	SELECT `+deliveryColumns+` FROM webhook_deliveries
+	WHERE webhook_id = $1
+	ORDER BY created_at DESC, id DESC
+	LIMIT $2
+	`, webhookID, limit)
+}
+
+// ClaimDue は再試行時刻を迎えた配信を取得し、他プロセスと重複しないようleaseだけ次回時刻を先送りする。
+func (r *WebhookRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	return r.queryDeliveries(ctx, `
+	UPDATE webhook_deliveries SET next_attempt_at = $2
+	WHERE id IN (
+		SELECT id FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= $1
+		ORDER BY next_attempt_at ASC
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING `+deliveryColumns, now, now.Add(lease), limit)
+}
+
+// RecordAttempt は配信試行の結果を保存する。
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if _, err := r.db.ExecContext(ctx, `
+	UPDATE webhook_deliveries
+	SET status = $1, attempts = $2, next_attempt_at = $3,
+		response_status = $4, last_error = $5, delivered_at = $6
+	WHERE id = $7
+	`, delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.ResponseStatus,
+		delivery.LastError, delivery.DeliveredAt, delivery.ID); err != nil {
+		return fmt.Errorf("update webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepository) CleanupDeliveries(ctx context.Context, before time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE created_at < $1 AND status <> 'pending'`, before); err != nil {
+		return fmt.Errorf("cleanup webhook deliveries: %w", err)
+	}
+	return nil
+}
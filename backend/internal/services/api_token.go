@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"connpass-requirement/internal/models"
+	"connpass-requirement/internal/repository"
+)
+
+// APITokenPrefix はパーソナルアクセストークンの接頭辞。JWTと区別するために付ける。
+const APITokenPrefix = "cpr_"
+
+// apiTokenDisplayLength は一覧で識別用に表示するトークン先頭の文字数
+const apiTokenDisplayLength = 12
+
+// APITokenService はパーソナルアクセストークンの発行と検証を行う。
+type APITokenService struct {
+	tokens *repository.APITokenRepository
+}
+
+func NewAPITokenService(tokens *repository.APITokenRepository) *APITokenService {
+	return &APITokenService{tokens: tokens}
+}
+
+// IsAPIToken はBearerトークンがパーソナルアクセストークンの形式か判定する。
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// Create はトークンを発行し、平文のトークンを返す。平文はこの時点でしか取得できない。
+func (s *APITokenService) Create(ctx context.Context, token *models.APIToken) (string, error) {
+	random, err := randomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("generate api token: %w", err)
+	}
+	plaintext := APITokenPrefix + random
+	token.TokenPrefix = plaintext[:apiTokenDisplayLength]
+	if err := s.tokens.Create(ctx, token, hashSessionToken(plaintext)); err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+// Authenticate は有効なトークンと所有者のDiscordユーザーIDを返す。該当しない場合はnil。
+func (s *APITokenService) Authenticate(ctx context.Context, plaintext string) (*models.APIToken, string, error) {
+	return s.tokens.FindActiveByHash(ctx, hashSessionToken(plaintext))
+}
+
+// RecordUse はトークンの利用を履歴に残す。
+func (s *APITokenService) RecordUse(ctx context.Context, token models.APIToken, method, path string, status int, ipAddress, userAgent string) error {
+	return s.tokens.RecordUse(ctx, models.APITokenUse{
+		TokenID:   token.ID,
+		UserID:    token.UserID,
+		Method:    method,
+		Path:      path,
+		Status:    status,
+		IPAddress: ipAddress,
+		UserAgent: truncate(userAgent, 512),
+	})
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"connpass-requirement/internal/models"
+	"connpass-requirement/internal/repository"
+)
+
+// auditIgnoredFields は差分に含めない項目。操作のたびに変わり、実行者・日時は監査ログ自体に残る。
+var auditIgnoredFields = map[string]struct{}{
+	"createdAt": {},
+	"updatedAt": {},
+	"updatedBy": {},
+}
+
+// AuditService は設定変更の監査ログを記録する。
+type AuditService struct {
+	audits *repository.AuditRepository
+	logger *LoggerService
+}
+
+func NewAuditService(audits *repository.AuditRepository, logger *LoggerService) *AuditService {
+	return &AuditService{audits: audits, logger: logger}
+}
+
+// Record は変更前後の状態と差分を記録する。作成時はbefore、削除時はafterにnilを渡す。
+// 記録に失敗しても操作自体は完了しているため、エラーはログに残して呼び出し元へは返さない。
+func (s *AuditService) Record(ctx context.Context, event models.AuditEvent, before, after any) {
+	if err := s.record(ctx, &event, before, after); err != nil {
+		s.logger.Error(ctx, "database_error", "監査ログの記録に失敗", map[string]any{
+			"guildId": event.GuildID,
+			"action":  event.Action,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (s *AuditService) record(ctx context.Context, event *models.AuditEvent, before, after any) error {
+	var err error
+	if event.Before, err = marshalAuditState(before); err != nil {
+		return err
+	}
+	if event.After, err = marshalAuditState(after); err != nil {
+		return err
+	}
+	if event.Diff, err = DiffJSON(event.Before, event.After); err != nil {
+		return err
+	}
+	event.UserAgent = truncate(event.UserAgent, 512)
+	return s.audits.Create(ctx, event)
+}
+
+func marshalAuditState(state any) (json.RawMessage, error) {
+	if state == nil || (reflect.ValueOf(state).Kind() == reflect.Pointer && reflect.ValueOf(state).IsNil()) {
+		return nil, nil
+	}
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("marshal audit state: %w", err)
+	}
+	return raw, nil
+}
+
+// DiffJSON は2つのJSONオブジェクトを比較し、値が変わった最上位の項目を{"項目": {"before": ..., "after": ...}}の形で返す。
+func DiffJSON(before, after json.RawMessage) (json.RawMessage, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := map[string]map[string]any{}
+	add := func(key string) {
+		if _, ok := auditIgnoredFields[key]; ok {
+			return
+		}
+		if _, done := diff[key]; done {
+			return
+		}
+		b, a := beforeFields[key], afterFields[key]
+		if reflect.DeepEqual(b, a) {
+			return
+		}
+		diff[key] = map[string]any{"before": b, "after": a}
+	}
+	for key := range beforeFields {
+		add(key)
+	}
+	for key := range afterFields {
+		add(key)
+	}
+
+	raw, err := json.Marshal(diff)
+	if err != nil {
+		return nil, fmt.Errorf("marshal audit diff: %w", err)
+	}
+	return raw, nil
+}
+
+func auditFields(raw json.RawMessage) (map[string]any, error) {
+	fields := map[string]any{}
+	if len(raw) == 0 {
+		return fields, nil
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("unmarshal audit state: %w", err)
+	}
+	return fields, nil
+}
@@ -32,8 +32,44 @@ func NewConnpassService(cfg config.Config) *ConnpassService {
 	}
 }
 
-// FetchEvents はキーワードと開催地からイベントを取得する。
-func (s *ConnpassService) FetchEvents(ctx context.Context, keyword, location string) ([]models.Event, error) {
+// maxQueryDays はymdパラメータで開催日を列挙する上限日数。
+// これを超える期間はローカルフィルタのみで判定する。
+const maxQueryDays = 31
+
+// EventQuery はconnpass APIへ渡す検索条件。
+type EventQuery struct {
+	Keyword     string
+	Location    string
+	Prefectures []string
+	Dates       []time.Time
+}
+
+// QueryForRule はルール設定のうちAPIで絞り込める条件を検索条件へ変換する。
+func QueryForRule(rule models.Rule, keyword string, now time.Time) EventQuery {
+	query := EventQuery{
+		Keyword:  keyword,
+		Location: rule.Location,
+	}
+
+	switch rule.VenueType {
+	case models.VenueTypeOnline:
+		query.Prefectures = []string{onlinePrefecture}
+	default:
+		query.Prefectures = rule.Prefectures
+	}
+
+	if rule.WithinDays > 0 && rule.WithinDays <= maxQueryDays {
+		today := now.In(jst)
+		for i := 0; i <= rule.WithinDays; i++ {
+			query.Dates = append(query.Dates, today.AddDate(0, 0, i))
+		}
+	}
+
+	return query
+}
+
+// FetchEvents は検索条件に合致するイベントを取得する。
+func (s *ConnpassService) FetchEvents(ctx context.Context, query EventQuery) ([]models.Event, error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
@@ -46,10 +82,16 @@ func (s *ConnpassService) FetchEvents(ctx context.Context, keyword, location str
 	}
 
 	q := u.Query()
-	q.Set("keyword", keyword)
+	q.Set("keyword", query.Keyword)
 	q.Set("count", strconv.Itoa(20))
-	if location != "" {
-		q.Set("address", location)
+	if query.Location != "" {
+		q.Set("address", query.Location)
+	}
+	for _, prefecture := range query.Prefectures {
+		q.Add("prefecture", prefecture)
+	}
+	for _, date := range query.Dates {
+		q.Add("ymd", date.Format("20060102"))
 	}
 	u.RawQuery = q.Encode()
 
@@ -78,6 +120,7 @@ func (s *ConnpassService) FetchEvents(ctx context.Context, keyword, location str
 		Events []struct {
 			ID            int64  `json:"id"`
 			Title         string `json:"title"`
+			Catch         string `json:"catch"`
 			URL           string `json:"url"`
 			StartedAt     string `json:"started_at"`
 			EndedAt       string `json:"ended_at"`
@@ -86,6 +129,8 @@ func (s *ConnpassService) FetchEvents(ctx context.Context, keyword, location str
 			Waiting       int    `json:"waiting"`
 			UpdatedAt     string `json:"updated_at"`
 			OwnerNickname string `json:"owner_nickname"`
+			Address       string `json:"address"`
+			Place         string `json:"place"`
 			Group         struct {
 				Title string `json:"title"`
 			} `json:"group"`
@@ -107,6 +152,7 @@ func (s *ConnpassService) FetchEvents(ctx context.Context, keyword, location str
 		events = append(events, models.Event{
 			EventID:       ev.ID,
 			Title:         ev.Title,
+			Catch:         ev.Catch,
 			EventURL:      ev.URL,
 			StartedAt:     startedAt,
 			EndedAt:       endedAt,
@@ -117,6 +163,8 @@ func (s *ConnpassService) FetchEvents(ctx context.Context, keyword, location str
 			RetrievedAt:   time.Now().UTC(),
 			OwnerNickname: ev.OwnerNickname,
 			SeriesTitle:   ev.Group.Title,
+			Address:       ev.Address,
+			Place:         ev.Place,
 			HashDigest:    hex.EncodeToString(hash[:]),
 		})
 	}
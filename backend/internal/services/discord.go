@@ -115,6 +115,49 @@ func (s *DiscordService) IsBotInGuild(ctx context.Context, guildID string) (bool
 	return false, fmt.Errorf("get guild %s: %w", guildID, err)
 }
 
+// BotGuildIDs はゲートウェイのステートからBotが参加しているギルドIDを返す。
+// ステートが未取得（接続前など）の場合はokがfalse。
+func (s *DiscordService) BotGuildIDs() (map[string]bool, bool) {
+	state := s.session.State
+	if state == nil {
+		return nil, false
+	}
+	state.RLock()
+	defer state.RUnlock()
+	if state.Ready.User == nil {
+		return nil, false
+	}
+	ids := make(map[string]bool, len(state.Guilds))
+	for _, guild := range state.Guilds {
+		ids[guild.ID] = true
+	}
+	return ids, true
+}
+
+// MemberRoleIDs はギルドメンバーが持つDiscordロールIDを返す。
+func (s *DiscordService) MemberRoleIDs(ctx context.Context, guildID, discordUserID string) ([]string, error) {
+	member, err := s.session.GuildMember(guildID, discordUserID)
+	if err != nil {
+		if isMissingAccessErr(err) {
+			return nil, ErrMissingAccess
+		}
+		return nil, fmt.Errorf("get guild member %s: %w", guildID, err)
+	}
+	return member.Roles, nil
+}
+
+// ListRoles はギルドのDiscordロール一覧を返す。
+func (s *DiscordService) ListRoles(ctx context.Context, guildID string) ([]*discordgo.Role, error) {
+	roles, err := s.session.GuildRoles(guildID)
+	if err != nil {
+		if isMissingAccessErr(err) {
+			return nil, ErrMissingAccess
+		}
+		return nil, fmt.Errorf("get guild roles %s: %w", guildID, err)
+	}
+	return roles, nil
+}
+
 func isMissingAccessErr(err error) bool {
 	var restErr *discordgo.RESTError
 	if !errors.As(err, &restErr) {
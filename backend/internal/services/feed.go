@@ -0,0 +1,262 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"connpass-requirement/internal/models"
+)
+
+// GenerateFeedToken は推測困難なフィード用トークンと、保存用のハッシュを生成する。
+func GenerateFeedToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("generate feed token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashFeedToken(token), nil
+}
+
+// HashFeedToken はフィード用トークンのハッシュを返す。
+func HashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+const icalTimeFormat = "20060102T150405Z"
+
+// BuildICalendar はイベント一覧をRFC 5545形式のカレンダーへ変換する。
+// UIDはevent_idから導出し、内容のハッシュが変わるたびにSEQUENCEとLAST-MODIFIEDが進む。
+func BuildICalendar(name string, events []models.Event) []byte {
+	var b strings.Builder
+	line := func(s string) {
+		b.WriteString(foldICalLine(s))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//connpass-requirement//Event Feed//JA")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escapeICalText(name))
+	line("X-WR-TIMEZONE:Asia/Tokyo")
+
+	for _, event := range events {
+		revisedAt := event.RevisedAt
+		if revisedAt.IsZero() {
+			revisedAt = event.RetrievedAt
+		}
+
+		line("BEGIN:VEVENT")
+		line(fmt.Sprintf("UID:connpass-event-%d@connpass-requirement", event.EventID))
+		line("DTSTAMP:" + revisedAt.UTC().Format(icalTimeFormat))
+		line("LAST-MODIFIED:" + revisedAt.UTC().Format(icalTimeFormat))
+		line(fmt.Sprintf("SEQUENCE:%d", event.Revision))
+		line("DTSTART:" + event.StartedAt.UTC().Format(icalTimeFormat))
+		if !event.EndedAt.IsZero() {
+			line("DTEND:" + event.EndedAt.UTC().Format(icalTimeFormat))
+		}
+		line("SUMMARY:" + escapeICalText(event.Title))
+		line("DESCRIPTION:" + escapeICalText(icalDescription(event)))
+		if location := strings.TrimSpace(strings.Join([]string{event.Place, event.Address}, " ")); location != "" {
+			line("LOCATION:" + escapeICalText(location))
+		}
+		if event.EventURL != "" {
+			line("URL:" + event.EventURL)
+		}
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return []byte(b.String())
+}
+
+func icalDescription(event models.Event) string {
+	var parts []string
+	if event.Catch != "" {
+		parts = append(parts, event.Catch)
+	}
+	if event.Limit > 0 {
+		parts = append(parts, fmt.Sprintf("参加者: %d/%d (補欠 %d)", event.Accepted, event.Limit, event.Waiting))
+	} else {
+		parts = append(parts, fmt.Sprintf("参加者: %d", event.Accepted))
+	}
+	if event.EventURL != "" {
+		parts = append(parts, event.EventURL)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// escapeICalText はTEXT値の特殊文字をエスケープする。
+func escapeICalText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		`;`, `\;`,
+		`,`, `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// foldICalLine は75オクテットを超える行をUTF-8の文字境界で折り返す。
+func foldICalLine(s string) string {
+	const limit = 75
+	if len(s) <= limit {
+		return s
+	}
+
+	var b strings.Builder
+	width := 0
+	maxWidth := limit
+	for _, r := range s {
+		size := utf8.RuneLen(r)
+		if width+size > maxWidth {
+			b.WriteString("\r\n ")
+			width = 0
+			// 継続行は先頭の空白を含めて75オクテットに収める
+			maxWidth = limit - 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published"`
+	Links     []atomLink  `xml:"link"`
+	Summary   string      `xml:"summary,omitempty"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// BuildAtomFeed はルールが一致したイベントと通知履歴をAtomフィードへ変換する。
+// エントリは最終更新（内容の変化または通知）の新しい順に並ぶ。
+func BuildAtomFeed(rule models.Rule, selfURL string, events []models.Event, notifications []models.Notification) ([]byte, error) {
+	history := make(map[int64][]models.Notification)
+	for _, n := range notifications {
+		history[n.EventID] = append(history[n.EventID], n)
+	}
+
+	feed := atomFeed{
+		ID:     fmt.Sprintf("urn:connpass-requirement:rule:%d", rule.ID),
+		Title:  rule.Name,
+		Links:  []atomLink{{Rel: "self", Href: selfURL}},
+		Author: atomAuthor{Name: "connpass-requirement"},
+	}
+
+	feedUpdated := rule.UpdatedAt
+	type entryWithTime struct {
+		entry   atomEntry
+		updated time.Time
+	}
+	entries := make([]entryWithTime, 0, len(events))
+	for _, event := range events {
+		updated := event.RevisedAt
+		if updated.IsZero() {
+			updated = event.RetrievedAt
+		}
+		sent := history[event.EventID]
+		for _, n := range sent {
+			if n.SentAt.After(updated) {
+				updated = n.SentAt
+			}
+		}
+		if updated.After(feedUpdated) {
+			feedUpdated = updated
+		}
+
+		published := updated
+		if len(sent) > 0 {
+			published = sent[0].SentAt
+		}
+
+		entry := atomEntry{
+			ID:        fmt.Sprintf("urn:connpass-requirement:rule:%d:event:%d", rule.ID, event.EventID),
+			Title:     event.Title,
+			Updated:   updated.UTC().Format(time.RFC3339),
+			Published: published.UTC().Format(time.RFC3339),
+			Summary:   event.Catch,
+			Content:   atomContent{Type: "text", Body: atomContentText(event, sent)},
+		}
+		if event.EventURL != "" {
+			entry.Links = []atomLink{{Rel: "alternate", Href: event.EventURL}}
+		}
+		entries = append(entries, entryWithTime{entry: entry, updated: updated})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].updated.After(entries[j].updated)
+	})
+	for _, e := range entries {
+		feed.Entries = append(feed.Entries, e.entry)
+	}
+	feed.Updated = feedUpdated.UTC().Format(time.RFC3339)
+
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal atom feed: %w", err)
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+func atomContentText(event models.Event, sent []models.Notification) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("開催日時: %s\n", event.StartedAt.In(jst).Format("2006/01/02 15:04")))
+	if location := strings.TrimSpace(event.Place + " " + event.Address); location != "" {
+		b.WriteString(fmt.Sprintf("会場: %s\n", location))
+	}
+	if event.Limit > 0 {
+		b.WriteString(fmt.Sprintf("参加者: %d/%d (補欠 %d)\n", event.Accepted, event.Limit, event.Waiting))
+	} else {
+		b.WriteString(fmt.Sprintf("参加者: %d\n", event.Accepted))
+	}
+	if len(sent) > 0 {
+		b.WriteString("通知履歴:\n")
+		for _, n := range sent {
+			line := fmt.Sprintf("- %s %s", n.SentAt.In(jst).Format("2006/01/02 15:04"), n.NotifyKey)
+			if n.Baseline {
+				line += " (ベースライン)"
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	b.WriteString(event.EventURL)
+	return b.String()
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"connpass-requirement/internal/models"
+)
+
+// jst は開催曜日・時間帯を判定する基準タイムゾーン。
+var jst = time.FixedZone("JST", 9*60*60)
+
+// onlinePrefecture はconnpassでオンライン開催を表す都道府県コード。
+const onlinePrefecture = "online"
+
+// prefectureCodes はconnpass APIの都道府県コード一覧。
+var prefectureCodes = map[string]bool{
+	"hokkaido": true, "aomori": true, "iwate": true, "miyagi": true, "akita": true,
+	"yamagata": true, "fukushima": true, "ibaraki": true, "tochigi": true, "gunma": true,
+	"saitama": true, "chiba": true, "tokyo": true, "kanagawa": true, "niigata": true,
+	"toyama": true, "ishikawa": true, "fukui": true, "yamanashi": true, "nagano": true,
+	"gifu": true, "shizuoka": true, "aichi": true, "mie": true, "shiga": true,
+	"kyoto": true, "osaka": true, "hyogo": true, "nara": true, "wakayama": true,
+	"tottori": true, "shimane": true, "okayama": true, "hiroshima": true, "yamaguchi": true,
+	"tokushima": true, "kagawa": true, "ehime": true, "kochi": true, "fukuoka": true,
+	"saga": true, "nagasaki": true, "kumamoto": true, "oita": true, "miyazaki": true,
+	"kagoshima": true, "okinawa": true,
+}
+
+// IsValidPrefecture はconnpassの都道府県コードとして有効か判定する。
+func IsValidPrefecture(code string) bool {
+	return prefectureCodes[code]
+}
+
+// ルール条件による除外理由。
+const (
+	FilterReasonVenueOnline   = "venue_online"
+	FilterReasonVenueOffline  = "venue_offline"
+	FilterReasonWeekday       = "weekday"
+	FilterReasonTimeOfDay     = "time_of_day"
+	FilterReasonBeyondHorizon = "beyond_horizon"
+)
+
+// MatchFilters はAPIで絞り込めないルール条件をローカルで判定する。
+// 条件を満たさない場合は除外理由を返す。
+func MatchFilters(rule models.Rule, event models.Event, now time.Time) (bool, string) {
+	switch rule.VenueType {
+	case models.VenueTypeOnline:
+		if !isOnlineEvent(event) {
+			return false, FilterReasonVenueOffline
+		}
+	case models.VenueTypeOffline:
+		if isOnlineEvent(event) {
+			return false, FilterReasonVenueOnline
+		}
+	}
+
+	startedAt := event.StartedAt.In(jst)
+
+	if len(rule.Weekdays) > 0 {
+		matched := false
+		for _, day := range rule.Weekdays {
+			if time.Weekday(day) == startedAt.Weekday() {
+				matched = true
+				break
+			}
+		}
+		if !matched || event.StartedAt.IsZero() {
+			return false, FilterReasonWeekday
+		}
+	}
+
+	if rule.TimeFrom != "" || rule.TimeTo != "" {
+		if event.StartedAt.IsZero() || !withinTimeOfDay(startedAt, rule.TimeFrom, rule.TimeTo) {
+			return false, FilterReasonTimeOfDay
+		}
+	}
+
+	if rule.WithinDays > 0 && !event.StartedAt.Before(WithinDaysHorizon(now, rule.WithinDays)) {
+		return false, FilterReasonBeyondHorizon
+	}
+
+	return true, ""
+}
+
+// WithinDaysHorizon はwithinDaysの対象期間の終端（JSTでnowのwithinDays日後の翌日0時）を返す。
+// connpass APIのymd指定（今日〜withinDays日後の日単位）と同じ範囲になる。
+func WithinDaysHorizon(now time.Time, withinDays int) time.Time {
+	today := now.In(jst)
+	return time.Date(today.Year(), today.Month(), today.Day()+withinDays+1, 0, 0, 0, 0, jst)
+}
+
+func isOnlineEvent(event models.Event) bool {
+	if strings.TrimSpace(event.Address) == "" && strings.TrimSpace(event.Place) == "" {
+		return true
+	}
+	for _, text := range []string{event.Address, event.Place} {
+		lower := strings.ToLower(text)
+		if strings.Contains(lower, "オンライン") || strings.Contains(lower, "online") {
+			return true
+		}
+	}
+	return false
+}
+
+// withinTimeOfDay は時刻がfrom〜toの範囲か判定する。from>toの場合は日跨ぎとして扱う。
+func withinTimeOfDay(t time.Time, from, to string) bool {
+	minute := t.Hour()*60 + t.Minute()
+	start := 0
+	end := 24 * 60
+	if v, ok := parseClock(from); ok {
+		start = v
+	}
+	if v, ok := parseClock(to); ok {
+		end = v
+	}
+	if start <= end {
+		return minute >= start && minute <= end
+	}
+	return minute >= start || minute <= end
+}
+
+// parseClock は"HH:MM"形式を0時からの経過分へ変換する。
+func parseClock(value string) (int, bool) {
+	if value == "" {
+		return 0, false
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// IsValidClock は"HH:MM"形式の時刻か判定する。空文字は未指定として有効。
+func IsValidClock(value string) bool {
+	if value == "" {
+		return true
+	}
+	_, ok := parseClock(value)
+	return ok
+}
+
+// MatchExclusions はルールの除外リストに該当するか判定する。
+// 該当した場合は除外種別と一致した値を返す。
+func MatchExclusions(rule models.Rule, event models.Event) (bool, string, string) {
+	targets := []string{NormalizeText(event.Title), NormalizeText(event.Catch)}
+	for _, keyword := range rule.ExcludeKeywords {
+		normalized := NormalizeText(strings.TrimSpace(keyword))
+		if normalized == "" {
+			continue
+		}
+		for _, target := range targets {
+			if strings.Contains(target, normalized) {
+				return true, models.ExclusionKindKeyword, keyword
+			}
+		}
+	}
+	for _, owner := range rule.ExcludeOwners {
+		if owner != "" && strings.EqualFold(event.OwnerNickname, owner) {
+			return true, models.ExclusionKindOwner, owner
+		}
+	}
+	for _, series := range rule.ExcludeSeries {
+		if series != "" && strings.EqualFold(event.SeriesTitle, series) {
+			return true, models.ExclusionKindSeries, series
+		}
+	}
+	return false, "", ""
+}
+
+// FilterReasonExcluded は除外リストに該当したことを表す。
+const FilterReasonExcluded = "excluded"
+
+// ScreenResult はルール条件によるイベント選別の結果。Reasonが空なら通過。
+type ScreenResult struct {
+	Reason         string `json:"reason,omitempty"`
+	ExclusionKind  string `json:"exclusionKind,omitempty"`
+	ExclusionValue string `json:"exclusionValue,omitempty"`
+}
+
+// ScreenEvent は開催条件フィルタ・除外リスト・ローカル照合を順に適用する。
+// matcherがnil（照合パターンが不正）の場合は一致なしとして扱う。
+func ScreenEvent(rule models.Rule, matcher *KeywordMatcher, event models.Event, now time.Time) ScreenResult {
+	if ok, reason := MatchFilters(rule, event, now); !ok {
+		return ScreenResult{Reason: reason}
+	}
+	if excluded, kind, value := MatchExclusions(rule, event); excluded {
+		return ScreenResult{Reason: FilterReasonExcluded, ExclusionKind: kind, ExclusionValue: value}
+	}
+	if matcher == nil || !matcher.Match(event) {
+		return ScreenResult{Reason: FilterReasonNoMatch}
+	}
+	return ScreenResult{}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"connpass-requirement/internal/models"
+)
+
+func jstTime(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, jst)
+}
+
+func TestWithinTimeOfDay(t *testing.T) {
+	tests := []struct {
+		name     string
+		at       time.Time
+		from, to string
+		want     bool
+	}{
+		{name: "範囲内", at: jstTime(2026, 10, 20, 19, 0), from: "18:00", to: "21:00", want: true},
+		{name: "開始時刻ちょうど", at: jstTime(2026, 10, 20, 18, 0), from: "18:00", to: "21:00", want: true},
+		{name: "終了時刻ちょうど", at: jstTime(2026, 10, 20, 21, 0), from: "18:00", to: "21:00", want: true},
+		{name: "範囲外", at: jstTime(2026, 10, 20, 12, 0), from: "18:00", to: "21:00", want: false},
+		{name: "開始のみ指定", at: jstTime(2026, 10, 20, 23, 59), from: "18:00", to: "", want: true},
+		{name: "終了のみ指定", at: jstTime(2026, 10, 20, 9, 0), from: "", to: "10:00", want: true},
+		{name: "日跨ぎ・開始側", at: jstTime(2026, 10, 20, 23, 0), from: "22:00", to: "02:00", want: true},
+		{name: "日跨ぎ・終了側", at: jstTime(2026, 10, 21, 1, 30), from: "22:00", to: "02:00", want: true},
+		{name: "日跨ぎ・範囲外", at: jstTime(2026, 10, 20, 12, 0), from: "22:00", to: "02:00", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withinTimeOfDay(tt.at, tt.from, tt.to); got != tt.want {
+				t.Errorf("withinTimeOfDay(%s, %q, %q) = %v, want %v", tt.at.Format("15:04"), tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchFilters(t *testing.T) {
+	now := jstTime(2026, 10, 20, 10, 0) // 火曜日
+	offline := models.Event{Address: "東京都渋谷区", Place: "会議室"}
+	at := func(event models.Event, start time.Time) models.Event {
+		event.StartedAt = start
+		return event
+	}
+
+	tests := []struct {
+		name       string
+		rule       models.Rule
+		event      models.Event
+		wantOK     bool
+		wantReason string
+	}{
+		{name: "条件なし", rule: models.Rule{}, event: at(offline, now), wantOK: true},
+		{
+			name:       "オンライン限定でオフライン開催",
+			rule:       models.Rule{VenueType: models.VenueTypeOnline},
+			event:      at(offline, now),
+			wantReason: FilterReasonVenueOffline,
+		},
+		{
+			name:       "オフライン限定でオンライン開催",
+			rule:       models.Rule{VenueType: models.VenueTypeOffline},
+			event:      at(models.Event{Place: "オンライン"}, now),
+			wantReason: FilterReasonVenueOnline,
+		},
+		{
+			name:   "曜日はJSTで判定",
+			rule:   models.Rule{Weekdays: []int{int(time.Wednesday)}},
+			event:  at(offline, time.Date(2026, 10, 20, 16, 0, 0, 0, time.UTC)), // JSTでは水曜1時
+			wantOK: true,
+		},
+		{
+			name:       "曜日が対象外",
+			rule:       models.Rule{Weekdays: []int{int(time.Saturday)}},
+			event:      at(offline, now),
+			wantReason: FilterReasonWeekday,
+		},
+		{
+			name:       "開始日時がない場合は曜日条件を満たさない",
+			rule:       models.Rule{Weekdays: []int{int(time.Tuesday)}},
+			event:      offline,
+			wantReason: FilterReasonWeekday,
+		},
+		{
+			name:   "日跨ぎの時間帯",
+			rule:   models.Rule{TimeFrom: "22:00", TimeTo: "02:00"},
+			event:  at(offline, jstTime(2026, 10, 21, 0, 30)),
+			wantOK: true,
+		},
+		{
+			name:       "時間帯の対象外",
+			rule:       models.Rule{TimeFrom: "22:00", TimeTo: "02:00"},
+			event:      at(offline, jstTime(2026, 10, 21, 19, 0)),
+			wantReason: FilterReasonTimeOfDay,
+		},
+		{
+			name:   "withinDays日後の終わりまでは対象",
+			rule:   models.Rule{WithinDays: 7},
+			event:  at(offline, jstTime(2026, 10, 27, 23, 59)),
+			wantOK: true,
+		},
+		{
+			name:       "withinDays日後の翌日は対象外",
+			rule:       models.Rule{WithinDays: 7},
+			event:      at(offline, jstTime(2026, 10, 28, 0, 0)),
+			wantReason: FilterReasonBeyondHorizon,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, reason := MatchFilters(tt.rule, tt.event, now)
+			if ok != tt.wantOK || reason != tt.wantReason {
+				t.Errorf("MatchFilters() = (%v, %q), want (%v, %q)", ok, reason, tt.wantOK, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestWithinDaysHorizonMatchesQueryDates(t *testing.T) {
+	// UTCでは前日でもJSTの日付を基準にする
+	now := time.Date(2026, 10, 19, 16, 0, 0, 0, time.UTC)
+	horizon := WithinDaysHorizon(now, 3)
+	if want := jstTime(2026, 10, 24, 0, 0); !horizon.Equal(want) {
+		t.Fatalf("WithinDaysHorizon() = %s, want %s", horizon, want)
+	}
+
+	query := QueryForRule(models.Rule{WithinDays: 3}, "go", now)
+	last := query.Dates[len(query.Dates)-1].In(jst)
+	if !horizon.After(last) || horizon.Sub(last) > 24*time.Hour {
+		t.Errorf("last query date %s is not the day before horizon %s", last.Format("20060102"), horizon)
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"time"
+
+	"connpass-requirement/internal/models"
+)
+
+const (
+	// forecastWindow は申込ペースの算出に使う直近の観測期間。
+	forecastWindow = 24 * time.Hour
+	// minForecastSpan はペースを算出するために必要な最短の観測期間。
+	minForecastSpan = time.Hour
+	// DefaultFillHorizonHours はfilling_fastの既定の予測期間。
+	DefaultFillHorizonHours = 24
+	// MaxFillHorizonHours はfilling_fastの予測期間の上限。
+	MaxFillHorizonHours = 168
+)
+
+// FillForecast は直近の申込ペースから見た満席までの見込み。
+type FillForecast struct {
+	VelocityPerHour float64   `json:"velocityPerHour"`
+	Remaining       int       `json:"remaining"`
+	HoursToFull     float64   `json:"hoursToFull"`
+	ProjectedFullAt time.Time `json:"projectedFullAt"`
+}
+
+// ForecastFill は観測順のスナップショットから申込ペースを求め、満席到達時刻を予測する。
+// snapshotsには観測期間の開始時点の状態を表すため、期間開始以前の直近1件を含めてよい。
+// 予測できない場合（定員なし・満席済み・増加なし・観測不足）はnilを返す。
+func ForecastFill(event models.Event, snapshots []models.EventSnapshot, now time.Time) *FillForecast {
+	if event.Limit <= 0 || event.Accepted >= event.Limit {
+		return nil
+	}
+
+	windowStart := now.Add(-forecastWindow)
+	var base *models.EventSnapshot
+	var baseTime time.Time
+	for i := range snapshots {
+		snapshot := &snapshots[i]
+		if snapshot.ObservedAt.After(now) {
+			break
+		}
+		if !snapshot.ObservedAt.After(windowStart) {
+			// 期間開始以前の状態は次の変化まで続いているとみなす
+			base, baseTime = snapshot, windowStart
+			continue
+		}
+		if base == nil {
+			base, baseTime = snapshot, snapshot.ObservedAt
+		}
+		break
+	}
+
+	if base == nil {
+		return nil
+	}
+	span := now.Sub(baseTime)
+	if span < minForecastSpan {
+		return nil
+	}
+	gained := event.Accepted - base.Accepted
+	if gained <= 0 {
+		return nil
+	}
+
+	velocity := float64(gained) / span.Hours()
+	remaining := event.Limit - event.Accepted
+	hours := float64(remaining) / velocity
+
+	return &FillForecast{
+		VelocityPerHour: velocity,
+		Remaining:       remaining,
+		HoursToFull:     hours,
+		ProjectedFullAt: now.Add(time.Duration(hours * float64(time.Hour))),
+	}
+}
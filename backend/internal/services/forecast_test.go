@@ -0,0 +1,60 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"connpass-requirement/internal/models"
+)
+
+func TestForecastFill(t *testing.T) {
+	now := jstTime(2026, 10, 20, 12, 0)
+	snapshot := func(ago time.Duration, accepted int) models.EventSnapshot {
+		return models.EventSnapshot{Limit: 50, Accepted: accepted, ObservedAt: now.Add(-ago)}
+	}
+	event := models.Event{Limit: 50, Accepted: 40}
+
+	t.Run("観測期間内のペースから予測", func(t *testing.T) {
+		got := ForecastFill(event, []models.EventSnapshot{snapshot(4*time.Hour, 20), snapshot(time.Hour, 35)}, now)
+		if got == nil {
+			t.Fatal("ForecastFill() = nil")
+		}
+		if got.VelocityPerHour != 5 || got.Remaining != 10 || got.HoursToFull != 2 {
+			t.Errorf("ForecastFill() = %+v, want velocity 5, remaining 10, hours 2", got)
+		}
+		if want := now.Add(2 * time.Hour); !got.ProjectedFullAt.Equal(want) {
+			t.Errorf("ProjectedFullAt = %s, want %s", got.ProjectedFullAt, want)
+		}
+	})
+
+	t.Run("期間開始以前の状態は期間開始から数える", func(t *testing.T) {
+		got := ForecastFill(event, []models.EventSnapshot{snapshot(48*time.Hour, 16), snapshot(2*time.Hour, 30)}, now)
+		if got == nil {
+			t.Fatal("ForecastFill() = nil")
+		}
+		if math.Abs(got.VelocityPerHour-1) > 1e-9 {
+			t.Errorf("VelocityPerHour = %v, want 1", got.VelocityPerHour)
+		}
+	})
+
+	tests := []struct {
+		name      string
+		event     models.Event
+		snapshots []models.EventSnapshot
+	}{
+		{name: "定員なし", event: models.Event{Accepted: 40}, snapshots: []models.EventSnapshot{snapshot(4*time.Hour, 20)}},
+		{name: "満席済み", event: models.Event{Limit: 50, Accepted: 50}, snapshots: []models.EventSnapshot{snapshot(4*time.Hour, 20)}},
+		{name: "スナップショットなし", event: event},
+		{name: "観測期間が1時間未満", event: event, snapshots: []models.EventSnapshot{snapshot(30*time.Minute, 20)}},
+		{name: "申込が増えていない", event: event, snapshots: []models.EventSnapshot{snapshot(4*time.Hour, 40)}},
+		{name: "基準時刻より後の観測は使わない", event: event, snapshots: []models.EventSnapshot{snapshot(-time.Hour, 10)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ForecastFill(tt.event, tt.snapshots, now); got != nil {
+				t.Errorf("ForecastFill() = %+v, want nil", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"connpass-requirement/internal/models"
+	"connpass-requirement/internal/repository"
+)
+
+const (
+	guildSyncBatchSize = 50
+	// guildSyncRefreshWindow はトークン期限のこの時間前からリフレッシュする
+	guildSyncRefreshWindow = 24 * time.Hour
+)
+
+// GuildSyncService はDiscordのOAuthトークンを更新し、ギルド権限を定期的に再同期する。
+type GuildSyncService struct {
+	users    *repository.UserRepository
+	oauth    *OAuthService
+	discord  *DiscordService
+	logger   *LoggerService
+	interval time.Duration
+}
+
+func NewGuildSyncService(users *repository.UserRepository, oauth *OAuthService, discord *DiscordService, logger *LoggerService, interval time.Duration) *GuildSyncService {
+	return &GuildSyncService{users: users, oauth: oauth, discord: discord, logger: logger, interval: interval}
+}
+
+// Start はintervalごとに再同期を実行する。ctxが終了するまで戻らない。
+func (s *GuildSyncService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.SyncDue(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.logger.Error(ctx, "guild_sync_failed", "ギルド権限の再同期対象の取得に失敗", map[string]any{"error": err.Error()})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SyncDue は最終同期からintervalが経過したユーザーのギルド権限を再同期し、同期した人数を返す。
+// 対象がいなくなるまでバッチを繰り返す。失敗したユーザーは同じ実行内では再試行しない。
+func (s *GuildSyncService) SyncDue(ctx context.Context) (int, error) {
+	now := time.Now()
+	attempted := []int64{}
+	synced := 0
+	for {
+		users, err := s.users.ListDueForGuildSync(ctx, now.Add(-s.interval), now.Add(guildSyncRefreshWindow), attempted, guildSyncBatchSize)
+		if err != nil {
+			return synced, err
+		}
+
+		for _, user := range users {
+			if ctx.Err() != nil {
+				return synced, ctx.Err()
+			}
+			attempted = append(attempted, user.ID)
+			if s.syncDueUser(ctx, user) {
+				synced++
+			}
+		}
+
+		if len(users) < guildSyncBatchSize {
+			return synced, nil
+		}
+	}
+}
+
+// syncDueUser は1人分の再同期を行い、成功した場合はtrueを返す。失敗はログに記録する。
+func (s *GuildSyncService) syncDueUser(ctx context.Context, user models.User) bool {
+	err := s.SyncUser(ctx, user)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, ErrOAuthRevoked) {
+		if rerr := s.users.RevokeSessions(ctx, user.ID); rerr != nil {
+			s.logger.Error(ctx, "database_error", "セッション失効に失敗", map[string]any{"userId": user.ID, "error": rerr.Error()})
+			return false
+		}
+		s.logger.Warn(ctx, "oauth_revoked", "Discordトークンが取り消されたためセッションを失効", map[string]any{"userId": user.ID})
+		return false
+	}
+	s.logger.Error(ctx, "guild_sync_failed", "ギルド権限の再同期に失敗", map[string]any{"userId": user.ID, "error": err.Error()})
+	return false
+}
+
+// SyncUser は必要に応じてトークンをリフレッシュし、所属ギルドと権限を保存し直す。
+func (s *GuildSyncService) SyncUser(ctx context.Context, user models.User) error {
+	accessToken := user.AccessToken
+	if time.Until(user.TokenExpiresAt) < guildSyncRefreshWindow {
+		token, err := s.oauth.RefreshToken(ctx, user.RefreshToken)
+		if err != nil {
+			return fmt.Errorf("refresh token: %w", err)
+		}
+		expiresAt := time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+		if err := s.users.UpdateTokens(ctx, user.ID, token.AccessToken, token.RefreshToken, expiresAt); err != nil {
+			return err
+		}
+		accessToken = token.AccessToken
+	}
+
+	guilds, err := s.oauth.FetchGuilds(ctx, accessToken)
+	if err != nil {
+		return fmt.Errorf("fetch guilds: %w", err)
+	}
+	return s.users.SaveGuildPermissions(ctx, user.ID, s.Permissions(ctx, user.ID, user.DiscordUserID, guilds))
+}
+
+// Permissions はDiscordのギルド一覧から保存用の権限情報を組み立てる。
+// Botが参加しているギルドに限り、ロール対応のためにメンバーのDiscordロールIDも取得する。
+// ロールIDを取得できなかったギルドは保存済みの値を引き継ぐ。
+func (s *GuildSyncService) Permissions(ctx context.Context, userID int64, discordUserID string, guilds []DiscordGuild) []models.GuildPermission {
+	previous := map[string][]string{}
+	if existing, err := s.users.ListGuildPermissions(ctx, userID); err != nil {
+		s.logger.Error(ctx, "database_error", "保存済みギルド権限の取得に失敗", map[string]any{"userId": userID, "error": err.Error()})
+	} else {
+		for _, perm := range existing {
+			previous[perm.GuildID] = perm.RoleIDs
+		}
+	}
+
+	var botGuilds map[string]bool
+	botGuildsKnown := false
+	if s.discord != nil {
+		botGuilds, botGuildsKnown = s.discord.BotGuildIDs()
+	}
+
+	perms := make([]models.GuildPermission, 0, len(guilds))
+	for _, guild := range guilds {
+		permValue, _ := strconv.ParseInt(guild.Permissions, 10, 64)
+		roleIDs := previous[guild.ID]
+		if botGuildsKnown {
+			roleIDs = nil
+			if botGuilds[guild.ID] {
+				roleIDs = s.memberRoleIDs(ctx, userID, guild.ID, discordUserID, previous[guild.ID])
+			}
+		}
+		perms = append(perms, models.GuildPermission{
+			GuildID:       guild.ID,
+			GuildName:     guild.Name,
+			IconURL:       guildIconURL(guild.ID, guild.Icon),
+			Permissions:   permValue,
+			CanManage:     hasPermission(permValue, 0x8) || hasPermission(permValue, 0x20),
+			CanManageRole: hasPermission(permValue, 0x20),
+			RoleIDs:       roleIDs,
+		})
+	}
+	return perms
+}
+
+// memberRoleIDs はギルドでのDiscordロールIDを取得する。取得できない場合はログに残し、previousを返す。
+func (s *GuildSyncService) memberRoleIDs(ctx context.Context, userID int64, guildID, discordUserID string, previous []string) []string {
+	roleIDs, err := s.discord.MemberRoleIDs(ctx, guildID, discordUserID)
+	if err != nil {
+		s.logger.Warn(ctx, "discord_role_fetch_failed", "DiscordロールIDの取得に失敗したため保存済みの値を使用", map[string]any{
+			"userId":  userID,
+			"guildId": guildID,
+			"error":   err.Error(),
+		})
+		return previous
+	}
+	return roleIDs
+}
+
+func guildIconURL(guildID, icon string) string {
+	if icon == "" {
+		return ""
+	}
+	return "https://cdn.discordapp.com/icons/" + guildID + "/" + icon + ".png"
+}
+
+func hasPermission(value int64, bit int64) bool {
+	return value&bit == bit
+}
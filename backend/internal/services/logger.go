@@ -11,25 +11,60 @@ import (
 
 // LoggerService は重要ログ記録を統一的に扱う。
 type LoggerService struct {
-	repo *repository.LogRepository
+	repo     *repository.LogRepository
+	webhooks *WebhookService
 }
 
 func NewLoggerService(repo *repository.LogRepository) *LoggerService {
 	return &LoggerService{repo: repo}
 }
 
+// webhookEventTypes はログ種別とWebhookで配信するイベント種別の対応。
+var webhookEventTypes = map[string]string{
+	"notification_sent":   models.WebhookEventNotificationSent,
+	"discord_send_failed": models.WebhookEventNotificationFailed,
+	"rule_created":        models.WebhookEventRuleCreated,
+	"rule_updated":        models.WebhookEventRuleUpdated,
+	"rule_deleted":        models.WebhookEventRuleDeleted,
+}
+
+// AttachWebhooks は対応するログ種別をWebhookへも配信するようにする。
+func (l *LoggerService) AttachWebhooks(webhooks *WebhookService) {
+	l.webhooks = webhooks
+}
+
 func (l *LoggerService) log(ctx context.Context, level, eventType, message string, metadata any) {
 	metaJSON, err := json.Marshal(metadata)
 	if err != nil {
 		metaJSON = []byte(`{"error":"metadata marshal failed"}`)
 	}
 
-	_ = l.repo.Save(ctx, models.ImportantLog{
+	var guildID string
+	if m, ok := metadata.(map[string]any); ok {
+		guildID, _ = m["guildId"].(string)
+	}
+	log := models.ImportantLog{
 		Level:     level,
 		EventType: eventType,
 		Message:   message,
 		Metadata:  string(metaJSON),
-	})
+	}
+	if guildID != "" {
+		log.GuildID = &guildID
+	}
+	_ = l.repo.Save(ctx, log)
+
+	if webhookEvent, ok := webhookEventTypes[eventType]; ok {
+		l.Publish(ctx, guildID, webhookEvent, message, metadata)
+	}
+}
+
+// Publish は重要ログに残さずギルドのWebhookへイベントを配信する。
+func (l *LoggerService) Publish(ctx context.Context, guildID, webhookEvent, message string, data any) {
+	if l.webhooks == nil || guildID == "" {
+		return
+	}
+	_ = l.webhooks.Emit(ctx, guildID, webhookEvent, message, data)
 }
 
 func (l *LoggerService) Info(ctx context.Context, eventType, message string, metadata any) {
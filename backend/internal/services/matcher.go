@@ -0,0 +1,165 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+
+	"connpass-requirement/internal/models"
+)
+
+// MaxFuzzyDistance はあいまい一致で許容する編集距離の上限。
+const MaxFuzzyDistance = 3
+
+// FilterReasonNoMatch はローカル照合でどのパターンにも一致しなかったことを表す。
+const FilterReasonNoMatch = "no_match"
+
+// KeywordMatcher はルールの照合パターンを前処理したもの。正規表現のコンパイルと正規化は生成時に1回だけ行う。
+type KeywordMatcher struct {
+	mode     string
+	distance int
+	patterns []string
+	regexps  []*regexp.Regexp
+}
+
+// NewKeywordMatcher はルールの照合モードとパターンから照合器を作る。パターンが不正な場合はエラー。
+func NewKeywordMatcher(rule models.Rule) (*KeywordMatcher, error) {
+	m := &KeywordMatcher{mode: rule.MatchMode, distance: rule.FuzzyDistance}
+	if m.mode == "" || m.mode == models.MatchModeOff {
+		return m, nil
+	}
+
+	patterns := rule.MatchPatterns
+	if len(patterns) == 0 {
+		patterns = rule.Keywords
+	}
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		if m.mode == models.MatchModeRegex {
+			re, err := compileRegex(pattern)
+			if err != nil {
+				return nil, err
+			}
+			m.regexps = append(m.regexps, re)
+			continue
+		}
+		m.patterns = append(m.patterns, NormalizeText(pattern))
+	}
+	return m, nil
+}
+
+// Match はタイトルとキャッチをローカルで照合する。照合モードがoffの場合は常に一致とみなす。
+func (m *KeywordMatcher) Match(event models.Event) bool {
+	if m.mode == "" || m.mode == models.MatchModeOff {
+		return true
+	}
+
+	targets := []string{event.Title, event.Catch}
+	if m.mode == models.MatchModeRegex {
+		for _, re := range m.regexps {
+			for _, target := range targets {
+				if re.MatchString(NormalizeText(target)) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for _, target := range targets {
+		normalized := NormalizeText(target)
+		for _, pattern := range m.patterns {
+			if m.mode == models.MatchModeFuzzy {
+				if fuzzyContains([]rune(normalized), []rune(pattern), m.distance) {
+					return true
+				}
+			} else if strings.Contains(normalized, pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CompilePattern は照合パターンが指定モードで利用可能か検証する。
+func CompilePattern(mode, pattern string) error {
+	if mode != models.MatchModeRegex {
+		return nil
+	}
+	_, err := compileRegex(pattern)
+	return err
+}
+
+// compileRegex は正規化済みのテキストと照合できるよう、パターンにも同じ正規化（英字の小文字化を除く）をかけてコンパイルする。
+// 大文字小文字は(?i)で区別しない。小文字化すると\Dなどのエスケープの意味が変わるため行わない。
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile("(?i)" + foldWidthAndKana(pattern))
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+// NormalizeText は表記揺れを吸収するため、全角/半角・互換漢字をNFKCで統一し、
+// ひらがなをカタカナへ寄せ、英字を小文字化する。
+func NormalizeText(s string) string {
+	return strings.ToLower(foldWidthAndKana(s))
+}
+
+// foldWidthAndKana はNFKCで全角/半角・互換漢字を統一し、ひらがなをカタカナへ寄せる。
+func foldWidthAndKana(s string) string {
+	s = norm.NFKC.String(s)
+	var builder strings.Builder
+	builder.Grow(len(s))
+	for _, r := range s {
+		if r >= 'ぁ' && r <= 'ゖ' {
+			r += 'ァ' - 'ぁ'
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// fuzzyContains はtextの部分文字列のうち、patternとの編集距離がmaxDist以下のものがあるか判定する。
+// 短いパターンがほぼ何にでも一致しないよう、許容距離はパターン長の1/4までに抑える（4文字未満は完全一致）。
+func fuzzyContains(text, pattern []rune, maxDist int) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if limit := len(pattern) / 4; maxDist > limit {
+		maxDist = limit
+	}
+	if maxDist < 0 {
+		maxDist = 0
+	}
+
+	// prev[j] は pattern[:j] とtextのある位置で終わる部分文字列との最小編集距離。
+	prev := make([]int, len(pattern)+1)
+	curr := make([]int, len(pattern)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	if prev[len(pattern)] <= maxDist {
+		return true
+	}
+
+	for _, tr := range text {
+		curr[0] = 0
+		for j := 1; j <= len(pattern); j++ {
+			cost := 1
+			if pattern[j-1] == tr {
+				cost = 0
+			}
+			curr[j] = min(prev[j-1]+cost, prev[j]+1, curr[j-1]+1)
+		}
+		if curr[len(pattern)] <= maxDist {
+			return true
+		}
+		prev, curr = curr, prev
+	}
+	return false
+}
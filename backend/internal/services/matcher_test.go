@@ -0,0 +1,82 @@
+package services
+
+import (
+	"testing"
+
+	"connpass-requirement/internal/models"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "全角英数を半角小文字へ", in: "ＧＯ言語１２３", want: "go言語123"},
+		{name: "半角カナを全角へ", in: "ｺﾞｰﾙﾝ", want: "ゴールン"},
+		{name: "ひらがなをカタカナへ", in: "もくもく会", want: "モクモク会"},
+		{name: "大文字を小文字へ", in: "TypeScript", want: "typescript"},
+		{name: "空文字", in: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeText(tt.in); got != tt.want {
+				t.Errorf("NormalizeText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFuzzyContains(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		pattern string
+		maxDist int
+		want    bool
+	}{
+		{name: "完全一致", text: "golang勉強会", pattern: "golang", maxDist: 1, want: true},
+		{name: "空パターンは常に一致", text: "abc", pattern: "", maxDist: 0, want: true},
+		{name: "4文字未満は完全一致なら一致", text: "rubykaigi", pattern: "rub", maxDist: 3, want: true},
+		{name: "3文字で1文字違いは不一致", text: "gp", pattern: "goo", maxDist: 3, want: false},
+		{name: "4文字なら距離1まで", text: "kotlon meetup", pattern: "kotl", maxDist: 3, want: true},
+		{name: "4文字で距離2は不一致", text: "kxxl", pattern: "kotl", maxDist: 3, want: false},
+		{name: "7文字は距離1に制限", text: "typexcrypt", pattern: "typescr", maxDist: 3, want: true},
+		{name: "7文字で距離2は不一致", text: "tyxexcr", pattern: "typescr", maxDist: 3, want: false},
+		{name: "8文字なら距離2まで", text: "kuburnxtes", pattern: "kubernet", maxDist: 3, want: true},
+		{name: "距離3は12文字以上で有効", text: "infrastrxxxu", pattern: "infrastructu", maxDist: 3, want: true},
+		{name: "11文字では距離3は効かない", text: "infrastrxxx", pattern: "infrastruct", maxDist: 3, want: false},
+		{name: "指定距離が上限より小さければ指定値", text: "kuburnxtes", pattern: "kubernet", maxDist: 1, want: false},
+		{name: "負の距離は0扱い", text: "kotlin", pattern: "kotlin", maxDist: -1, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fuzzyContains([]rune(tt.text), []rune(tt.pattern), tt.maxDist); got != tt.want {
+				t.Errorf("fuzzyContains(%q, %q, %d) = %v, want %v", tt.text, tt.pattern, tt.maxDist, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeywordMatcherRegexNormalizes(t *testing.T) {
+	rule := models.Rule{MatchMode: models.MatchModeRegex, MatchPatterns: []string{"もくもく会$", `^\D+ＧＯ`}}
+	matcher, err := NewKeywordMatcher(rule)
+	if err != nil {
+		t.Fatalf("NewKeywordMatcher: %v", err)
+	}
+	tests := []struct {
+		title string
+		want  bool
+	}{
+		{title: "週末モクモク会", want: true},
+		{title: "週末ﾓｸﾓｸ会", want: true},
+		{title: "初心者向けGo入門", want: true},
+		{title: "2024 Go入門", want: false},
+		{title: "もくもく会 その2", want: false},
+	}
+	for _, tt := range tests {
+		if got := matcher.Match(models.Event{Title: tt.title}); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.title, got, tt.want)
+		}
+	}
+}
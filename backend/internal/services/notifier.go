@@ -10,6 +10,9 @@ import (
 	"connpass-requirement/internal/repository"
 )
 
+// triggerWindow は開始・締切前通知を行う基準時刻前後の幅。
+const triggerWindow = 30 * time.Minute
+
 // NotifierService は通知条件の判定とDiscord送信を担当する。
 type NotifierService struct {
 	notificationRepo *repository.NotificationRepository
@@ -35,8 +38,10 @@ func NewNotifierService(
 	}
 }
 
-// Evaluate は通知対象のトリガーを返す。
-func (n *NotifierService) Evaluate(rule models.Rule, event models.Event, prev *models.Event) []string {
+// Evaluate は基準時刻nowにおける通知対象のトリガーを返す。
+// prevはこのルールが前回イベントに一致した時点の状態で、初めて一致した場合はnil。
+// forecastは満席予測で、予測できない場合はnil。
+func (n *NotifierService) Evaluate(rule models.Rule, event models.Event, prev *models.RuleEvent, forecast *FillForecast, now time.Time) []string {
 	var targets []string
 	for _, notifyType := range rule.NotifyTypes {
 		switch notifyType {
@@ -45,7 +50,9 @@ func (n *NotifierService) Evaluate(rule models.Rule, event models.Event, prev *m
 				targets = append(targets, notifyType)
 			}
 		case "start":
-			if withinWindow(event.StartedAt, 30*time.Minute) {
+			// 前回一致した時点で既に前後30分に入っていた場合は通知済みとみなす
+			if withinWindow(event.StartedAt, triggerWindow, now) &&
+				(prev == nil || !withinWindow(event.StartedAt, triggerWindow, prev.LastMatchedAt)) {
 				targets = append(targets, notifyType)
 			}
 		case "almost_full":
@@ -53,15 +60,23 @@ func (n *NotifierService) Evaluate(rule models.Rule, event models.Event, prev *m
 			if threshold == 0 {
 				threshold = n.defaultThreshold
 			}
-			if event.Limit > 0 {
-				rate := float64(event.Accepted) / float64(event.Limit) * 100
-				if int(rate+0.5) >= threshold {
-					targets = append(targets, notifyType)
-				}
+			// 前回一致時の申込数では閾値未満で、今回閾値に達した場合のみ通知する
+			if event.Limit > 0 && fillPercent(event.Limit, event.Accepted) >= threshold &&
+				(prev == nil || fillPercent(prev.Limit, prev.Accepted) < threshold) {
+				targets = append(targets, notifyType)
+			}
+		case "filling_fast":
+			horizon := rule.FillHorizonHours
+			if horizon == 0 {
+				horizon = DefaultFillHorizonHours
+			}
+			if forecast != nil && forecast.HoursToFull <= float64(horizon) {
+				targets = append(targets, notifyType)
 			}
 		case "before_deadline":
 			deadline := event.EndedAt.Add(-1 * time.Hour)
-			if withinWindow(deadline, 30*time.Minute) {
+			if withinWindow(deadline, triggerWindow, now) &&
+				(prev == nil || !withinWindow(deadline, triggerWindow, prev.LastMatchedAt)) {
 				targets = append(targets, notifyType)
 			}
 		}
@@ -69,54 +84,152 @@ func (n *NotifierService) Evaluate(rule models.Rule, event models.Event, prev *m
 	return targets
 }
 
-// Notify はDiscordへの通知と履歴登録を行う。
-func (n *NotifierService) Notify(ctx context.Context, rule models.Rule, event models.Event, notifyKey string) error {
-	exists, err := n.notificationRepo.Exists(ctx, rule.ID, event.EventID, notifyKey)
+// Forecast はイベントの直近の申込ペースから満席予測を求める。
+func (n *NotifierService) Forecast(ctx context.Context, event models.Event, now time.Time) (*FillForecast, error) {
+	snapshots, err := n.eventRepo.ListSnapshotsSince(ctx, event.EventID, now.Add(-forecastWindow))
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return ForecastFill(event, snapshots, now), nil
+}
+
+// AlreadySent はトリガーが通知済み（ベースライン記録を含む）か判定する。
+func (n *NotifierService) AlreadySent(ctx context.Context, rule models.Rule, event models.Event, notifyKey string) (bool, error) {
+	return n.notificationRepo.Exists(ctx, rule.ID, event.EventID, notifyKey)
+}
+
+// Notify はDiscordへの通知と履歴登録を行い、実際に送信した場合はtrueを返す。通知済みの場合は送信せずfalse。
+func (n *NotifierService) Notify(ctx context.Context, rule models.Rule, event models.Event, notifyKey string, forecast *FillForecast) (bool, error) {
+	exists, err := n.AlreadySent(ctx, rule, event, notifyKey)
+	if err != nil {
+		return false, err
 	}
 	if exists {
-		return nil
+		return false, nil
 	}
 
-	message := buildMessage(rule, event, notifyKey)
+	message := buildMessage(rule, event, notifyKey, forecast)
 	if err := n.discord.SendMessage(ctx, rule.ChannelID, message); err != nil {
 		n.logger.Error(ctx, "discord_send_failed", err.Error(), map[string]any{
 			"ruleId":    rule.ID,
+			"guildId":   rule.GuildID,
+			"channelId": rule.ChannelID,
 			"eventId":   event.EventID,
+			"eventUrl":  event.EventURL,
 			"notifyKey": notifyKey,
 		})
-		return err
+		return false, err
 	}
 
 	if err := n.notificationRepo.Record(ctx, rule.ID, event.EventID, notifyKey); err != nil {
-		return err
+		return true, err
 	}
 
 	n.logger.Info(ctx, "notification_sent", "Discord通知を送信しました", map[string]any{
 		"ruleId":    rule.ID,
+		"guildId":   rule.GuildID,
+		"channelId": rule.ChannelID,
 		"eventId":   event.EventID,
+		"title":     event.Title,
+		"eventUrl":  event.EventURL,
 		"notifyKey": notifyKey,
 	})
 
+	return true, nil
+}
+
+// SuppressBaseline は初回実行時に検出したトリガーをすべて送信せずに通知済みとして記録する。
+// 既存イベントは以降の変化（閾値の超過など）があった場合のみ通知される。
+func (n *NotifierService) SuppressBaseline(ctx context.Context, rule models.Rule, event models.Event, triggers []string) error {
+	for _, notifyKey := range triggers {
+		if err := n.notificationRepo.RecordBaseline(ctx, rule.ID, event.EventID, notifyKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NotifyBaselineSummary は初回実行時に該当した既存イベントをまとめて1通で通知する。
+func (n *NotifierService) NotifyBaselineSummary(ctx context.Context, rule models.Rule, events []models.Event) error {
+	message := buildBaselineSummary(rule, events)
+	if err := n.discord.SendMessage(ctx, rule.ChannelID, message); err != nil {
+		n.logger.Error(ctx, "discord_send_failed", err.Error(), map[string]any{
+			"ruleId":    rule.ID,
+			"guildId":   rule.GuildID,
+			"channelId": rule.ChannelID,
+			"notifyKey": "baseline_summary",
+		})
+		return err
+	}
+
+	n.logger.Info(ctx, "notification_sent", "初回ベースラインのまとめを送信しました", map[string]any{
+		"ruleId":    rule.ID,
+		"guildId":   rule.GuildID,
+		"channelId": rule.ChannelID,
+		"notifyKey": "baseline_summary",
+		"events":    len(events),
+	})
 	return nil
 }
 
-func withinWindow(target time.Time, window time.Duration) bool {
+// baselineSummaryLimit はまとめ通知に列挙するイベント数の上限。
+const baselineSummaryLimit = 10
+
+func buildBaselineSummary(rule models.Rule, events []models.Event) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("**ルール「%s」の監視を開始しました**\n", rule.Name))
+	builder.WriteString(fmt.Sprintf("現在公開中の該当イベント: %d件（以降は新規公開分のみ通知します）\n", len(events)))
+	for i, event := range events {
+		if i >= baselineSummaryLimit {
+			builder.WriteString(fmt.Sprintf("ほか%d件\n", len(events)-baselineSummaryLimit))
+			break
+		}
+		builder.WriteString(fmt.Sprintf("- %s %s\n", event.Title, event.EventURL))
+	}
+	return builder.String()
+}
+
+// wantsForecast はルールが満席予測を必要とするか判定する。
+func wantsForecast(rule models.Rule) bool {
+	for _, notifyType := range rule.NotifyTypes {
+		if notifyType == "filling_fast" {
+			return true
+		}
+	}
+	return false
+}
+
+// fillPercent は定員に対する申込数の割合（四捨五入した百分率）。定員がない場合は0。
+func fillPercent(limit, accepted int) int {
+	if limit <= 0 {
+		return 0
+	}
+	rate := float64(accepted) / float64(limit) * 100
+	return int(rate + 0.5)
+}
+
+func withinWindow(target time.Time, window time.Duration, now time.Time) bool {
 	if target.IsZero() {
 		return false
 	}
-	now := time.Now()
 	return target.After(now.Add(-window)) && target.Before(now.Add(window))
 }
 
-func buildMessage(rule models.Rule, event models.Event, notifyKey string) string {
+func buildMessage(rule models.Rule, event models.Event, notifyKey string, forecast *FillForecast) string {
 	var builder strings.Builder
 	builder.WriteString(fmt.Sprintf("**%s**\n", event.Title))
 	builder.WriteString(fmt.Sprintf("イベントURL: %s\n", event.EventURL))
 	builder.WriteString(fmt.Sprintf("開始: %s\n終了: %s\n", event.StartedAt.Format(time.RFC1123), event.EndedAt.Format(time.RFC1123)))
 	builder.WriteString(fmt.Sprintf("参加者: %d / %d (待機 %d)\n", event.Accepted, event.Limit, event.Waiting))
 	builder.WriteString(fmt.Sprintf("トリガー: %s\n", notifyKey))
+	if notifyKey == "filling_fast" && forecast != nil {
+		builder.WriteString(fmt.Sprintf("満席予測: 約%.1f時間後 (%s頃, 1時間あたり%.1f人ペース, 残り%d席)\n",
+			forecast.HoursToFull,
+			forecast.ProjectedFullAt.In(jst).Format("01/02 15:04"),
+			forecast.VelocityPerHour,
+			forecast.Remaining,
+		))
+	}
 	builder.WriteString(fmt.Sprintf("ルール: %s\n", rule.Name))
 	if rule.Description != "" {
 		builder.WriteString(rule.Description + "\n")
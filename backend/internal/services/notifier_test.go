@@ -0,0 +1,118 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"connpass-requirement/internal/models"
+)
+
+func TestEvaluate(t *testing.T) {
+	n := &NotifierService{defaultThreshold: 80}
+	now := jstTime(2026, 10, 20, 18, 50)
+	event := models.Event{
+		Limit:     100,
+		Accepted:  85,
+		StartedAt: jstTime(2026, 10, 20, 19, 0),
+		EndedAt:   jstTime(2026, 10, 20, 21, 0),
+	}
+	ruleFor := func(types ...string) models.Rule {
+		return models.Rule{NotifyTypes: types}
+	}
+	prevAt := func(at time.Time, accepted int) *models.RuleEvent {
+		return &models.RuleEvent{LastMatchedAt: at, Limit: 100, Accepted: accepted}
+	}
+
+	tests := []struct {
+		name     string
+		rule     models.Rule
+		event    models.Event
+		prev     *models.RuleEvent
+		forecast *FillForecast
+		now      time.Time
+		want     []string
+	}{
+		{name: "初めての一致はopen", rule: ruleFor("open"), event: event, now: now, want: []string{"open"}},
+		{name: "一致済みならopenなし", rule: ruleFor("open"), event: event, prev: prevAt(now.Add(-time.Hour), 85), now: now},
+		{name: "開始30分前以内で初回はstart", rule: ruleFor("start"), event: event, now: now, want: []string{"start"}},
+		{
+			name:  "前回は窓の外ならstart",
+			rule:  ruleFor("start"),
+			event: event,
+			prev:  prevAt(now.Add(-time.Hour), 85),
+			now:   now,
+			want:  []string{"start"},
+		},
+		{name: "前回すでに窓の中ならstartなし", rule: ruleFor("start"), event: event, prev: prevAt(now.Add(-5*time.Minute), 85), now: now},
+		{name: "開始まで遠いとstartなし", rule: ruleFor("start"), event: event, now: now.Add(-2 * time.Hour)},
+		{name: "初回で閾値以上ならalmost_full", rule: ruleFor("almost_full"), event: event, now: now, want: []string{"almost_full"}},
+		{
+			name:  "閾値を跨いだらalmost_full",
+			rule:  ruleFor("almost_full"),
+			event: event,
+			prev:  prevAt(now.Add(-time.Hour), 70),
+			now:   now,
+			want:  []string{"almost_full"},
+		},
+		{name: "前回も閾値以上ならalmost_fullなし", rule: ruleFor("almost_full"), event: event, prev: prevAt(now.Add(-time.Hour), 80), now: now},
+		{
+			name:  "ルールの閾値を優先",
+			rule:  models.Rule{NotifyTypes: []string{"almost_full"}, CapacityThresh: 90},
+			event: event,
+			now:   now,
+		},
+		{
+			name:  "定員なしはalmost_fullなし",
+			rule:  ruleFor("almost_full"),
+			event: models.Event{Accepted: 85, StartedAt: event.StartedAt},
+			now:   now,
+		},
+		{
+			name:     "予測期間内に満席ならfilling_fast",
+			rule:     ruleFor("filling_fast"),
+			event:    event,
+			forecast: &FillForecast{HoursToFull: 12},
+			now:      now,
+			want:     []string{"filling_fast"},
+		},
+		{name: "予測なしならfilling_fastなし", rule: ruleFor("filling_fast"), event: event, now: now},
+		{
+			name:     "予測期間外ならfilling_fastなし",
+			rule:     models.Rule{NotifyTypes: []string{"filling_fast"}, FillHorizonHours: 6},
+			event:    event,
+			forecast: &FillForecast{HoursToFull: 12},
+			now:      now,
+		},
+		{
+			name:  "締切1時間前の窓ならbefore_deadline",
+			rule:  ruleFor("before_deadline"),
+			event: event,
+			prev:  prevAt(now.Add(-time.Hour), 85),
+			now:   jstTime(2026, 10, 20, 19, 50),
+			want:  []string{"before_deadline"},
+		},
+		{
+			name:  "前回すでに締切前の窓ならbefore_deadlineなし",
+			rule:  ruleFor("before_deadline"),
+			event: event,
+			prev:  prevAt(jstTime(2026, 10, 20, 19, 45), 85),
+			now:   jstTime(2026, 10, 20, 19, 50),
+		},
+		{
+			name:  "複数のトリガーはルールの順で返す",
+			rule:  ruleFor("open", "start", "almost_full"),
+			event: event,
+			now:   now,
+			want:  []string{"open", "start", "almost_full"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := n.Evaluate(tt.rule, tt.event, tt.prev, tt.forecast, tt.now)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
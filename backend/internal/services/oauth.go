@@ -2,7 +2,11 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,9 +14,14 @@ import (
 	"strings"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+
 	"connpass-requirement/internal/config"
 )
 
+// ErrOAuthRevoked はリフレッシュトークンやアクセストークンが失効・取り消し済みの場合に返す。
+var ErrOAuthRevoked = errors.New("discord oauth token revoked")
+
 // OAuthService はDiscord OAuth2フローを扱う。
 type OAuthService struct {
 	cfg    config.Config
@@ -26,15 +35,116 @@ func NewOAuthService(cfg config.Config) *OAuthService {
 	}
 }
 
-// ExchangeCode は認可コードをアクセストークンへ交換する。
-func (o *OAuthService) ExchangeCode(ctx context.Context, code string) (*OAuthTokenResponse, error) {
-	endpoint := "https://discord.com/api/v10/oauth2/token"
+// oauthStateTTL はログイン開始からコールバックまでの猶予。
+const oauthStateTTL = 10 * time.Minute
+
+// oauthStateAudience はstateトークンをアクセストークンと区別するためのaud。
+const oauthStateAudience = "oauth_state"
+
+// ErrOAuthStateInvalid はstateの署名・期限・一致の検証に失敗した場合に返す。
+var ErrOAuthStateInvalid = errors.New("oauth state is invalid")
+
+// OAuthLogin はログイン開始時に発行するDiscordの認可URLと、コールバック検証用の署名済みstateトークン。
+type OAuthLogin struct {
+	AuthorizeURL string
+	StateToken   string
+	ExpiresAt    time.Time
+}
+
+// BeginLogin はstateとPKCEのverifierを生成し、Discordの認可URLを組み立てる。
+// stateとverifierは署名付きの短命なトークンにまとめ、呼び出し側でHttpOnly Cookieとして保持する。
+func (o *OAuthService) BeginLogin() (*OAuthLogin, error) {
+	state, err := randomToken(16)
+	if err != nil {
+		return nil, fmt.Errorf("generate oauth state: %w", err)
+	}
+	verifier, err := randomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("generate pkce verifier: %w", err)
+	}
+
+	expiresAt := time.Now().Add(oauthStateTTL)
+	stateToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"aud":      oauthStateAudience,
+		"state":    state,
+		"verifier": verifier,
+		"exp":      expiresAt.Unix(),
+	}).SignedString([]byte(o.cfg.JWTSecret))
+	if err != nil {
+		return nil, fmt.Errorf("sign oauth state: %w", err)
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{}
+	params.Set("client_id", o.cfg.DiscordClientID)
+	params.Set("redirect_uri", o.cfg.DiscordRedirectURI)
+	params.Set("response_type", "code")
+	params.Set("scope", "identify guilds")
+	params.Set("state", state)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+
+	return &OAuthLogin{
+		AuthorizeURL: "https://discord.com/oauth2/authorize?" + params.Encode(),
+		StateToken:   stateToken,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+// VerifyState は署名済みstateトークンとコールバックで受け取ったstateを照合し、PKCEのverifierを返す。
+// 失敗時はErrOAuthStateInvalidに理由を付けて返す。
+func (o *OAuthService) VerifyState(stateToken, state string) (string, error) {
+	if stateToken == "" {
+		return "", fmt.Errorf("%w: state cookie is missing", ErrOAuthStateInvalid)
+	}
+	if state == "" {
+		return "", fmt.Errorf("%w: state parameter is missing", ErrOAuthStateInvalid)
+	}
+
+	token, err := jwt.Parse(stateToken, func(token *jwt.Token) (any, error) {
+		return []byte(o.cfg.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(oauthStateAudience), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return "", fmt.Errorf("%w: %v", ErrOAuthStateInvalid, err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", fmt.Errorf("%w: invalid claims", ErrOAuthStateInvalid)
+	}
+	expected, _ := claims["state"].(string)
+	verifier, _ := claims["verifier"].(string)
+	if expected == "" || verifier == "" {
+		return "", fmt.Errorf("%w: invalid claims", ErrOAuthStateInvalid)
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(state)) != 1 {
+		return "", fmt.Errorf("%w: state mismatch", ErrOAuthStateInvalid)
+	}
+	return verifier, nil
+}
+
+// ExchangeCode はPKCEのverifierを添えて認可コードをアクセストークンへ交換する。
+func (o *OAuthService) ExchangeCode(ctx context.Context, code, codeVerifier string) (*OAuthTokenResponse, error) {
 	data := url.Values{}
-	data.Set("client_id", o.cfg.DiscordClientID)
-	data.Set("client_secret", o.cfg.DiscordClientSecret)
 	data.Set("grant_type", "authorization_code")
 	data.Set("code", code)
 	data.Set("redirect_uri", o.cfg.DiscordRedirectURI)
+	data.Set("code_verifier", codeVerifier)
+	return o.requestToken(ctx, data)
+}
+
+// RefreshToken はリフレッシュトークンでアクセストークンを更新する。
+// 取り消し済みの場合はErrOAuthRevokedを返す。
+func (o *OAuthService) RefreshToken(ctx context.Context, refreshToken string) (*OAuthTokenResponse, error) {
+	data := url.Values{}
+	data.Set("grant_type", "refresh_token")
+	data.Set("refresh_token", refreshToken)
+	return o.requestToken(ctx, data)
+}
+
+func (o *OAuthService) requestToken(ctx context.Context, data url.Values) (*OAuthTokenResponse, error) {
+	endpoint := "https://discord.com/api/v10/oauth2/token"
+	data.Set("client_id", o.cfg.DiscordClientID)
+	data.Set("client_secret", o.cfg.DiscordClientSecret)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(data.Encode()))
 	if err != nil {
@@ -51,6 +161,10 @@ func (o *OAuthService) ExchangeCode(ctx context.Context, code string) (*OAuthTok
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		// invalid_grantはトークンの失効・取り消しを表す
+		if resp.StatusCode == http.StatusBadRequest && strings.Contains(string(body), "invalid_grant") {
+			return nil, fmt.Errorf("%w: %s", ErrOAuthRevoked, string(body))
+		}
 		return nil, fmt.Errorf("discord token exchange failed: %s", string(body))
 	}
 
@@ -68,7 +182,7 @@ func (o *OAuthService) FetchIdentity(ctx context.Context, token string) (*Discor
 	if err != nil {
 		return nil, nil, err
 	}
-	guilds, err := o.getGuilds(ctx, token)
+	guilds, err := o.FetchGuilds(ctx, token)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -97,7 +211,8 @@ func (o *OAuthService) getUser(ctx context.Context, token string) (*DiscordUser,
 	return &user, nil
 }
 
-func (o *OAuthService) getGuilds(ctx context.Context, token string) ([]DiscordGuild, error) {
+// FetchGuilds はユーザーが所属するギルドを取得する。トークンが無効な場合はErrOAuthRevokedを返す。
+func (o *OAuthService) FetchGuilds(ctx context.Context, token string) ([]DiscordGuild, error) {
 	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://discord.com/api/v10/users/@me/guilds", nil)
 	req.Header.Set("Authorization", "Bearer "+token)
 
@@ -109,6 +224,9 @@ func (o *OAuthService) getGuilds(ctx context.Context, token string) ([]DiscordGu
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("%w: %s", ErrOAuthRevoked, string(body))
+		}
 		return nil, fmt.Errorf("discord guild fetch failed: %s", string(body))
 	}
 
@@ -5,80 +5,172 @@ import (
 	"fmt"
 	"time"
 
+	"connpass-requirement/internal/models"
 	"connpass-requirement/internal/repository"
 )
 
 // SchedulerService は30分毎に実行されるジョブを実装する。
 type SchedulerService struct {
-	ruleRepo         *repository.RuleRepository
-	notificationRepo *repository.NotificationRepository
-	eventRepo        *repository.EventRepository
-	logRepo          *repository.LogRepository
-	connpass         *ConnpassService
-	notifier         *NotifierService
-	logger           *LoggerService
+	ruleRepo          *repository.RuleRepository
+	ruleEventRepo     *repository.RuleEventRepository
+	notificationRepo  *repository.NotificationRepository
+	eventRepo         *repository.EventRepository
+	logRepo           *repository.LogRepository
+	connpass          *ConnpassService
+	notifier          *NotifierService
+	logger            *LoggerService
+	snapshotRetention time.Duration
+	trashRetention    time.Duration
+}
+
+// RunStats はスケジューラ1回分の処理件数を集計する。
+type RunStats struct {
+	Rules           int            `json:"rules"`
+	EventsFetched   int            `json:"eventsFetched"`
+	EventsFiltered  map[string]int `json:"eventsFiltered"`
+	EventsExcluded  int            `json:"eventsExcluded"`
+	BaselineRules   int            `json:"baselineRules"`
+	EventsBaselined int            `json:"eventsBaselined"`
+	Exclusions      []ExclusionHit `json:"exclusions"`
+	Notifications   int            `json:"notifications"`
+}
+
+// ExclusionHit は除外リストの値ごとの抑止件数。
+type ExclusionHit struct {
+	RuleID int64  `json:"ruleId"`
+	Kind   string `json:"kind"`
+	Value  string `json:"value"`
+	Count  int    `json:"count"`
+}
+
+func newRunStats() RunStats {
+	return RunStats{
+		EventsFiltered: map[string]int{},
+		Exclusions:     []ExclusionHit{},
+	}
+}
+
+func (s *RunStats) addExclusion(ruleID int64, kind, value string) {
+	s.EventsExcluded++
+	for i := range s.Exclusions {
+		hit := &s.Exclusions[i]
+		if hit.RuleID == ruleID && hit.Kind == kind && hit.Value == value {
+			hit.Count++
+			return
+		}
+	}
+	s.Exclusions = append(s.Exclusions, ExclusionHit{RuleID: ruleID, Kind: kind, Value: value, Count: 1})
+}
+
+// merge は別の集計結果を加算する。
+func (s *RunStats) merge(other RunStats) {
+	s.Rules += other.Rules
+	s.EventsFetched += other.EventsFetched
+	for reason, count := range other.EventsFiltered {
+		s.EventsFiltered[reason] += count
+	}
+	s.EventsExcluded += other.EventsExcluded
+	s.BaselineRules += other.BaselineRules
+	s.EventsBaselined += other.EventsBaselined
+	s.Exclusions = append(s.Exclusions, other.Exclusions...)
+	s.Notifications += other.Notifications
 }
 
 func NewSchedulerService(
 	ruleRepo *repository.RuleRepository,
+	ruleEventRepo *repository.RuleEventRepository,
 	notificationRepo *repository.NotificationRepository,
 	eventRepo *repository.EventRepository,
 	logRepo *repository.LogRepository,
 	connpass *ConnpassService,
 	notifier *NotifierService,
 	logger *LoggerService,
+	snapshotRetention time.Duration,
+	trashRetention time.Duration,
 ) *SchedulerService {
 	return &SchedulerService{
-		ruleRepo:         ruleRepo,
-		notificationRepo: notificationRepo,
-		eventRepo:        eventRepo,
-		logRepo:          logRepo,
-		connpass:         connpass,
-		notifier:         notifier,
-		logger:           logger,
+		ruleRepo:          ruleRepo,
+		ruleEventRepo:     ruleEventRepo,
+		notificationRepo:  notificationRepo,
+		eventRepo:         eventRepo,
+		logRepo:           logRepo,
+		connpass:          connpass,
+		notifier:          notifier,
+		logger:            logger,
+		snapshotRetention: snapshotRetention,
+		trashRetention:    trashRetention,
 	}
 }
 
-// Run はスケジュール処理を実行する。
-func (s *SchedulerService) Run(ctx context.Context) error {
+// Run はスケジュール処理を実行し、処理件数の統計を返す。
+func (s *SchedulerService) Run(ctx context.Context) (RunStats, error) {
 	s.logger.Info(ctx, "scheduler_start", "スケジューラを開始", nil)
 	start := time.Now()
+	stats := newRunStats()
 
 	rules, err := s.ruleRepo.ListActive(ctx)
 	if err != nil {
 		s.logger.Error(ctx, "database_error", "ルール一覧の取得に失敗", err)
 		s.logger.UpdateSchedulerStatus(ctx, start, err.Error())
-		return err
+		return stats, err
 	}
 
 	s.logger.Info(ctx, "scheduler_processing", fmt.Sprintf("処理するルール数: %d", len(rules)), nil)
 
+	guildStats := make(map[string]*RunStats)
 	for _, rule := range rules {
 		if len(rule.Keywords) == 0 {
 			s.logger.Info(ctx, "rule_skip", "キーワードが未設定のためスキップ", map[string]any{"ruleId": rule.ID, "ruleName": rule.Name})
 			continue
 		}
+		ruleStats := newRunStats()
+		ruleStats.Rules = 1
 		s.logger.Info(ctx, "rule_process", "ルールを処理中", map[string]any{"ruleId": rule.ID, "ruleName": rule.Name, "keywords": rule.Keywords})
+		matcher, err := NewKeywordMatcher(rule)
+		if err != nil {
+			s.logger.Warn(ctx, "rule_match_error", "キーワード照合に失敗", map[string]any{"ruleId": rule.ID, "guildId": rule.GuildID, "error": err.Error()})
+		}
+
+		// 新規・再有効化されたルールは初回実行で既存イベントをベースラインとして記録する
+		baseline := rule.BaselineAt == nil
+		baselineComplete := true
+		var baselineEvents []models.Event
+		// 複数のキーワードで同じイベントが見つかっても1件として扱う
+		baselined := make(map[int64]bool)
 
 		for _, keyword := range rule.Keywords {
 			select {
 			case <-ctx.Done():
-				return ctx.Err()
+				stats.merge(ruleStats)
+				return stats, ctx.Err()
 			default:
 			}
 
-			events, err := s.connpass.FetchEvents(ctx, keyword, rule.Location)
+			events, err := s.connpass.FetchEvents(ctx, QueryForRule(rule, keyword, start))
 			if err != nil {
 				s.logger.Error(ctx, "connpass_api_error", "connpass API取得に失敗", map[string]any{"keyword": keyword, "error": err.Error()})
+				baselineComplete = false
 				continue
 			}
+			ruleStats.EventsFetched += len(events)
 
 			s.logger.Info(ctx, "connpass_fetch", fmt.Sprintf("connpassから%d件のイベントを取得", len(events)), map[string]any{"keyword": keyword, "location": rule.Location})
 
 			for _, event := range events {
-				prev, err := s.eventRepo.FindByEventID(ctx, event.EventID)
+				screen := ScreenEvent(rule, matcher, event, start)
+				switch screen.Reason {
+				case "":
+				case FilterReasonExcluded:
+					ruleStats.addExclusion(rule.ID, screen.ExclusionKind, screen.ExclusionValue)
+					continue
+				default:
+					ruleStats.EventsFiltered[screen.Reason]++
+					continue
+				}
+
+				prev, err := s.ruleEventRepo.Find(ctx, rule.ID, event.EventID)
 				if err != nil {
-					s.logger.Error(ctx, "database_error", "イベントキャッシュ取得に失敗", err)
+					s.logger.Error(ctx, "database_error", "ルールの一致履歴取得に失敗", err)
 					continue
 				}
 
@@ -86,8 +178,34 @@ func (s *SchedulerService) Run(ctx context.Context) error {
 					s.logger.Error(ctx, "database_error", "イベントキャッシュ保存に失敗", err)
 					continue
 				}
+				if err := s.ruleEventRepo.Record(ctx, rule.ID, event); err != nil {
+					s.logger.Error(ctx, "database_error", "ルールの一致履歴保存に失敗", err)
+					continue
+				}
 
-				triggers := s.notifier.Evaluate(rule, event, prev)
+				now := time.Now()
+				var forecast *FillForecast
+				if wantsForecast(rule) {
+					forecast, err = s.notifier.Forecast(ctx, event, now)
+					if err != nil {
+						s.logger.Error(ctx, "database_error", "スナップショット取得に失敗", err)
+					}
+				}
+
+				triggers := s.notifier.Evaluate(rule, event, prev, forecast, now)
+				if baseline {
+					// 初回実行では既存イベントのトリガーを送信せず、一致履歴と通知済みの記録だけを残す
+					if err := s.notifier.SuppressBaseline(ctx, rule, event, triggers); err != nil {
+						s.logger.Error(ctx, "database_error", "ベースライン記録に失敗", err)
+						baselineComplete = false
+						continue
+					}
+					triggers = nil
+					if !baselined[event.EventID] {
+						baselined[event.EventID] = true
+						baselineEvents = append(baselineEvents, event)
+					}
+				}
 				if len(triggers) > 0 {
 					s.logger.Info(ctx, "notification_trigger", fmt.Sprintf("%d件の通知トリガーを検出", len(triggers)), map[string]any{
 						"eventId":  event.EventID,
@@ -96,21 +214,64 @@ func (s *SchedulerService) Run(ctx context.Context) error {
 					})
 				}
 				for _, notifyKey := range triggers {
-					if err := s.notifier.Notify(ctx, rule, event, notifyKey); err != nil {
-						continue
+					// 送信失敗はNotify内でログに記録される。通知済みで送らなかった分は数えない
+					if sent, _ := s.notifier.Notify(ctx, rule, event, notifyKey, forecast); sent {
+						ruleStats.Notifications++
 					}
 				}
 			}
 		}
+
+		if baseline && baselineComplete {
+			s.completeBaseline(ctx, rule, baselineEvents)
+			ruleStats.BaselineRules++
+			ruleStats.EventsBaselined += len(baselineEvents)
+		}
+		stats.merge(ruleStats)
+		if guildStats[rule.GuildID] == nil {
+			counts := newRunStats()
+			guildStats[rule.GuildID] = &counts
+		}
+		guildStats[rule.GuildID].merge(ruleStats)
 	}
 
 	cleanupBefore := time.Now().Add(-14 * 24 * time.Hour)
 	_ = s.eventRepo.Cleanup(ctx, cleanupBefore)
 	_ = s.notificationRepo.Cleanup(ctx, cleanupBefore)
 	_ = s.logRepo.Cleanup(ctx, time.Now().Add(-90*24*time.Hour))
+	_ = s.ruleEventRepo.Cleanup(ctx, time.Now().Add(-90*24*time.Hour))
+	if s.snapshotRetention > 0 {
+		_ = s.eventRepo.CleanupSnapshots(ctx, time.Now().Add(-s.snapshotRetention))
+	}
+	if purged, err := s.ruleRepo.PurgeDeleted(ctx, time.Now().Add(-s.trashRetention)); err == nil && purged > 0 {
+		s.logger.Info(ctx, "rule_purged", fmt.Sprintf("ゴミ箱のルールを%d件削除", purged), map[string]any{"count": purged})
+	}
 
 	s.logger.UpdateSchedulerStatus(ctx, time.Now(), "")
-	s.logger.Info(ctx, "scheduler_complete", "スケジューラが正常終了", map[string]any{"duration": time.Since(start).String()})
+	duration := time.Since(start).String()
+	s.logger.Info(ctx, "scheduler_complete", "スケジューラが正常終了", map[string]any{"duration": duration, "stats": stats})
+	// Webhookにはギルドごとに自ギルドのルール分の集計だけを配信する
+	for guildID, counts := range guildStats {
+		s.logger.Publish(ctx, guildID, models.WebhookEventSchedulerCompleted, "スケジューラが正常終了", map[string]any{"guildId": guildID, "duration": duration, "stats": counts})
+	}
 
-	return nil
+	return stats, nil
+}
+
+// completeBaseline はベースライン取得を完了として記録し、必要ならまとめ通知を送る。
+func (s *SchedulerService) completeBaseline(ctx context.Context, rule models.Rule, events []models.Event) {
+	if rule.BaselineMode == models.BaselineModeSummary && len(events) > 0 {
+		if err := s.notifier.NotifyBaselineSummary(ctx, rule, events); err != nil {
+			return
+		}
+	}
+	if err := s.ruleRepo.MarkBaselined(ctx, rule.ID, time.Now()); err != nil {
+		s.logger.Error(ctx, "database_error", "ベースライン完了の記録に失敗", err)
+		return
+	}
+	s.logger.Info(ctx, "rule_baseline", "初回実行のため既存イベントをベースラインとして記録", map[string]any{
+		"ruleId": rule.ID,
+		"mode":   rule.BaselineMode,
+		"events": len(events),
+	})
 }
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"connpass-requirement/internal/config"
+	"connpass-requirement/internal/models"
+	"connpass-requirement/internal/repository"
+)
+
+var (
+	// ErrSessionInvalid はリフレッシュトークンが不正・失効・期限切れの場合に返す。
+	ErrSessionInvalid = errors.New("session is invalid")
+	// ErrRefreshTokenReused は使用済みのリフレッシュトークンが再提示された場合に返す。該当セッションは失効させる。
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// refreshReuseGrace は直前のリフレッシュトークンを再利用とみなさない猶予。
+// 複数タブからの同時リフレッシュでセッションを失効させないためのもの。
+const refreshReuseGrace = 10 * time.Second
+
+// SessionTokens はログイン・リフレッシュ時に発行するトークンの組。
+// 猶予内の同時リフレッシュではRefreshTokenは空で、先に発行されたリフレッシュトークンをそのまま使う。
+type SessionTokens struct {
+	AccessToken  string
+	RefreshToken string
+	Session      models.Session
+}
+
+// SessionService はサーバー側セッションと短命なアクセストークンを発行する。
+type SessionService struct {
+	cfg      config.Config
+	sessions *repository.SessionRepository
+	users    *repository.UserRepository
+}
+
+func NewSessionService(cfg config.Config, sessions *repository.SessionRepository, users *repository.UserRepository) *SessionService {
+	return &SessionService{cfg: cfg, sessions: sessions, users: users}
+}
+
+// Issue はログイン時に新しいセッションを作成する。
+func (s *SessionService) Issue(ctx context.Context, user models.User, userAgent, ipAddress string) (*SessionTokens, error) {
+	id, err := randomToken(16)
+	if err != nil {
+		return nil, fmt.Errorf("generate session id: %w", err)
+	}
+	refresh, err := randomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("generate refresh token: %w", err)
+	}
+
+	session := models.Session{
+		ID:        id,
+		UserID:    user.ID,
+		UserAgent: truncate(userAgent, 512),
+		IPAddress: ipAddress,
+		ExpiresAt: time.Now().Add(s.cfg.SessionDuration),
+	}
+	if err := s.sessions.Create(ctx, &session, hashSessionToken(refresh)); err != nil {
+		return nil, err
+	}
+
+	access, err := s.accessToken(user, session)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionTokens{AccessToken: access, RefreshToken: refresh, Session: session}, nil
+}
+
+// Refresh はリフレッシュトークンをローテーションし、新しいアクセストークンを発行する。
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string) (*SessionTokens, error) {
+	next, err := randomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("generate refresh token: %w", err)
+	}
+
+	hash := hashSessionToken(refreshToken)
+	session, err := s.sessions.Rotate(ctx, hash, hashSessionToken(next))
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		// 直前にローテーションされたばかりなら同時リフレッシュとみなし、アクセストークンのみ再発行する
+		session, err = s.sessions.FindRecentlyRotated(ctx, hash, refreshReuseGrace)
+		if err != nil {
+			return nil, err
+		}
+		if session != nil {
+			user, err := s.users.FindByID(ctx, session.UserID)
+			if err != nil {
+				return nil, err
+			}
+			access, err := s.accessToken(*user, *session)
+			if err != nil {
+				return nil, err
+			}
+			return &SessionTokens{AccessToken: access, Session: *session}, nil
+		}
+
+		userID, err := s.sessions.RevokeByPreviousHash(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		if userID != 0 {
+			return nil, ErrRefreshTokenReused
+		}
+		return nil, ErrSessionInvalid
+	}
+
+	user, err := s.users.FindByID(ctx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	access, err := s.accessToken(*user, *session)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionTokens{AccessToken: access, RefreshToken: next, Session: *session}, nil
+}
+
+// AccessTokenTTL はアクセストークンの有効期間。セッションより長くはしない。
+func (s *SessionService) AccessTokenTTL() time.Duration {
+	if s.cfg.SessionDuration < s.cfg.AccessTokenTTL {
+		return s.cfg.SessionDuration
+	}
+	return s.cfg.AccessTokenTTL
+}
+
+func (s *SessionService) accessToken(user models.User, session models.Session) (string, error) {
+	now := time.Now()
+	exp := now.Add(s.AccessTokenTTL())
+	if exp.After(session.ExpiresAt) {
+		exp = session.ExpiresAt
+	}
+	claims := jwt.MapClaims{
+		"sub":             user.ID,
+		"discord_user_id": user.DiscordUserID,
+		"jti":             session.ID,
+		"iat":             now.Unix(),
+		"exp":             exp.Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.cfg.JWTSecret))
+	if err != nil {
+		return "", fmt.Errorf("sign access token: %w", err)
+	}
+	return token, nil
+}
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return s[:limit]
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"connpass-requirement/internal/models"
+	"connpass-requirement/internal/repository"
+)
+
+// SimulatorService は通知を送らずにルールの判定結果を再現する。
+type SimulatorService struct {
+	ruleEventRepo *repository.RuleEventRepository
+	connpass      *ConnpassService
+	notifier      *NotifierService
+}
+
+func NewSimulatorService(ruleEventRepo *repository.RuleEventRepository, connpass *ConnpassService, notifier *NotifierService) *SimulatorService {
+	return &SimulatorService{ruleEventRepo: ruleEventRepo, connpass: connpass, notifier: notifier}
+}
+
+// シミュレーションで送信しないトリガーの理由。
+const (
+	SuppressReasonBaseline    = "baseline"
+	SuppressReasonAlreadySent = "already_sent"
+)
+
+// SimulatedEvent はシミュレーションでのイベントごとの判定結果。
+// Triggersは実際に送信されるもの、Suppressedは条件を満たすが送信されないもの。
+type SimulatedEvent struct {
+	Event      models.Event        `json:"event"`
+	Keyword    string              `json:"keyword"`
+	Triggers   []string            `json:"triggers,omitempty"`
+	Suppressed []SuppressedTrigger `json:"suppressed,omitempty"`
+	Forecast   *FillForecast       `json:"forecast,omitempty"`
+	ScreenResult
+}
+
+// SuppressedTrigger は送信されないトリガーとその理由。
+type SuppressedTrigger struct {
+	NotifyKey string `json:"notifyKey"`
+	Reason    string `json:"reason"`
+}
+
+// SimulationResult はシミュレーション全体の結果。
+type SimulationResult struct {
+	ReferenceTime time.Time        `json:"referenceTime"`
+	Baseline      bool             `json:"baseline"`
+	Matched       []SimulatedEvent `json:"matched"`
+	Filtered      []SimulatedEvent `json:"filtered"`
+	Errors        []string         `json:"errors,omitempty"`
+}
+
+// Simulate は基準時刻nowでルールを評価する。イベントキャッシュと通知履歴は更新しない。
+// 保存済みのルール（IDあり）では一致履歴・通知履歴・ベースライン状態をスケジューラと同じように反映する。
+func (s *SimulatorService) Simulate(ctx context.Context, rule models.Rule, now time.Time) (SimulationResult, error) {
+	result := SimulationResult{
+		ReferenceTime: now,
+		Baseline:      rule.BaselineAt == nil,
+		Matched:       []SimulatedEvent{},
+		Filtered:      []SimulatedEvent{},
+	}
+	seen := make(map[int64]bool)
+	matcher, err := NewKeywordMatcher(rule)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+
+	for _, keyword := range rule.Keywords {
+		events, err := s.connpass.FetchEvents(ctx, QueryForRule(rule, keyword, now))
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			result.Errors = append(result.Errors, keyword+": "+err.Error())
+			continue
+		}
+
+		for _, event := range events {
+			if seen[event.EventID] {
+				continue
+			}
+			seen[event.EventID] = true
+
+			screen := ScreenEvent(rule, matcher, event, now)
+			simulated := SimulatedEvent{Event: event, Keyword: keyword, ScreenResult: screen}
+			if screen.Reason != "" {
+				result.Filtered = append(result.Filtered, simulated)
+				continue
+			}
+
+			var prev *models.RuleEvent
+			if rule.ID != 0 {
+				prev, err = s.ruleEventRepo.Find(ctx, rule.ID, event.EventID)
+				if err != nil {
+					return result, err
+				}
+			}
+			if wantsForecast(rule) {
+				simulated.Forecast, err = s.notifier.Forecast(ctx, event, now)
+				if err != nil {
+					return result, err
+				}
+			}
+			triggers := s.notifier.Evaluate(rule, event, prev, simulated.Forecast, now)
+			simulated.Triggers, simulated.Suppressed, err = s.suppress(ctx, rule, event, triggers, result.Baseline)
+			if err != nil {
+				return result, err
+			}
+			result.Matched = append(result.Matched, simulated)
+		}
+	}
+
+	return result, nil
+}
+
+// suppress はスケジューラと同じ条件で送信されないトリガーを振り分ける。
+// ベースライン取得前はすべて、保存済みのルールでは通知済みのものを除く。
+func (s *SimulatorService) suppress(ctx context.Context, rule models.Rule, event models.Event, triggers []string, baseline bool) ([]string, []SuppressedTrigger, error) {
+	var sent []string
+	var suppressed []SuppressedTrigger
+	for _, notifyKey := range triggers {
+		if baseline {
+			suppressed = append(suppressed, SuppressedTrigger{NotifyKey: notifyKey, Reason: SuppressReasonBaseline})
+			continue
+		}
+		if rule.ID != 0 {
+			exists, err := s.notifier.AlreadySent(ctx, rule, event, notifyKey)
+			if err != nil {
+				return nil, nil, err
+			}
+			if exists {
+				suppressed = append(suppressed, SuppressedTrigger{NotifyKey: notifyKey, Reason: SuppressReasonAlreadySent})
+				continue
+			}
+		}
+		sent = append(sent, notifyKey)
+	}
+	return sent, suppressed, nil
+}
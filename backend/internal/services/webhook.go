@@ -0,0 +1,353 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"connpass-requirement/internal/models"
+	"connpass-requirement/internal/repository"
+)
+
+const (
+	webhookPollInterval = 15 * time.Second
+	webhookLease        = 2 * time.Minute
+	webhookBatchSize    = 50
+	webhookRetention    = 30 * 24 * time.Hour
+)
+
+// webhookBackoff は失敗後の再試行間隔。要素数+1回目の失敗で配信を打ち切る。
+var webhookBackoff = []time.Duration{
+	1 * time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+	6 * time.Hour,
+}
+
+// ErrWebhookURLNotAllowed は送信先URLがhttpsでない、または公開されていないアドレスを指す場合のエラー。
+var ErrWebhookURLNotAllowed = errors.New("webhook url must be https and resolve to a public address")
+
+// carrierGradeNAT は共有アドレス空間（100.64.0.0/10）。net.IPのIsPrivateには含まれない。
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// WebhookPayload はWebhookへ送信するJSON本文。
+type WebhookPayload struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	GuildID   string    `json:"guildId,omitempty"`
+	Message   string    `json:"message"`
+	Data      any       `json:"data"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// WebhookService はシステムイベントを署名付きJSONとして外部へ配信する。
+type WebhookService struct {
+	repo   *repository.WebhookRepository
+	client *http.Client
+	wake   chan struct{}
+}
+
+func NewWebhookService(repo *repository.WebhookRepository) *WebhookService {
+	return &WebhookService{
+		repo:   repo,
+		client: newWebhookClient(),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// newWebhookClient は内部ネットワークへ送信しないHTTPクライアントを返す。
+// 接続直前に実際の接続先IPを検査するため、DNSの応答が登録後に変わっても内部アドレスへは接続しない。
+// リダイレクトには従わず、3xxは失敗として扱う。
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return ErrWebhookURLNotAllowed
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// ValidateWebhookURL は送信先URLがhttpsで、ホストが公開アドレスのみに解決されることを確認する。
+func ValidateWebhookURL(ctx context.Context, raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme != "https" || parsed.Hostname() == "" || parsed.User != nil {
+		return ErrWebhookURLNotAllowed
+	}
+	host := parsed.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !isPublicIP(ip) {
+			return ErrWebhookURLNotAllowed
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("%w: cannot resolve host", ErrWebhookURLNotAllowed)
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return ErrWebhookURLNotAllowed
+		}
+	}
+	return nil
+}
+
+// isPublicIP はループバック・プライベート・リンクローカル・未指定などの内部向けアドレスでなければtrueを返す。
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil && (carrierGradeNAT.Contains(ip4) || ip4[0] == 0 || ip4.Equal(net.IPv4bcast)) {
+		return false
+	}
+	return true
+}
+
+// GenerateWebhookSecret は署名用のシークレットを生成する。
+func GenerateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// SignWebhookPayload は"タイムスタンプ.本文"のHMAC-SHA256署名を返す。
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Emit はギルドでイベントを購読しているWebhookへの配信を登録する。ギルドを特定できないイベントは配信しない。
+func (s *WebhookService) Emit(ctx context.Context, guildID, eventType, message string, data any) error {
+	if guildID == "" {
+		return nil
+	}
+	webhooks, err := s.repo.ListSubscribers(ctx, guildID, eventType)
+	if err != nil {
+		return err
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	eventID := make([]byte, 16)
+	if _, err := rand.Read(eventID); err != nil {
+		return fmt.Errorf("generate webhook event id: %w", err)
+	}
+	payload := WebhookPayload{
+		ID:        "evt_" + hex.EncodeToString(eventID),
+		Type:      eventType,
+		GuildID:   guildID,
+		Message:   message,
+		Data:      data,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	for _, webhook := range webhooks {
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("marshal webhook payload: %w", err)
+		}
+		delivery := models.WebhookDelivery{
+			WebhookID: webhook.ID,
+			EventID:   payload.ID,
+			EventType: eventType,
+			Payload:   string(body),
+		}
+		if err := s.repo.CreateDelivery(ctx, &delivery); err != nil {
+			return err
+		}
+	}
+
+	s.notify()
+	return nil
+}
+
+// Replay は過去の配信と同じ本文を新しい配信として再送する。
+func (s *WebhookService) Replay(ctx context.Context, original models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	delivery := models.WebhookDelivery{
+		WebhookID: original.WebhookID,
+		EventID:   original.EventID,
+		EventType: original.EventType,
+		Payload:   original.Payload,
+		ReplayOf:  &original.ID,
+	}
+	if err := s.repo.CreateDelivery(ctx, &delivery); err != nil {
+		return nil, err
+	}
+	s.notify()
+	return &delivery, nil
+}
+
+func (s *WebhookService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start はctxが終了するまで配信待ちのWebhookを定期的に送信する。
+func (s *WebhookService) Start(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	lastCleanup := time.Time{}
+
+	for {
+		if _, err := s.DeliverDue(ctx); err != nil && ctx.Err() != nil {
+			return
+		}
+		if time.Since(lastCleanup) > time.Hour {
+			_ = s.repo.CleanupDeliveries(ctx, time.Now().Add(-webhookRetention))
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// DeliverDue は再試行時刻を迎えた配信を送信し、送信を試みた件数を返す。
+func (s *WebhookService) DeliverDue(ctx context.Context) (int, error) {
+	total := 0
+	webhooks := make(map[int64]*models.Webhook)
+	for {
+		deliveries, err := s.repo.ClaimDue(ctx, time.Now().UTC(), webhookLease, webhookBatchSize)
+		if err != nil {
+			return total, err
+		}
+		if len(deliveries) == 0 {
+			return total, nil
+		}
+
+		for i := range deliveries {
+			delivery := &deliveries[i]
+			webhook, ok := webhooks[delivery.WebhookID]
+			if !ok {
+				webhook, err = s.repo.Get(ctx, delivery.WebhookID)
+				if err != nil {
+					return total, err
+				}
+				webhooks[delivery.WebhookID] = webhook
+			}
+			s.attempt(ctx, webhook, delivery)
+			if err := s.repo.RecordAttempt(ctx, delivery); err != nil {
+				return total, err
+			}
+			total++
+		}
+
+		if len(deliveries) < webhookBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// attempt は1回分の送信を行い、結果に応じて配信状態と次回試行時刻を更新する。
+func (s *WebhookService) attempt(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) {
+	now := time.Now().UTC()
+	delivery.Attempts++
+	delivery.ResponseStatus = nil
+
+	if webhook == nil || !webhook.IsActive {
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.LastError = "webhook is disabled"
+		return
+	}
+
+	code, err := s.send(ctx, webhook, delivery, now)
+	if code != 0 {
+		delivery.ResponseStatus = &code
+	}
+	if err == nil {
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts > len(webhookBackoff) {
+		delivery.Status = models.WebhookDeliveryFailed
+		return
+	}
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.NextAttemptAt = now.Add(webhookBackoff[delivery.Attempts-1])
+}
+
+func (s *WebhookService) send(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery, now time.Time) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("create request: %w", err)
+	}
+	// 制限導入前に登録されたhttpのURLにも送信しない
+	if req.URL.Scheme != "https" {
+		return 0, ErrWebhookURLNotAllowed
+	}
+
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "connpass-requirement-webhook/1.0")
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Id", delivery.EventID)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", SignWebhookPayload(webhook.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
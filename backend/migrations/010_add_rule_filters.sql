@@ -0,0 +1,14 @@
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS venue_type TEXT NOT NULL DEFAULT 'any';
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS weekdays INTEGER[] NOT NULL DEFAULT '{}';
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS time_from TEXT NOT NULL DEFAULT '';
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS time_to TEXT NOT NULL DEFAULT '';
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS within_days INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS rule_prefectures (
+    rule_id BIGINT NOT NULL REFERENCES rules(id) ON DELETE CASCADE,
+    prefecture TEXT NOT NULL,
+    PRIMARY KEY(rule_id, prefecture)
+);
+
+ALTER TABLE events_cache ADD COLUMN IF NOT EXISTS address TEXT NOT NULL DEFAULT '';
+ALTER TABLE events_cache ADD COLUMN IF NOT EXISTS place TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS rule_exclusions (
+    rule_id BIGINT NOT NULL REFERENCES rules(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY(rule_id, kind, value)
+);
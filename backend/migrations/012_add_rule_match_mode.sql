@@ -0,0 +1,10 @@
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS match_mode TEXT NOT NULL DEFAULT 'off';
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS fuzzy_distance INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS rule_match_patterns (
+    rule_id BIGINT NOT NULL REFERENCES rules(id) ON DELETE CASCADE,
+    pattern TEXT NOT NULL,
+    PRIMARY KEY(rule_id, pattern)
+);
+
+ALTER TABLE events_cache ADD COLUMN IF NOT EXISTS catch TEXT NOT NULL DEFAULT '';
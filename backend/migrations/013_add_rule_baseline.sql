@@ -0,0 +1,6 @@
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS baseline_mode TEXT NOT NULL DEFAULT 'silent';
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS baseline_at TIMESTAMPTZ;
+
+UPDATE rules SET baseline_at = NOW() WHERE baseline_at IS NULL;
+
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS baseline BOOLEAN NOT NULL DEFAULT FALSE;
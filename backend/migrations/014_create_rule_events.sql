@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS rule_events (
+    rule_id BIGINT NOT NULL REFERENCES rules(id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL,
+    first_matched_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_matched_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    "limit" INTEGER NOT NULL DEFAULT 0,
+    accepted INTEGER NOT NULL DEFAULT 0,
+    waiting INTEGER NOT NULL DEFAULT 0,
+    hash_digest TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY(rule_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rule_events_event_id ON rule_events(event_id);
+CREATE INDEX IF NOT EXISTS idx_rule_events_last_matched_at ON rule_events(last_matched_at);
+
+-- 既存ルールの一致履歴を通知履歴から引き継ぐ。ベースラインは取り直さない
+-- 通知済みのイベントはここで一致済みになるためopenは再送されず、他のトリガーも通知履歴で重複を防ぐ
+INSERT INTO rule_events (rule_id, event_id, first_matched_at, last_matched_at, "limit", accepted, waiting, hash_digest)
+SELECT n.rule_id, n.event_id, MIN(n.sent_at), MAX(n.sent_at),
+    COALESCE(MAX(e."limit"), 0), COALESCE(MAX(e.accepted), 0),
+    COALESCE(MAX(e.waiting), 0), COALESCE(MAX(e.hash_digest), '')
+FROM notifications n
+LEFT JOIN events_cache e ON e.event_id = n.event_id
+GROUP BY n.rule_id, n.event_id
+ON CONFLICT (rule_id, event_id) DO NOTHING;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS event_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    event_id BIGINT NOT NULL,
+    "limit" INTEGER NOT NULL DEFAULT 0,
+    accepted INTEGER NOT NULL DEFAULT 0,
+    waiting INTEGER NOT NULL DEFAULT 0,
+    hash_digest TEXT NOT NULL,
+    observed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_snapshots_event_observed ON event_snapshots(event_id, observed_at);
+CREATE INDEX IF NOT EXISTS idx_event_snapshots_observed_at ON event_snapshots(observed_at);
@@ -0,0 +1,3 @@
+ALTER TYPE notify_trigger ADD VALUE IF NOT EXISTS 'filling_fast';
+
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS fill_horizon_hours INTEGER NOT NULL DEFAULT 24;
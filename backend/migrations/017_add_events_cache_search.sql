@@ -0,0 +1,6 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_events_cache_search_trgm ON events_cache
+    USING GIN ((title || ' ' || COALESCE(owner_nickname, '') || ' ' || COALESCE(series_title, '')) gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_events_cache_started_at ON events_cache(started_at, event_id);
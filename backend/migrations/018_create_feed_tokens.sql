@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS feed_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    scope TEXT NOT NULL,
+    rule_id BIGINT REFERENCES rules(id) ON DELETE CASCADE,
+    guild_id TEXT,
+    token_hash TEXT NOT NULL,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_feed_tokens_hash ON feed_tokens(token_hash);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_feed_tokens_rule ON feed_tokens(rule_id) WHERE scope = 'rule';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_feed_tokens_guild ON feed_tokens(guild_id) WHERE scope = 'guild';
+
+ALTER TABLE events_cache ADD COLUMN IF NOT EXISTS revision INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE events_cache ADD COLUMN IF NOT EXISTS revised_at TIMESTAMPTZ;
+
+UPDATE events_cache SET revised_at = retrieved_at WHERE revised_at IS NULL;
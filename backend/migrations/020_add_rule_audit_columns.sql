@@ -0,0 +1,7 @@
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS created_by BIGINT REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS updated_by BIGINT REFERENCES users(id) ON DELETE SET NULL;
+
+UPDATE rules SET created_by = user_id WHERE created_by IS NULL;
+UPDATE rules SET updated_by = user_id WHERE updated_by IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_rules_guild_id ON rules(guild_id);
@@ -0,0 +1,20 @@
+ALTER TABLE guild_permissions ADD COLUMN IF NOT EXISTS role_ids TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS guild_member_roles (
+    guild_id TEXT NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL,
+    granted_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY(guild_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS guild_role_mappings (
+    guild_id TEXT NOT NULL,
+    discord_role_id TEXT NOT NULL,
+    role TEXT NOT NULL,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY(guild_id, discord_role_id)
+);
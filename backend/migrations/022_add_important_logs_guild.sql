@@ -0,0 +1,7 @@
+ALTER TABLE important_logs ADD COLUMN IF NOT EXISTS guild_id TEXT;
+
+UPDATE important_logs
+SET guild_id = metadata->>'guildId'
+WHERE guild_id IS NULL AND jsonb_typeof(metadata) = 'object' AND metadata->>'guildId' IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_important_logs_guild_created ON important_logs(guild_id, created_at DESC);
@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS guilds_synced_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS sessions_revoked_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_users_guilds_synced_at ON users(guilds_synced_at);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    guild_id TEXT NOT NULL,
+    actor_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    api_token_id BIGINT REFERENCES api_tokens(id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL,
+    target_id TEXT NOT NULL DEFAULT '',
+    before JSONB,
+    after JSONB,
+    diff JSONB NOT NULL DEFAULT '{}',
+    ip_address TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_guild ON audit_events(guild_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events(guild_id, target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events(guild_id, actor_user_id);
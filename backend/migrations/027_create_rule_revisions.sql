@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS rule_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    rule_id BIGINT NOT NULL REFERENCES rules(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    restored_from INTEGER,
+    config JSONB NOT NULL,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (rule_id, revision)
+);
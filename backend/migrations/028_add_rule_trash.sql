@@ -0,0 +1,4 @@
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE rules ADD COLUMN IF NOT EXISTS deleted_by BIGINT REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_rules_deleted_at ON rules(deleted_at) WHERE deleted_at IS NOT NULL;
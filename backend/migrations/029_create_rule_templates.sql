@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS rule_templates (
+    id BIGSERIAL PRIMARY KEY,
+    guild_id TEXT,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    config JSONB NOT NULL,
+    use_count INTEGER NOT NULL DEFAULT 0,
+    last_used_at TIMESTAMPTZ,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    promoted_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rule_templates_guild ON rule_templates(guild_id, use_count DESC);
+
+CREATE TABLE IF NOT EXISTS rule_template_uses (
+    id BIGSERIAL PRIMARY KEY,
+    template_id BIGINT NOT NULL REFERENCES rule_templates(id) ON DELETE CASCADE,
+    guild_id TEXT NOT NULL,
+    rule_id BIGINT REFERENCES rules(id) ON DELETE SET NULL,
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rule_template_uses_template ON rule_template_uses(template_id, created_at DESC);
+
+INSERT INTO rule_templates (name, description, config)
+SELECT v.name, v.description, v.config::jsonb
+FROM (VALUES
+    ('地域のキーワード新着・残席わずか', '指定した地域で開催されるキーワードのイベントを、公開時と残席わずかで通知します。',
+     '{"name": "{{keywords}} in {{location}}", "keywords": ["Go"], "notifyTypes": ["almost_full", "open"], "venueType": "offline", "location": "東京", "capacityThreshold": 80, "isActive": true, "baselineMode": "silent"}'),
+    ('オンライン開催の新着・申込開始', 'オンライン開催のイベントを公開時と申込開始時に通知します。',
+     '{"name": "{{keywords}} オンライン", "keywords": ["Go"], "notifyTypes": ["open", "start"], "venueType": "online", "isActive": true, "baselineMode": "silent"}'),
+    ('満席前アラート', '人気イベントが満席になりそうなときだけ通知します。',
+     '{"name": "{{keywords}} 満席前アラート", "keywords": ["Go"], "notifyTypes": ["almost_full", "filling_fast"], "venueType": "any", "capacityThreshold": 80, "fillHorizonHours": 24, "isActive": true, "baselineMode": "silent"}')
+) AS v(name, description, config)
+WHERE NOT EXISTS (SELECT 1 FROM rule_templates WHERE guild_id IS NULL);
@@ -39,7 +39,9 @@ export default function LoginPage() {
     }
 
     const search = typeof window !== 'undefined' ? window.location.search : '';
-    const code = search ? new URLSearchParams(search).get('code') : null;
+    const params = search ? new URLSearchParams(search) : null;
+    const code = params?.get('code') ?? null;
+    const state = params?.get('state') ?? '';
     if (!code) {
       return;
     }
@@ -50,7 +52,7 @@ export default function LoginPage() {
     const run = async () => {
       setLoading(true);
       try {
-        await exchangeCode(code);
+        await exchangeCode(code, state);
         if (!cancelled) {
           router.replace('/dashboard');
         }
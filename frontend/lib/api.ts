@@ -1,4 +1,4 @@
-import axios from 'axios';
+import axios, { AxiosError, InternalAxiosRequestConfig } from 'axios';
 
 const apiBaseUrl = process.env.NEXT_PUBLIC_API_BASE_URL ?? 'http://localhost:8080/api';
 
@@ -6,3 +6,42 @@ export const apiClient = axios.create({
   baseURL: apiBaseUrl,
   withCredentials: true
 });
+
+type RetriableConfig = InternalAxiosRequestConfig & { _retried?: boolean };
+
+// アクセストークンは短命なため、401時はリフレッシュCookieで再発行して1度だけ再試行する
+let refreshing: Promise<void> | null = null;
+
+apiClient.interceptors.response.use(
+  (response) => response,
+  async (error: AxiosError) => {
+    const config = error.config as RetriableConfig | undefined;
+    const url = config?.url ?? '';
+    if (
+      error.response?.status !== 401 ||
+      !config ||
+      config._retried ||
+      url.includes('/auth/refresh') ||
+      url.includes('/auth/callback')
+    ) {
+      return Promise.reject(error);
+    }
+
+    config._retried = true;
+    if (!refreshing) {
+      refreshing = apiClient
+        .post('/auth/refresh')
+        .then(() => undefined)
+        .finally(() => {
+          refreshing = null;
+        });
+    }
+
+    try {
+      await refreshing;
+    } catch {
+      return Promise.reject(error);
+    }
+    return apiClient(config);
+  }
+);
@@ -2,27 +2,14 @@
 
 import { apiClient } from './api';
 
-const clientId = process.env.NEXT_PUBLIC_DISCORD_CLIENT_ID ?? '';
-const redirectUri = process.env.NEXT_PUBLIC_DISCORD_REDIRECT_URI ?? '';
-const oauthBase = process.env.NEXT_PUBLIC_DISCORD_OAUTH_URL ?? 'https://discord.com/oauth2/authorize';
-
+// stateとPKCEはAPIが発行するため、ログイン開始はAPI経由でDiscordへリダイレクトする
 export function initiateLogin() {
-  if (!clientId || !redirectUri) {
-    throw new Error('Discord OAuthの環境変数が設定されていません');
-  }
-
-  const params = new URLSearchParams({
-    client_id: clientId,
-    redirect_uri: redirectUri,
-    response_type: 'code',
-    scope: 'identify guilds'
-  });
-
-  window.location.href = `${oauthBase}?${params.toString()}`;
+  const baseUrl = apiClient.defaults.baseURL ?? '';
+  window.location.href = `${baseUrl.replace(/\/$/, '')}/auth/login`;
 }
 
-export async function exchangeCode(code: string) {
-  const { data } = await apiClient.post('/auth/callback', { code });
+export async function exchangeCode(code: string, state: string) {
+  const { data } = await apiClient.post('/auth/callback', { code, state });
   return data;
 }
 